@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/export"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config")
+	format := fs.String("format", "csv", "Output format: "+strings.Join(export.Formats, "|"))
+	out := fs.String("o", "-", "Output file ('-' — stdout)")
+	sortBy := fs.String("sort", "ip", "Sort: last_seen|first_seen|ip|port|service, '-' prefix for descending (xml is always by ip)")
+	state := fs.String("state", "", "Only ports in this state: open|closed")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: scanner export [flags] ['<query>']\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	f := storage.ResultFilter{
+		Query: strings.Join(fs.Args(), " "),
+		State: *state,
+		Sort:  export.SortFor(*format, *sortBy),
+	}
+	if _, _, err := export.ContentType(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := storage.CheckResultFilter(f); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	_, pg := openPostgres(*configPath)
+	defer pg.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			logger.Fatalf("export: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+	bw := bufio.NewWriter(w)
+
+	ew, err := export.NewWriter(bw, *format, strings.Join(os.Args[1:], " "))
+	if err != nil {
+		logger.Fatalf("export: %v", err)
+	}
+	n := 0
+	err = pg.EachResult(f, func(r storage.ResultRow) error {
+		n++
+		return ew.Write(r)
+	})
+	if err == nil {
+		err = ew.Close()
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if errors.Is(err, storage.ErrInvalidFilter) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err != nil {
+		logger.Fatalf("export failed: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "%d findings exported\n", n)
+}
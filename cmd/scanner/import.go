@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/scan"
+)
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config")
+	format := fs.String("format", "auto", "Input format: auto|"+strings.Join(scan.ImportFormats, "|"))
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: scanner import [flags] <file>...\n\n")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, pg := openPostgres(*configPath)
+	defer pg.Close()
+
+	if err := pg.Migrate("./migrations"); err != nil {
+		logger.Fatalf("migrations failed: %v", err)
+	}
+
+	runner := scan.NewRunner(cfg, nil)
+	runner.SetPostgres(pg)
+
+	failed := false
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			logger.Errorf("import %s: %v", path, err)
+			failed = true
+			continue
+		}
+		run, err := runner.Import(f, scan.ImportOptions{Format: *format, Name: filepath.Base(path)})
+		f.Close()
+		if errors.Is(err, scan.ErrBadImport) {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		if err != nil {
+			logger.Fatalf("import %s failed: %v", path, err)
+		}
+		fmt.Printf("%s: scan %s engine=%s hosts=%d found=%d new=%d\n",
+			path, run.ID, run.Engine, run.TargetsCount, run.Found, run.NewFound)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -1,30 +1,107 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/L1nMay/portscanner/internal/config"
 	"github.com/L1nMay/portscanner/internal/logger"
 	"github.com/L1nMay/portscanner/internal/scan"
 	"github.com/L1nMay/portscanner/internal/storage"
+	"github.com/L1nMay/portscanner/internal/tracing"
 )
 
+const usage = `usage: scanner [command] [flags]
+
+commands:
+  scan    run a scan with targets from config (default); -dry-run prints the plan
+  query   search findings: scanner query 'port:3389 AND NOT tag:vpn'
+  export  write findings as csv, ndjson or nmap xml: scanner export -format xml -o inv.xml
+  import  load nmap xml or masscan json/list files: scanner import dmz.xml
+  report  render the html exposure report: scanner report -from 7d -o report.html
+`
+
 func main() {
-	configPath := flag.String("config", "config.yaml", "Path to config")
-	flag.Parse()
+	args := os.Args[1:]
+	cmd := "scan"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	switch cmd {
+	case "scan":
+		runScan(args)
+	case "query":
+		runQuery(args)
+	case "export":
+		runExport(args)
+	case "import":
+		runImport(args)
+	case "report":
+		runReport(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+}
 
-	cfg, err := config.LoadConfig(*configPath)
+// openPostgres — конфиг + подключение к БД (общая часть команд)
+func openPostgres(configPath string) (*config.Config, *storage.Postgres) {
+	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
 		logger.Fatalf("failed to load config: %v", err)
 	}
+	if err := logger.Setup(cfg.Log); err != nil {
+		logger.Fatalf("log config: %v", err)
+	}
 
 	// DSN берём из cfg.Database.DSN (туда уже может прилететь ENV DATABASE_DSN через override в LoadConfig)
 	pg, err := storage.NewPostgres(cfg.Database.DSN)
 	if err != nil {
 		logger.Fatalf("failed to connect postgres: %v", err)
 	}
+	return cfg, pg
+}
+
+func runScan(args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config")
+	dry := fs.Bool("dry-run", false, "Print the scan plan with estimates and exit")
+	_ = fs.Parse(args)
+
+	cfg, pg := openPostgres(*configPath)
 	defer pg.Close()
 
+	if *dry {
+		runner := scan.NewRunner(cfg, nil)
+		runner.SetPostgres(pg)
+		plan, err := runner.Plan(cfg)
+		if err != nil {
+			logger.Fatalf("plan failed: %v", err)
+		}
+		printPlan(os.Stdout, plan)
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(cfg.Tracing)
+	if err != nil {
+		logger.Fatalf("tracing config: %v", err)
+	}
+	// спаны уходят пачками в фоне — дописываем очередь до выхода
+	flushTracing := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdownTracing(ctx)
+	}
+	defer flushTracing()
+
 	// migrations
 	if err := pg.Migrate("./migrations"); err != nil {
 		logger.Fatalf("migrations failed: %v", err)
@@ -34,8 +111,9 @@ func main() {
 	runner := scan.NewRunner(cfg, nil)
 	runner.SetPostgres(pg)
 
-	run, _, err := runner.RunOnce()
+	run, err := runner.RunOnceCtx(context.Background())
 	if err != nil {
+		flushTracing()
 		logger.Fatalf("scan failed: %v", err)
 	}
 
@@ -47,3 +125,18 @@ func main() {
 		run.NewFound,
 	)
 }
+
+// printPlan — план скана для -dry-run
+func printPlan(w io.Writer, p *scan.ScanPlan) {
+	fmt.Fprintf(w, "targets:    %s\n", strings.Join(p.Targets, ", "))
+	fmt.Fprintf(w, "engine:     %s (%s)\n", p.Engine, p.Reason)
+	fmt.Fprintf(w, "interface:  %s\n", p.Interface)
+	fmt.Fprintf(w, "ports:      %s (%d)\n", p.Ports, p.PortCount)
+	fmt.Fprintf(w, "addresses:  %d\n", p.Addresses)
+	fmt.Fprintf(w, "probes:     %d at %d/s\n", p.Probes, p.Rate)
+	fmt.Fprintf(w, "estimated:  %s (incl. wait %ds, banners %.0fs)\n", p.Estimated, p.WaitSeconds, p.BannerSeconds)
+	fmt.Fprintf(w, "banners:    ~%d open ports, up to %.0fs each, concurrency %d\n", p.ExpectedOpenPorts, p.BannerSecondsPerPort, p.BannerConcurrency)
+	for _, warn := range p.Warnings {
+		fmt.Fprintf(w, "warning:    %s\n", warn)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/query"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+func runQuery(args []string) {
+	fs := flag.NewFlagSet("query", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config")
+	sortBy := fs.String("sort", "-last_seen", "Sort: last_seen|first_seen|ip|port|service, '-' prefix for descending")
+	limit := fs.Int("limit", storage.DefaultResultLimit, "Max rows (0 — all)")
+	asJSON := fs.Bool("json", false, "Print NDJSON instead of a table")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: scanner query [flags] '<query>'\n\nfields: %s\n\n", strings.Join(query.Fields(), ", "))
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	q := strings.Join(fs.Args(), " ")
+	// синтаксис проверяем до подключения к БД
+	if _, err := query.Parse(q); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	_, pg := openPostgres(*configPath)
+	defer pg.Close()
+
+	var (
+		tw      *tabwriter.Writer
+		enc     = json.NewEncoder(os.Stdout)
+		printed int
+		total   int
+		cursor  string
+	)
+	if !*asJSON {
+		tw = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "IP\tPORT\tSERVICE\tSTATE\tLAST SEEN\tTAGS\tBANNER")
+	}
+
+	for {
+		pageSize := storage.MaxResultLimit
+		if *limit > 0 && *limit-printed < pageSize {
+			pageSize = *limit - printed
+		}
+		page, err := pg.ListResults(storage.ResultFilter{Query: q, Sort: *sortBy, Limit: pageSize, Cursor: cursor})
+		if errors.Is(err, storage.ErrInvalidFilter) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if err != nil {
+			logger.Fatalf("query failed: %v", err)
+		}
+		total = page.Total
+
+		for _, r := range page.Items {
+			if *asJSON {
+				_ = enc.Encode(r)
+			} else {
+				fmt.Fprintf(tw, "%s\t%d/%s\t%s\t%s\t%s\t%s\t%s\n",
+					r.IP, r.Port, r.Proto, r.Service, r.State,
+					r.LastSeen.Local().Format(time.DateTime), strings.Join(r.Tags, ","), oneLine(r.Banner, 60))
+			}
+		}
+		printed += len(page.Items)
+
+		cursor = page.NextCursor
+		if cursor == "" || (*limit > 0 && printed >= *limit) {
+			break
+		}
+	}
+
+	if tw != nil {
+		_ = tw.Flush()
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d findings\n", printed, total)
+}
+
+// oneLine — баннер в одну строку для таблицы
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max-1]) + "…"
+	}
+	return s
+}
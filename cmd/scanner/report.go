@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/report"
+)
+
+func runReport(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "Path to config")
+	out := fs.String("o", "", "Output file (default: exposure-report-<date>.html, '-' — stdout)")
+	from := fs.String("from", "", "Period start: 2006-01-02, RFC3339 or age like 7d (default: end of the previous report)")
+	to := fs.String("to", "", "Period end (default: now)")
+	save := fs.Bool("save", true, "Store the report so the next one starts where this one ended")
+	_ = fs.Parse(args)
+
+	now := time.Now()
+	opts := report.Options{Kind: "manual", CreatedBy: "cli", Save: *save}
+	var err error
+	if *from != "" {
+		if opts.From, err = parseWhen(*from, now); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *to != "" {
+		if opts.To, err = parseWhen(*to, now); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	_, pg := openPostgres(*configPath)
+	defer pg.Close()
+
+	if err := pg.Migrate("./migrations"); err != nil {
+		logger.Fatalf("migrations failed: %v", err)
+	}
+
+	rep, html, err := report.Generate(pg, opts)
+	if err != nil {
+		logger.Fatalf("report failed: %v", err)
+	}
+
+	path := *out
+	if path == "" {
+		path = report.FileName(rep)
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(html)
+	} else {
+		err = os.WriteFile(path, html, 0o644)
+	}
+	if err != nil {
+		logger.Fatalf("report write: %v", err)
+	}
+
+	s := rep.Summary
+	fmt.Fprintf(os.Stderr, "report %s — %s: %d hosts, %d open ports, %d new, %d closed, %d violations",
+		rep.PeriodFrom.Local().Format(time.DateTime), rep.PeriodTo.Local().Format(time.DateTime),
+		s.Hosts, s.OpenPorts, s.NewPorts, s.ClosedPorts, s.ViolatingPorts)
+	if path != "-" {
+		fmt.Fprintf(os.Stderr, " -> %s", path)
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+// parseWhen — дата, RFC3339 или давность (7d, 12h)
+func parseWhen(v string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+	if n, ok := strings.CutSuffix(v, "d"); ok {
+		if days, err := strconv.Atoi(n); err == nil && days >= 0 {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (2006-01-02, RFC3339 or age like 7d)", v)
+}
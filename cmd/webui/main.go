@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"net/http"
+	"os"
 
+	"github.com/L1nMay/portscanner/internal/alerting"
+	"github.com/L1nMay/portscanner/internal/auth"
 	"github.com/L1nMay/portscanner/internal/config"
 	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/notifier"
 	"github.com/L1nMay/portscanner/internal/scan"
 	"github.com/L1nMay/portscanner/internal/storage"
+	"github.com/L1nMay/portscanner/internal/telegram"
+	"github.com/L1nMay/portscanner/internal/tracing"
 	"github.com/L1nMay/portscanner/internal/webui"
 )
 
@@ -19,6 +27,13 @@ func main() {
 	if err != nil {
 		logger.Fatalf("failed to load config: %v", err)
 	}
+	if err := logger.Setup(cfg.Log); err != nil {
+		logger.Fatalf("log config: %v", err)
+	}
+	// сервер работает до kill — очередь спанов при выходе не дописывается
+	if _, err := tracing.Setup(cfg.Tracing); err != nil {
+		logger.Fatalf("tracing config: %v", err)
+	}
 
 	pg, err := storage.NewPostgres(cfg.Database.DSN)
 	if err != nil {
@@ -31,10 +46,44 @@ func main() {
 		logger.Fatalf("migrations failed: %v", err)
 	}
 
+	// сканы прошлого запуска: процесс, который их вёл, уже не допишет итог
+	if n, err := pg.InterruptScanRuns(); err != nil {
+		logger.Errorf("mark interrupted scans: %v", err)
+	} else if n > 0 {
+		logger.Infof("Marked %d interrupted scan(s) as failed", n)
+	}
+
+	// первый администратор
+	if err := bootstrapAdmin(cfg, pg); err != nil {
+		logger.Fatalf("bootstrap admin: %v", err)
+	}
+
 	// runner
 	runner := scan.NewRunner(cfg, nil)
 	runner.SetPostgres(pg)
 
+	// alerts
+	engine := alerting.NewEngine(pg)
+	engine.SetRules(cfg.Alerts.Rules) // правила из БД добавит worker
+	channels, err := notifier.FromConfig(cfg)
+	if err != nil {
+		logger.Fatalf("notifications: %v", err)
+	}
+	if len(channels) > 0 {
+		worker := notifier.NewWorker(cfg, pg, channels, engine)
+		go worker.Run(context.Background())
+		go notifier.NewDigester(cfg.Notifications.Digest, pg).Run(context.Background())
+	}
+
+	// telegram commands
+	if cfg.Telegram.Commands {
+		bot, err := telegram.NewBot(cfg, pg, runner, engine)
+		if err != nil {
+			logger.Fatalf("telegram bot: %v", err)
+		}
+		go bot.Run(context.Background())
+	}
+
 	// web ui
 	server := webui.NewServer(cfg, pg, runner)
 
@@ -43,3 +92,32 @@ func main() {
 		logger.Fatalf("web ui error: %v", err)
 	}
 }
+
+// bootstrapAdmin — создаёт admin-пользователя, если пользователей ещё нет
+func bootstrapAdmin(cfg *config.Config, pg *storage.Postgres) error {
+	n, err := pg.CountUsers()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	password := cfg.WebUI.AdminPassword
+	generated := password == ""
+	if generated {
+		password = auth.NewToken()[:20]
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	u := &storage.User{Username: cfg.WebUI.AdminUser, PasswordHash: hash, Role: string(auth.RoleAdmin)}
+	if err := pg.CreateUser(u); err != nil {
+		return err
+	}
+	logger.Infof("Created admin user %q", u.Username)
+	if generated {
+		// пароль — только в stderr, мимо logger: логи уходят в общий сбор
+		fmt.Fprintf(os.Stderr, "\nGenerated password for admin user %q: %s\nChange it after first login; it is not shown again.\n\n", u.Username, password)
+	}
+	return nil
+}
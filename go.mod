@@ -3,11 +3,10 @@ module github.com/L1nMay/portscanner
 go 1.22
 
 require (
+	github.com/lib/pq v1.10.9
 	go.etcd.io/bbolt v1.3.9
+	golang.org/x/crypto v0.31.0
 	gopkg.in/yaml.v3 v3.0.1
 )
 
-require (
-	github.com/lib/pq v1.10.9 // indirect
-	golang.org/x/sys v0.4.0 // indirect
-)
+require golang.org/x/sys v0.28.0 // indirect
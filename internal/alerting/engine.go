@@ -0,0 +1,137 @@
+package alerting
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State — окна throttle/dedup правил. Живут вне процесса (storage.Postgres),
+// поэтому события могут маршрутизировать несколько воркеров одновременно,
+// а окна переживают рестарт.
+type State interface {
+	// FireAlertRule — фиксирует срабатывание r по ключу dedupKey, если его
+	// не подавляют dedup или throttle; false — подавлено
+	FireAlertRule(r *Rule, dedupKey string, now time.Time) (bool, error)
+}
+
+// Engine — маршрутизация событий по правилам с throttling/dedup/mute.
+// Throttle и dedup считаются в State по имени правила, mute хранится в БД
+// и подтягивается через SetMutes.
+type Engine struct {
+	mu    sync.Mutex
+	rules []Rule
+	state State
+
+	muted map[string]time.Time // rule -> до какого момента молчать
+}
+
+func NewEngine(state State) *Engine {
+	return &Engine{
+		state: state,
+		muted: map[string]time.Time{},
+	}
+}
+
+// SetRules — заменяет набор правил (правила должны пройти Compile)
+func (e *Engine) SetRules(rules []Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+func (e *Engine) Rules() []Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Rule(nil), e.rules...)
+}
+
+// HasRules — есть ли хоть одно включённое правило
+func (e *Engine) HasRules() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range e.rules {
+		if r.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// Mute — глушит правило до момента until
+func (e *Engine) Mute(rule string, until time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.muted[rule] = until
+}
+
+// SetMutes — заменяет mute всех правил (из БД: общие для экземпляров)
+func (e *Engine) SetMutes(muted map[string]time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.muted = muted
+}
+
+// Route — каналы, в которые нужно доставить событие.
+// matched — событие подошло хотя бы под одно включённое правило; пустые
+// каналы при matched означают, что всё подавлено mute/dedup/throttle.
+// Если State недоступен, правило считается сработавшим (лишнее уведомление
+// лучше потерянного), а ошибка возвращается вместе с каналами.
+func (e *Engine) Route(ev Event, now time.Time) (channels []string, matched bool, err error) {
+	e.mu.Lock()
+	e.gc(now)
+	rules := e.rules
+	muted := make(map[string]time.Time, len(e.muted))
+	for k, v := range e.muted {
+		muted[k] = v
+	}
+	e.mu.Unlock()
+
+	set := map[string]struct{}{}
+	var errs []error
+
+	for i := range rules {
+		r := &rules[i]
+		if !r.Enabled {
+			continue
+		}
+		if !r.Matches(ev, now) {
+			continue
+		}
+		matched = true
+		if until, ok := muted[r.Name]; ok && now.Before(until) {
+			continue
+		}
+
+		if r.DedupSeconds > 0 || (r.ThrottleCount > 0 && r.ThrottleSeconds > 0) {
+			fire, err := e.state.FireAlertRule(r, DedupKey(r.Name, ev), now)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rule %s: %w", r.Name, err))
+			} else if !fire {
+				continue
+			}
+		}
+
+		for _, c := range r.Channels {
+			set[c] = struct{}{}
+		}
+	}
+
+	channels = make([]string, 0, len(set))
+	for c := range set {
+		channels = append(channels, c)
+	}
+	sort.Strings(channels)
+	return channels, matched, errors.Join(errs...)
+}
+
+// gc — чистим протухшие mute
+func (e *Engine) gc(now time.Time) {
+	for k, until := range e.muted {
+		if !now.Before(until) {
+			delete(e.muted, k)
+		}
+	}
+}
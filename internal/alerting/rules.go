@@ -0,0 +1,276 @@
+package alerting
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/netutil"
+	"github.com/L1nMay/portscanner/internal/policy"
+)
+
+// Event — то, что маршрутизируем (тип + payload из таблицы events)
+type Event struct {
+	ID        int64
+	Type      string
+	Payload   map[string]any
+	CreatedAt time.Time
+}
+
+// Match — условия правила; пустое поле = без ограничения
+type Match struct {
+	Types       []string `json:"types,omitempty" yaml:"types"`       // new_port, policy_violation, ...
+	Ports       string   `json:"ports,omitempty" yaml:"ports"`       // "22,3389,8000-8100"
+	Services    []string `json:"services,omitempty" yaml:"services"` // ssh, rdp, ...
+	Tags        []string `json:"tags,omitempty" yaml:"tags"`         // любой из тегов хоста
+	MinSeverity string   `json:"min_severity,omitempty" yaml:"min_severity"`
+	CIDRs       []string `json:"cidrs,omitempty" yaml:"cidrs"`
+	TimeWindow  string   `json:"time_window,omitempty" yaml:"time_window"` // "09:00-18:00", через полночь тоже можно
+	Days        []string `json:"days,omitempty" yaml:"days"`               // mon, tue, ... sun
+}
+
+type Rule struct {
+	ID       int64    `json:"id,omitempty" yaml:"-"`
+	Name     string   `json:"name" yaml:"name"`
+	Enabled  bool     `json:"enabled" yaml:"enabled"`
+	Source   string   `json:"source,omitempty" yaml:"-"` // config | api
+	Match    Match    `json:"match" yaml:"match"`
+	Channels []string `json:"channels" yaml:"channels"`
+
+	// не больше ThrottleCount уведомлений за ThrottleSeconds (0 — без ограничения)
+	ThrottleCount   int `json:"throttle_count,omitempty" yaml:"throttle_count"`
+	ThrottleSeconds int `json:"throttle_seconds,omitempty" yaml:"throttle_seconds"`
+
+	// одинаковое событие (тип + ip + порт) не повторяется в течение окна
+	DedupSeconds int `json:"dedup_seconds,omitempty" yaml:"dedup_seconds"`
+
+	// только для YAML: правила из конфига включены, если явно не disabled
+	Disabled bool `json:"-" yaml:"disabled"`
+
+	ports    netutil.PortSet
+	networks []*net.IPNet
+	window   *timeWindow
+	days     map[time.Weekday]struct{}
+}
+
+type timeWindow struct {
+	from, to int // минуты от начала суток
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Compile — проверяет правило и готовит его к сопоставлению
+func (r *Rule) Compile() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if len(r.Channels) == 0 {
+		return fmt.Errorf("rule %s: at least one channel is required", r.Name)
+	}
+
+	ports, err := netutil.ParsePorts(r.Match.Ports)
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	r.ports = ports
+
+	r.networks = nil
+	for _, c := range r.Match.CIDRs {
+		n, err := netutil.NormalizeCIDR(c)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		_, ipnet, _ := net.ParseCIDR(n)
+		r.networks = append(r.networks, ipnet)
+	}
+
+	if s := r.Match.MinSeverity; s != "" && policy.SeverityRank(s) == 0 {
+		return fmt.Errorf("rule %s: invalid min_severity: %s", r.Name, s)
+	}
+
+	r.window = nil
+	if tw := strings.TrimSpace(r.Match.TimeWindow); tw != "" {
+		w, err := parseWindow(tw)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		r.window = w
+	}
+
+	r.days = nil
+	for _, d := range r.Match.Days {
+		key := strings.ToLower(strings.TrimSpace(d))
+		if len(key) > 3 {
+			key = key[:3] // monday -> mon
+		}
+		wd, ok := weekdays[key]
+		if !ok {
+			return fmt.Errorf("rule %s: invalid day: %s", r.Name, d)
+		}
+		if r.days == nil {
+			r.days = map[time.Weekday]struct{}{}
+		}
+		r.days[wd] = struct{}{}
+	}
+
+	if r.ThrottleCount < 0 || r.ThrottleSeconds < 0 || r.DedupSeconds < 0 {
+		return fmt.Errorf("rule %s: negative throttle/dedup", r.Name)
+	}
+
+	return nil
+}
+
+func parseWindow(s string) (*timeWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid time_window: %s", s)
+	}
+	from, err := parseClock(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	to, err := parseClock(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	return &timeWindow{from: from, to: to}, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w *timeWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.from <= w.to {
+		return m >= w.from && m < w.to
+	}
+	// окно через полночь: 22:00-06:00
+	return m >= w.from || m < w.to
+}
+
+// Matches — подходит ли событие под условия правила (без учёта throttle/dedup)
+func (r *Rule) Matches(ev Event, now time.Time) bool {
+	m := r.Match
+
+	if len(m.Types) > 0 && !containsFold(m.Types, ev.Type) {
+		return false
+	}
+
+	if len(r.ports) > 0 {
+		port, ok := payloadInt(ev.Payload["port"])
+		if !ok || !r.ports.Contains(port) {
+			return false
+		}
+	}
+
+	if len(m.Services) > 0 && !containsFold(m.Services, fmt.Sprint(ev.Payload["service"])) {
+		return false
+	}
+
+	if len(m.Tags) > 0 {
+		hit := false
+		for _, t := range PayloadStrings(ev.Payload["tags"]) {
+			if containsFold(m.Tags, t) {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			return false
+		}
+	}
+
+	if m.MinSeverity != "" {
+		sev, _ := ev.Payload["severity"].(string)
+		if policy.SeverityRank(sev) < policy.SeverityRank(m.MinSeverity) {
+			return false
+		}
+	}
+
+	if len(r.networks) > 0 {
+		ip := net.ParseIP(fmt.Sprint(ev.Payload["ip"]))
+		if ip == nil {
+			return false
+		}
+		hit := false
+		for _, n := range r.networks {
+			if n.Contains(ip) {
+				hit = true
+				break
+			}
+		}
+		if !hit {
+			return false
+		}
+	}
+
+	if r.window != nil && !r.window.contains(now) {
+		return false
+	}
+	if r.days != nil {
+		if _, ok := r.days[now.Weekday()]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DedupKey — ключ дедупликации события в рамках правила
+func DedupKey(rule string, ev Event) string {
+	return strings.Join([]string{
+		rule,
+		ev.Type,
+		fmt.Sprint(ev.Payload["ip"]),
+		fmt.Sprint(ev.Payload["port"]),
+		fmt.Sprint(ev.Payload["proto"]),
+	}, "|")
+}
+
+// PayloadStrings — []string из JSON payload (после Unmarshal это []any)
+func PayloadStrings(v any) []string {
+	switch arr := v.(type) {
+	case []string:
+		return arr
+	case []any:
+		out := make([]string, 0, len(arr))
+		for _, x := range arr {
+			out = append(out, fmt.Sprint(x))
+		}
+		return out
+	}
+	return nil
+}
+
+func payloadInt(v any) (int, bool) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), true
+	case int:
+		return n, true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, x := range list {
+		if strings.EqualFold(strings.TrimSpace(x), s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role — роль пользователя; роли вложены: admin > operator > viewer
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // читает результаты
+	RoleOperator Role = "operator" // + запускает и отменяет сканы
+	RoleAdmin    Role = "admin"    // + политики, правила, пользователи
+)
+
+func (r Role) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+func (r Role) Valid() bool { return r.rank() > 0 }
+
+// Allows — достаточно ли роли r для действия, требующего need
+func (r Role) Allows(need Role) bool {
+	return r.rank() > 0 && r.rank() >= need.rank()
+}
+
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	if !r.Valid() {
+		return "", fmt.Errorf("invalid role %q (viewer|operator|admin)", s)
+	}
+	return r, nil
+}
+
+// Principal — кто выполняет запрос
+type Principal struct {
+	UserID   int64  `json:"user_id,omitempty"`
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+	Via      string `json:"via"` // session | token | legacy
+
+	// только для API-токенов
+	TokenID      int64    `json:"token_id,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+	AllowedCIDRs []string `json:"allowed_cidrs,omitempty"` // пусто — любые цели
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext — principal текущего запроса (nil, если не аутентифицирован)
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
+
+const minPasswordLen = 8
+
+func HashPassword(password string) (string, error) {
+	if len(password) < minPasswordLen {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLen)
+	}
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// dummyHash — сравнение с ним для несуществующих пользователей,
+// чтобы время ответа не выдавало, есть ли такой логин
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("portscanner-dummy"), bcrypt.DefaultCost)
+
+func CheckDummy(password string) {
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// NewToken — случайный секрет (сессии, CSRF, пароли по умолчанию)
+func NewToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// HashToken — в БД храним только sha256 от секрета
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// limiterMaxKeys — потолок числа ключей: логины приходят от клиента, и
+// перебор случайных имён иначе раздувал бы карту без предела
+const limiterMaxKeys = 10000
+
+// LoginLimiter — ограничение подбора пароля: после maxFails неудач
+// ключ (ip или логин) блокируется на window. Протухшие ключи вычищаются
+// раз в window; сверх limiterMaxKeys вытесняется ключ с самой старой неудачей.
+type LoginLimiter struct {
+	mu        sync.Mutex
+	fails     map[string][]time.Time
+	maxFails  int
+	window    time.Duration
+	lastSweep time.Time
+}
+
+func NewLoginLimiter(maxFails int, window time.Duration) *LoginLimiter {
+	return &LoginLimiter{fails: map[string][]time.Time{}, maxFails: maxFails, window: window}
+}
+
+func (l *LoginLimiter) Blocked(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.recent(key, now)) >= l.maxFails
+}
+
+func (l *LoginLimiter) Fail(key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= l.window {
+		l.sweep(now)
+	}
+	if _, ok := l.fails[key]; !ok && len(l.fails) >= limiterMaxKeys {
+		l.evictOldest()
+	}
+	l.fails[key] = append(l.recent(key, now), now)
+}
+
+// sweep — удаляет ключи без неудач в пределах window
+func (l *LoginLimiter) sweep(now time.Time) {
+	for key := range l.fails {
+		l.recent(key, now)
+	}
+	l.lastSweep = now
+}
+
+// evictOldest — удаляет ключ, последняя неудача которого самая давняя
+func (l *LoginLimiter) evictOldest() {
+	var (
+		oldest string
+		at     time.Time
+	)
+	for key, list := range l.fails {
+		if last := list[len(list)-1]; oldest == "" || last.Before(at) {
+			oldest, at = key, last
+		}
+	}
+	delete(l.fails, oldest)
+}
+
+func (l *LoginLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.fails, key)
+}
+
+func (l *LoginLimiter) recent(key string, now time.Time) []time.Time {
+	list := l.fails[key]
+	kept := list[:0]
+	for _, t := range list {
+		if now.Sub(t) < l.window {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.fails, key)
+		return nil
+	}
+	l.fails[key] = kept
+	return kept
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Scope — право API-токена; scopes вложены так же, как роли
+const (
+	ScopeResultsRead = "results:read" // = viewer
+	ScopeScanRun     = "scan:run"     // = operator
+	ScopeAdmin       = "admin"        // = admin
+)
+
+// TokenPrefix — по нему authenticate отличает API-токены от legacy auth_token
+const TokenPrefix = "pst_"
+
+func scopeRole(scope string) Role {
+	switch scope {
+	case ScopeResultsRead:
+		return RoleViewer
+	case ScopeScanRun:
+		return RoleOperator
+	case ScopeAdmin:
+		return RoleAdmin
+	default:
+		return ""
+	}
+}
+
+// ParseScopes — проверка и нормализация списка scopes
+func ParseScopes(in []string) ([]string, error) {
+	out := make([]string, 0, len(in))
+	seen := map[string]struct{}{}
+	for _, s := range in {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if s == "" {
+			continue
+		}
+		if scopeRole(s) == "" {
+			return nil, fmt.Errorf("invalid scope %q (results:read|scan:run|admin)", s)
+		}
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	return out, nil
+}
+
+// ScopesRole — роль, которую дают scopes (самый сильный из них)
+func ScopesRole(scopes []string) Role {
+	var best Role
+	for _, s := range scopes {
+		if r := scopeRole(s); r.rank() > best.rank() {
+			best = r
+		}
+	}
+	return best
+}
+
+// TokenRole — эффективная роль токена: scopes, но не больше роли владельца
+func TokenRole(owner Role, scopes []string) Role {
+	r := ScopesRole(scopes)
+	if owner.rank() < r.rank() {
+		return owner
+	}
+	return r
+}
+
+// NewAPIToken — секрет API-токена (показывается пользователю один раз)
+func NewAPIToken() string {
+	return TokenPrefix + NewToken()
+}
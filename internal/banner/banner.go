@@ -2,8 +2,8 @@ package banner
 
 import (
 	"bufio"
-	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,7 +11,14 @@ import (
 )
 
 func GrabBanner(ip string, port uint16, cfg *config.Config) (string, string, error) {
-	addr := fmt.Sprintf("%s:%d", ip, port)
+	start := time.Now()
+	banner, service, err := grabBanner(ip, port, cfg)
+	observeGrab(time.Since(start), err)
+	return banner, service, err
+}
+
+func grabBanner(ip string, port uint16, cfg *config.Config) (string, string, error) {
+	addr := net.JoinHostPort(ip, strconv.Itoa(int(port)))
 
 	dialer := net.Dialer{
 		Timeout: cfg.ConnectTimeout(),
@@ -27,6 +34,15 @@ func GrabBanner(ip string, port uint16, cfg *config.Config) (string, string, err
 		return "", "", err
 	}
 
+	// SMB молчит, пока клиент не начнёт negotiate — отдельная проба
+	if port == 445 {
+		bnr, err := probeSMB(conn)
+		if err != nil && !isTimeout(err) {
+			return "", "", err
+		}
+		return bnr, "smb", nil
+	}
+
 	// Простая логика: для HTTP отправим запрос, для других — просто читаем
 	if port == 80 || port == 8080 || port == 8000 || port == 443 {
 		// даже для 443 это не идеальный вариант, но для баннера часто хватает
@@ -62,6 +78,12 @@ func detectService(port uint16, banner string) string {
 		return "ftp"
 	case 25:
 		return "smtp"
+	case 135:
+		return "msrpc"
+	case 445:
+		return "smb"
+	case 3389:
+		return "rdp"
 	case 80, 8080, 8000:
 		if strings.Contains(lower, "http") {
 			return "http"
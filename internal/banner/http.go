@@ -0,0 +1,44 @@
+package banner
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// HTTPInfo — то, что удаётся достать из HTTP-баннера (ответ на HEAD/GET)
+type HTTPInfo struct {
+	Status  int               `json:"status"`
+	Server  string            `json:"server,omitempty"`
+	Title   string            `json:"title,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+var titleRe = regexp.MustCompile(`(?is)<title[^>]*>([^<]*)`)
+
+// ParseHTTP — разбор сохранённого баннера; nil, если это не HTTP-ответ
+func ParseHTTP(b string) *HTTPInfo {
+	if !strings.HasPrefix(b, "HTTP/") {
+		return nil
+	}
+	head, body, _ := strings.Cut(strings.ReplaceAll(b, "\r\n", "\n"), "\n\n")
+	lines := strings.Split(head, "\n")
+
+	info := &HTTPInfo{Headers: map[string]string{}}
+	if f := strings.Fields(lines[0]); len(f) >= 2 {
+		info.Status, _ = strconv.Atoi(f[1])
+	}
+	for _, l := range lines[1:] {
+		k, v, ok := strings.Cut(l, ":")
+		if !ok {
+			continue
+		}
+		info.Headers[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+	}
+	info.Server = info.Headers["server"]
+	if m := titleRe.FindStringSubmatch(body); m != nil {
+		info.Title = strings.TrimSpace(html.UnescapeString(m[1]))
+	}
+	return info
+}
@@ -0,0 +1,42 @@
+package banner
+
+import (
+	"errors"
+	"syscall"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/metrics"
+)
+
+var (
+	grabDuration = metrics.NewHistogramVec(
+		"portscanner_banner_grab_duration_seconds",
+		"Banner grab latency, including connect and read.",
+		[]float64{.01, .05, .1, .25, .5, 1, 2, 3, 5, 10},
+	)
+	grabErrors = metrics.NewCounterVec(
+		"portscanner_banner_grab_errors_total",
+		"Failed banner grabs by reason (timeout, refused, reset, other).",
+		"reason",
+	)
+)
+
+func observeGrab(d time.Duration, err error) {
+	grabDuration.Observe(d.Seconds())
+	if err != nil {
+		grabErrors.Inc(grabErrorReason(err))
+	}
+}
+
+func grabErrorReason(err error) string {
+	switch {
+	case isTimeout(err):
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "reset"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,63 @@
+package banner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// probeSMB — отправляет SMB2 NEGOTIATE и возвращает выбранный сервером диалект
+// в виде баннера "SMB2 dialect=3.0.2". Диалект 3.1.1 не предлагаем: он требует
+// negotiate contexts, а для OS-эвристики хватает 2.0.2..3.0.2.
+func probeSMB(conn net.Conn) (string, error) {
+	dialects := []uint16{0x0202, 0x0210, 0x0300, 0x0302}
+
+	// SMB2 header (64 байта)
+	hdr := make([]byte, 64)
+	copy(hdr[0:4], []byte{0xFE, 'S', 'M', 'B'})
+	binary.LittleEndian.PutUint16(hdr[4:], 64) // StructureSize
+	binary.LittleEndian.PutUint16(hdr[12:], 0) // Command: NEGOTIATE
+	binary.LittleEndian.PutUint16(hdr[14:], 1) // CreditRequest
+
+	// NEGOTIATE request (36 байт + диалекты)
+	req := make([]byte, 36+2*len(dialects))
+	binary.LittleEndian.PutUint16(req[0:], 36)
+	binary.LittleEndian.PutUint16(req[2:], uint16(len(dialects)))
+	binary.LittleEndian.PutUint16(req[4:], 1) // SecurityMode: signing enabled
+	for i, d := range dialects {
+		binary.LittleEndian.PutUint16(req[36+2*i:], d)
+	}
+
+	msg := append(hdr, req...)
+
+	// NetBIOS session header: 0x00 + 24-bit length
+	pkt := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(pkt, uint32(len(msg)))
+	pkt = append(pkt, msg...)
+
+	if _, err := conn.Write(pkt); err != nil {
+		return "", err
+	}
+
+	var nb [4]byte
+	if _, err := io.ReadFull(conn, nb[:]); err != nil {
+		return "", err
+	}
+	n := binary.BigEndian.Uint32(nb[:]) & 0x00FFFFFF
+	if n < 64+6 || n > 64*1024 {
+		return "", fmt.Errorf("smb: unexpected response length %d", n)
+	}
+
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return "", err
+	}
+	if resp[0] != 0xFE || string(resp[1:4]) != "SMB" {
+		return "", fmt.Errorf("smb: not an SMB2 response")
+	}
+
+	// NEGOTIATE response: StructureSize(2) SecurityMode(2) DialectRevision(2)
+	d := binary.LittleEndian.Uint16(resp[64+4:])
+	return fmt.Sprintf("SMB2 dialect=%d.%d.%d", d>>8, (d>>4)&0xF, d&0xF), nil
+}
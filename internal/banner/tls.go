@@ -0,0 +1,62 @@
+package banner
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/config"
+	"github.com/L1nMay/portscanner/internal/model"
+)
+
+// tlsPorts — порты, где TLS начинается сразу после подключения
+var tlsPorts = map[uint16]bool{
+	443:  true,
+	465:  true,
+	636:  true,
+	993:  true,
+	995:  true,
+	8443: true,
+	9443: true,
+}
+
+// WantsCert — снимать ли сертификат: известный TLS-порт или сервис,
+// опознанный по баннеру как TLS
+func WantsCert(port uint16, service string) bool {
+	return tlsPorts[port] || service == "tls" || service == "https"
+}
+
+// GrabCert — сертификат порта. Цепочка не проверяется: нужен сам
+// сертификат (срок, субъект), в том числе самоподписанный или просроченный.
+func GrabCert(ip string, port uint16, cfg *config.Config) (*model.Cert, error) {
+	dialer := &net.Dialer{Timeout: cfg.ConnectTimeout()}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(ip, strconv.Itoa(int(port))), &tls.Config{
+		InsecureSkipVerify: true, // доверие к цепочке здесь не важно
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("no peer certificate")
+	}
+	leaf := certs[0]
+	return &model.Cert{
+		Subject:  certName(leaf.Subject.CommonName, leaf.Subject.String()),
+		Issuer:   certName(leaf.Issuer.CommonName, leaf.Issuer.String()),
+		DNSNames: leaf.DNSNames,
+		NotAfter: leaf.NotAfter.UTC(),
+	}, nil
+}
+
+// certName — CN, а без него полное имя
+func certName(cn, full string) string {
+	if cn = strings.TrimSpace(cn); cn != "" {
+		return cn
+	}
+	return full
+}
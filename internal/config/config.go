@@ -3,8 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/L1nMay/portscanner/internal/alerting"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/tracing"
 	"gopkg.in/yaml.v3"
 )
 
@@ -12,12 +16,117 @@ type TelegramConfig struct {
 	Enabled  bool   `yaml:"enabled"`
 	BotToken string `yaml:"bot_token"`
 	ChatID   string `yaml:"chat_id"`
+	APIURL   string `yaml:"api_url"` // по умолчанию https://api.telegram.org
+
+	// интерактивные команды (/scan, /status, ...) через long polling getUpdates
+	Commands        bool    `yaml:"commands"`
+	AuthorizedChats []int64 `yaml:"authorized_chats"` // пусто — только chat_id
+	PollTimeoutSec  int     `yaml:"poll_timeout_seconds"`
 }
 
 type WebUIConfig struct {
-	Enabled   bool   `yaml:"enabled"`
-	Listen    string `yaml:"listen"`
+	Enabled bool   `yaml:"enabled"`
+	Listen  string `yaml:"listen"`
+	// AuthToken — устаревший общий bearer-токен, даёт права admin
 	AuthToken string `yaml:"auth_token"`
+
+	// первый администратор создаётся, если таблица users пуста;
+	// без пароля генерируется случайный и пишется в лог один раз
+	AdminUser     string `yaml:"admin_user"`
+	AdminPassword string `yaml:"admin_password"`
+
+	SessionTTLHours int  `yaml:"session_ttl_hours"`
+	SecureCookies   bool `yaml:"secure_cookies"` // Secure-флаг cookie (за HTTPS)
+
+	// MetricsToken — bearer для /metrics; пусто — эндпоинт открыт
+	MetricsToken string `yaml:"metrics_token"`
+}
+
+type NmapConfig struct {
+	// OSDetection — запуск nmap с -O и разбором XML (нужен root/CAP_NET_RAW)
+	OSDetection bool `yaml:"os_detection"`
+}
+
+type AlertsConfig struct {
+	// правила маршрутизации событий; если правил нет — всё уходит во все каналы
+	Rules []alerting.Rule `yaml:"rules"`
+	// куда идут события, не подошедшие ни под одно правило; пусто — никуда
+	// (событие только пишется в лог)
+	UnmatchedChannels []string `yaml:"unmatched_channels"`
+}
+
+// ChannelConfig — канал уведомлений; какие поля нужны, зависит от Type
+type ChannelConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // telegram | webhook | slack | matrix | discord | email
+
+	// telegram
+	BotToken string `yaml:"bot_token"`
+	ChatID   string `yaml:"chat_id"`
+	APIURL   string `yaml:"api_url"` // по умолчанию https://api.telegram.org
+
+	// webhook / slack / discord
+	URL     string            `yaml:"url"`
+	Secret  string            `yaml:"secret"` // webhook: HMAC-SHA256 подпись тела
+	Headers map[string]string `yaml:"headers"`
+
+	// matrix
+	Homeserver  string `yaml:"homeserver"`
+	AccessToken string `yaml:"access_token"`
+	RoomID      string `yaml:"room_id"`
+
+	// email
+	SMTPHost string   `yaml:"smtp_host"`
+	SMTPPort int      `yaml:"smtp_port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	StartTLS bool     `yaml:"starttls"`
+
+	AttachReport bool `yaml:"attach_report"` // HTML-отчёт вложением к регулярной сводке
+
+	TimeoutSec int `yaml:"timeout_seconds"`
+}
+
+// PlanLimitsConfig — пороги, сверх которых план скана выдаёт предупреждения
+// (скан не блокируется); 0 — значение по умолчанию, отрицательное — без проверки
+type PlanLimitsConfig struct {
+	MaxAddresses   int64 `yaml:"max_addresses"`
+	MaxProbes      int64 `yaml:"max_probes"`
+	MaxDurationSec int   `yaml:"max_duration_seconds"`
+	MaxRate        int   `yaml:"max_rate"`
+}
+
+type NotificationsConfig struct {
+	Channels []ChannelConfig `yaml:"channels"`
+
+	// ретраи доставки: пауза base * 2^(attempt-1), но не больше max;
+	// после max_attempts неудач событие уходит в dead-letter
+	MaxAttempts      int `yaml:"max_attempts"`
+	RetryBaseSeconds int `yaml:"retry_base_seconds"`
+	RetryMaxSeconds  int `yaml:"retry_max_seconds"`
+
+	Digest DigestConfig `yaml:"digest"`
+}
+
+// DigestConfig — сводки вместо потока отдельных уведомлений
+type DigestConfig struct {
+	PerScan   bool `yaml:"per_scan"`   // сводка после каждого скана
+	SendEmpty bool `yaml:"send_empty"` // слать сводку скана, даже если ничего не изменилось
+
+	Schedule string `yaml:"schedule"` // "" | daily | weekly
+	At       string `yaml:"at"`       // "09:00", локальное время
+	Weekday  string `yaml:"weekday"`  // для weekly: mon..sun
+
+	Channels []string `yaml:"channels"` // пусто — все каналы
+	Top      int      `yaml:"top"`      // строк в топах и выборках
+
+	Report bool `yaml:"report"` // генерировать HTML-отчёт к daily/weekly сводке
+
+	// типы событий, которые без правил alerts не рассылаются по одному
+	// (по умолчанию при per_scan — new_port: они и так попадут в сводку)
+	Suppress []string `yaml:"suppress"`
 }
 
 type DatabaseConfig struct {
@@ -36,12 +145,27 @@ type Config struct {
 	ConnectTimeoutSec int `yaml:"connect_timeout_seconds"`
 	ReadTimeoutSec    int `yaml:"read_timeout_seconds"`
 	BannerMaxBytes    int `yaml:"banner_max_bytes"`
+	// BannerConcurrency — сколько портов хоста опрашивается одновременно
+	BannerConcurrency int `yaml:"banner_concurrency"`
+
+	// ScanLogMaxBytes — лимит журнала одного скана (scan_logs), байт
+	ScanLogMaxBytes int `yaml:"scan_log_max_bytes"`
+
+	Nmap NmapConfig `yaml:"nmap"`
+
+	PlanLimits PlanLimitsConfig `yaml:"plan_limits"`
 
 	Database DatabaseConfig `yaml:"database"`
 	Telegram TelegramConfig `yaml:"telegram"`
+	Alerts   AlertsConfig   `yaml:"alerts"`
+
+	Notifications NotificationsConfig `yaml:"notifications"`
 
 	ScanName string `yaml:"scan_name"`
 
+	Log     logger.Config  `yaml:"log"`
+	Tracing tracing.Config `yaml:"tracing"`
+
 	WebUI       WebUIConfig `yaml:"webui"`
 	AutoTargets bool        `yaml:"auto_targets"`
 	UserDefined bool        `yaml:"-"`
@@ -62,6 +186,18 @@ func LoadConfig(path string) (*Config, error) {
 	if v := os.Getenv("DATABASE_DSN"); v != "" {
 		cfg.Database.DSN = v
 	}
+	if v := os.Getenv("PORTSCANNER_ADMIN_PASSWORD"); v != "" {
+		cfg.WebUI.AdminPassword = v
+	}
+	if v := os.Getenv("PORTSCANNER_LOG_LEVEL"); v != "" {
+		cfg.Log.Level = v
+	}
+	if v := os.Getenv("PORTSCANNER_LOG_FORMAT"); v != "" {
+		cfg.Log.Format = v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		cfg.Tracing.Endpoint = v
+	}
 
 	// defaults
 	if cfg.MasscanPath == "" {
@@ -79,12 +215,74 @@ func LoadConfig(path string) (*Config, error) {
 	if cfg.BannerMaxBytes <= 0 {
 		cfg.BannerMaxBytes = 1024
 	}
+	if cfg.BannerConcurrency <= 0 {
+		cfg.BannerConcurrency = 1
+	}
+	if cfg.ScanLogMaxBytes <= 0 {
+		cfg.ScanLogMaxBytes = 1 << 20
+	}
+	if cfg.PlanLimits.MaxAddresses == 0 {
+		cfg.PlanLimits.MaxAddresses = 65536
+	}
+	if cfg.PlanLimits.MaxProbes == 0 {
+		cfg.PlanLimits.MaxProbes = 10_000_000
+	}
+	if cfg.PlanLimits.MaxDurationSec == 0 {
+		cfg.PlanLimits.MaxDurationSec = 3600
+	}
+	if cfg.PlanLimits.MaxRate == 0 {
+		cfg.PlanLimits.MaxRate = 10000
+	}
 	if cfg.ScanName == "" {
 		cfg.ScanName = "Port scanner"
 	}
 	if cfg.WebUI.Listen == "" {
 		cfg.WebUI.Listen = "127.0.0.1:8088"
 	}
+	if cfg.WebUI.AdminUser == "" {
+		cfg.WebUI.AdminUser = "admin"
+	}
+	if cfg.WebUI.SessionTTLHours <= 0 {
+		cfg.WebUI.SessionTTLHours = 168
+	}
+	if cfg.Telegram.PollTimeoutSec <= 0 {
+		cfg.Telegram.PollTimeoutSec = 30
+	}
+	if cfg.Notifications.MaxAttempts <= 0 {
+		cfg.Notifications.MaxAttempts = 8
+	}
+	if cfg.Notifications.RetryBaseSeconds <= 0 {
+		cfg.Notifications.RetryBaseSeconds = 10
+	}
+	if cfg.Notifications.RetryMaxSeconds <= 0 {
+		cfg.Notifications.RetryMaxSeconds = 3600
+	}
+
+	if err := cfg.normalizeChannels(); err != nil {
+		return nil, err
+	}
+	if err := cfg.normalizeDigest(); err != nil {
+		return nil, err
+	}
+
+	for i := range cfg.Alerts.Rules {
+		r := &cfg.Alerts.Rules[i]
+		r.Enabled = !r.Disabled
+		r.Source = "config"
+		if err := r.Compile(); err != nil {
+			return nil, fmt.Errorf("alerts: %w", err)
+		}
+		for _, ch := range r.Channels {
+			if !cfg.HasChannel(ch) {
+				return nil, fmt.Errorf("alerts: rule %s: unknown channel %q", r.Name, ch)
+			}
+		}
+	}
+	for _, ch := range cfg.Alerts.UnmatchedChannels {
+		if !cfg.HasChannel(ch) {
+			return nil, fmt.Errorf("alerts: unmatched_channels: unknown channel %q", ch)
+		}
+	}
 
 	if cfg.Database.DSN == "" {
 		return nil, fmt.Errorf("database.dsn is empty (set in config.yaml or via DATABASE_DSN env)")
@@ -93,6 +291,105 @@ func LoadConfig(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// normalizeChannels — проверка списка каналов; старая секция telegram
+// превращается в канал "telegram", если такого ещё нет
+func (c *Config) normalizeChannels() error {
+	if c.Telegram.Enabled && !c.HasChannel("telegram") {
+		c.Notifications.Channels = append(c.Notifications.Channels, ChannelConfig{
+			Name:     "telegram",
+			Type:     "telegram",
+			BotToken: c.Telegram.BotToken,
+			ChatID:   c.Telegram.ChatID,
+			APIURL:   c.Telegram.APIURL,
+		})
+	}
+
+	seen := map[string]struct{}{}
+	for i := range c.Notifications.Channels {
+		ch := &c.Notifications.Channels[i]
+		ch.Name = strings.TrimSpace(ch.Name)
+		ch.Type = strings.ToLower(strings.TrimSpace(ch.Type))
+		if ch.Name == "" {
+			return fmt.Errorf("notifications: channel #%d: name is required", i+1)
+		}
+		if _, dup := seen[ch.Name]; dup {
+			return fmt.Errorf("notifications: duplicate channel name %q", ch.Name)
+		}
+		seen[ch.Name] = struct{}{}
+		if ch.TimeoutSec <= 0 {
+			ch.TimeoutSec = 10
+		}
+	}
+	return nil
+}
+
+func (c *Config) normalizeDigest() error {
+	d := &c.Notifications.Digest
+
+	d.Schedule = strings.ToLower(strings.TrimSpace(d.Schedule))
+	switch d.Schedule {
+	case "", "daily", "weekly":
+	default:
+		return fmt.Errorf("notifications.digest: invalid schedule %q (daily|weekly)", d.Schedule)
+	}
+	if d.At == "" {
+		d.At = "09:00"
+	}
+	if _, err := time.Parse("15:04", d.At); err != nil {
+		return fmt.Errorf("notifications.digest: invalid at %q (HH:MM)", d.At)
+	}
+	if d.Weekday == "" {
+		d.Weekday = "mon"
+	}
+	if _, ok := ParseWeekday(d.Weekday); !ok {
+		return fmt.Errorf("notifications.digest: invalid weekday %q", d.Weekday)
+	}
+	if d.Top <= 0 {
+		d.Top = 5
+	}
+	if d.Suppress == nil && d.PerScan {
+		d.Suppress = []string{"new_port"}
+	}
+	for _, ch := range d.Channels {
+		if !c.HasChannel(ch) {
+			return fmt.Errorf("notifications.digest: unknown channel %q", ch)
+		}
+	}
+	return nil
+}
+
+// ParseWeekday — "mon", "monday", "Mon" -> time.Monday
+func ParseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) > 3 {
+		s = s[:3]
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.ToLower(d.String()[:3]) == s {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// HasChannel — есть ли канал уведомлений с таким именем
+func (c *Config) HasChannel(name string) bool {
+	for _, ch := range c.Notifications.Channels {
+		if ch.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ChannelConfig) Timeout() time.Duration {
+	return time.Duration(c.TimeoutSec) * time.Second
+}
+
+func (c *WebUIConfig) SessionTTL() time.Duration {
+	return time.Duration(c.SessionTTLHours) * time.Hour
+}
+
 func (c *Config) ConnectTimeout() time.Duration {
 	return time.Duration(c.ConnectTimeoutSec) * time.Second
 }
@@ -0,0 +1,116 @@
+// Package export — потоковая выгрузка находок: CSV, NDJSON и XML в формате
+// nmap (-oX), который понимают инструменты, умеющие читать вывод nmap.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// Formats — поддерживаемые форматы
+var Formats = []string{"csv", "ndjson", "xml"}
+
+// Writer — построчная запись; Close дописывает хвост формата
+type Writer interface {
+	Write(r storage.ResultRow) error
+	Close() error
+}
+
+// NewWriter — writer для формата; args попадает в nmaprun/@args (только xml)
+func NewWriter(w io.Writer, format, args string) (Writer, error) {
+	switch strings.ToLower(format) {
+	case "", "csv":
+		return newCSV(w)
+	case "ndjson", "jsonl":
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case "xml", "nmap":
+		return newNmapXML(w, args, time.Now())
+	default:
+		_, _, err := ContentType(format)
+		return nil, err
+	}
+}
+
+// ContentType — MIME-тип и расширение файла для формата
+func ContentType(format string) (string, string, error) {
+	switch strings.ToLower(format) {
+	case "", "csv":
+		return "text/csv; charset=utf-8", "csv", nil
+	case "ndjson", "jsonl":
+		return "application/x-ndjson", "ndjson", nil
+	case "xml", "nmap":
+		return "application/xml; charset=utf-8", "xml", nil
+	default:
+		return "", "", fmt.Errorf("unknown format %q (%s)", format, strings.Join(Formats, "|"))
+	}
+}
+
+// SortFor — порядок строк, который нужен формату: в XML строки одного
+// хоста должны идти подряд
+func SortFor(format, sort string) string {
+	switch strings.ToLower(format) {
+	case "xml", "nmap":
+		return "ip"
+	}
+	return sort
+}
+
+/* ========================= CSV ========================= */
+
+type csvWriter struct {
+	cw *csv.Writer
+}
+
+var csvHeader = []string{
+	"ip", "port", "proto", "state", "service", "banner", "first_seen", "last_seen",
+	"os_name", "os_family", "tags", "groups", "compliance",
+}
+
+func newCSV(w io.Writer) (*csvWriter, error) {
+	c := &csvWriter{cw: csv.NewWriter(w)}
+	if err := c.cw.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(r storage.ResultRow) error {
+	return c.cw.Write(CSVRow(
+		r.IP, strconv.Itoa(r.Port), r.Proto, r.State, r.Service, r.Banner,
+		r.FirstSeen.UTC().Format(time.RFC3339), r.LastSeen.UTC().Format(time.RFC3339),
+		r.OSName, r.OSFamily, strings.Join(r.Tags, ","), strings.Join(r.Groups, ","), r.Compliance,
+	))
+}
+
+func (c *csvWriter) Close() error {
+	c.cw.Flush()
+	return c.cw.Error()
+}
+
+// CSVRow — ячейки, безопасные для Excel/LibreOffice: значение, начинающееся
+// с = + - @ (или таба/CR), таблица исполнит как формулу, поэтому перед ним
+// ставится апостроф. Баннеры, имена и параметры запросов приходят извне.
+func CSVRow(cells ...string) []string {
+	for i, v := range cells {
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			cells[i] = "'" + v
+		}
+	}
+	return cells
+}
+
+/* ========================= NDJSON ========================= */
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(r storage.ResultRow) error { return n.enc.Encode(r) }
+func (n *ndjsonWriter) Close() error                    { return nil }
@@ -0,0 +1,188 @@
+package export
+
+import (
+	"encoding/xml"
+	"io"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// Структура повторяет nmap -oX (xmloutputversion 1.05) в объёме, который
+// читают типовые парсеры: host/status/address/ports/port/state/service/os.
+
+type xmlHost struct {
+	XMLName   xml.Name    `xml:"host"`
+	StartTime int64       `xml:"starttime,attr"`
+	EndTime   int64       `xml:"endtime,attr"`
+	Status    xmlStatus   `xml:"status"`
+	Address   xmlAddress  `xml:"address"`
+	Hostnames struct{}    `xml:"hostnames"`
+	Ports     []xmlPort   `xml:"ports>port"`
+	OS        *xmlOSMatch `xml:"os>osmatch,omitempty"`
+}
+
+type xmlStatus struct {
+	State  string `xml:"state,attr"`
+	Reason string `xml:"reason,attr"`
+}
+
+type xmlAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type xmlPort struct {
+	Protocol string `xml:"protocol,attr"`
+	PortID   int    `xml:"portid,attr"`
+	State    struct {
+		State     string `xml:"state,attr"`
+		Reason    string `xml:"reason,attr"`
+		ReasonTTL int    `xml:"reason_ttl,attr"`
+	} `xml:"state"`
+	Service struct {
+		Name   string `xml:"name,attr"`
+		Method string `xml:"method,attr"`
+		Conf   int    `xml:"conf,attr"`
+	} `xml:"service"`
+	Script *xmlScript `xml:"script,omitempty"`
+}
+
+type xmlScript struct {
+	ID     string `xml:"id,attr"`
+	Output string `xml:"output,attr"`
+}
+
+type xmlOSMatch struct {
+	Name     string `xml:"name,attr"`
+	Accuracy int    `xml:"accuracy,attr"`
+	Class    struct {
+		Family   string `xml:"osfamily,attr"`
+		Accuracy int    `xml:"accuracy,attr"`
+	} `xml:"osclass"`
+}
+
+// nmapXMLWriter — строки приходят отсортированными по ip; хост копится,
+// пока не сменится адрес, и пишется целиком
+type nmapXMLWriter struct {
+	w     io.Writer
+	enc   *xml.Encoder
+	start time.Time
+	host  *xmlHost
+	up    int
+}
+
+func newNmapXML(w io.Writer, args string, now time.Time) (*nmapXMLWriter, error) {
+	x := &nmapXMLWriter{w: w, enc: xml.NewEncoder(w), start: now}
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE nmaprun>\n"); err != nil {
+		return nil, err
+	}
+	x.enc.Indent("", " ")
+	err := x.enc.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "nmaprun"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "scanner"}, Value: "nmap"},
+			{Name: xml.Name{Local: "args"}, Value: args},
+			{Name: xml.Name{Local: "start"}, Value: strconv.FormatInt(now.Unix(), 10)},
+			{Name: xml.Name{Local: "startstr"}, Value: now.Format(time.ANSIC)},
+			{Name: xml.Name{Local: "version"}, Value: "7.94"},
+			{Name: xml.Name{Local: "xmloutputversion"}, Value: "1.05"},
+		},
+	})
+	return x, err
+}
+
+func (x *nmapXMLWriter) Write(r storage.ResultRow) error {
+	if x.host != nil && x.host.Address.Addr != r.IP {
+		if err := x.flush(); err != nil {
+			return err
+		}
+	}
+	if x.host == nil {
+		x.host = &xmlHost{
+			StartTime: r.FirstSeen.Unix(),
+			EndTime:   r.LastSeen.Unix(),
+			Status:    xmlStatus{State: "up", Reason: "user-set"},
+			Address:   xmlAddress{Addr: r.IP, AddrType: addrType(r.IP)},
+		}
+		if r.OSName != "" {
+			x.host.OS = &xmlOSMatch{Name: r.OSName, Accuracy: r.OSConfidence}
+			x.host.OS.Class.Family = r.OSFamily
+			x.host.OS.Class.Accuracy = r.OSConfidence
+		}
+	}
+	if t := r.LastSeen.Unix(); t > x.host.EndTime {
+		x.host.EndTime = t
+	}
+
+	var p xmlPort
+	p.Protocol, p.PortID = r.Proto, r.Port
+	p.State.State, p.State.Reason = r.State, "syn-ack"
+	if r.State != "open" {
+		p.State.Reason = "no-response"
+	}
+	p.Service.Name, p.Service.Method, p.Service.Conf = r.Service, "table", 3
+	if r.Banner != "" {
+		p.Script = &xmlScript{ID: "banner", Output: r.Banner}
+	}
+	x.host.Ports = append(x.host.Ports, p)
+	return nil
+}
+
+func (x *nmapXMLWriter) flush() error {
+	err := x.enc.Encode(x.host)
+	x.host = nil
+	x.up++
+	return err
+}
+
+func (x *nmapXMLWriter) Close() error {
+	if x.host != nil {
+		if err := x.flush(); err != nil {
+			return err
+		}
+	}
+
+	end := time.Now()
+	up := strconv.Itoa(x.up)
+	run := struct {
+		XMLName  xml.Name `xml:"runstats"`
+		Finished struct {
+			Time    int64  `xml:"time,attr"`
+			TimeStr string `xml:"timestr,attr"`
+			Elapsed string `xml:"elapsed,attr"`
+			Exit    string `xml:"exit,attr"`
+		} `xml:"finished"`
+		Hosts struct {
+			Up    string `xml:"up,attr"`
+			Down  string `xml:"down,attr"`
+			Total string `xml:"total,attr"`
+		} `xml:"hosts"`
+	}{}
+	run.Finished.Time = end.Unix()
+	run.Finished.TimeStr = end.Format(time.ANSIC)
+	run.Finished.Elapsed = strconv.FormatFloat(end.Sub(x.start).Seconds(), 'f', 2, 64)
+	run.Finished.Exit = "success"
+	run.Hosts.Up, run.Hosts.Down, run.Hosts.Total = up, "0", up
+
+	if err := x.enc.Encode(run); err != nil {
+		return err
+	}
+	if err := x.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "nmaprun"}}); err != nil {
+		return err
+	}
+	if err := x.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(x.w, "\n")
+	return err
+}
+
+func addrType(ip string) string {
+	if a, err := netip.ParseAddr(ip); err == nil && a.Is6() && !a.Is4In6() {
+		return "ipv6"
+	}
+	return "ipv4"
+}
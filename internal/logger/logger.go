@@ -1,26 +1,301 @@
+// Package logger — структурированные логи на log/slog: уровень, текст или
+// JSON, атрибуты из context (scan_id, engine, target, request_id).
 package logger
 
 import (
-	"log"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"time"
 )
 
+// Config — секция log в config.yaml
+type Config struct {
+	Level  string `yaml:"level"`  // debug | info | warn | error (по умолчанию info)
+	Format string `yaml:"format"` // text | json (по умолчанию text)
+}
+
 var (
-	stdLogger *log.Logger
+	level            = new(slog.LevelVar)
+	output io.Writer = os.Stdout
+	std    *slog.Logger
 )
 
 func init() {
-	stdLogger = log.New(os.Stdout, "[portscanner] ", log.LstdFlags|log.Lshortfile)
+	std = slog.New(&ctxHandler{next: newHandler("text")})
+}
+
+// Setup — применяет уровень и формат; вызывается после загрузки конфига
+func Setup(cfg Config) error {
+	lvl, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	format := strings.ToLower(strings.TrimSpace(cfg.Format))
+	switch format {
+	case "":
+		format = "text"
+	case "text", "json":
+	default:
+		return fmt.Errorf("unknown log format %q (want text or json)", cfg.Format)
+	}
+
+	level.Set(lvl)
+	std = slog.New(&ctxHandler{next: newHandler(format)})
+	slog.SetDefault(std)
+	return nil
+}
+
+// ParseLevel — debug, info, warn (warning), error; пусто — info
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "debug":
+		return slog.LevelDebug, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("unknown log level %q (want debug, info, warn or error)", s)
+}
+
+// LevelFatal — запись перед os.Exit(1)
+const LevelFatal = slog.LevelError + 4
+
+func newHandler(format string) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level, AddSource: true, ReplaceAttr: replaceAttr}
+	if format == "json" {
+		return slog.NewJSONHandler(output, opts)
+	}
+	return slog.NewTextHandler(output, opts)
+}
+
+// replaceAttr — FATAL вместо ERROR+4 и dir/file.go:123 вместо полного пути
+func replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey {
+		if l, ok := a.Value.Any().(slog.Level); ok && l >= LevelFatal {
+			return slog.String(slog.LevelKey, "FATAL")
+		}
+		return a
+	}
+	if a.Key != slog.SourceKey {
+		return a
+	}
+	if src, ok := a.Value.Any().(*slog.Source); ok {
+		file := src.File
+		if i := strings.LastIndexByte(file, '/'); i >= 0 {
+			if j := strings.LastIndexByte(file[:i], '/'); j >= 0 {
+				file = file[j+1:]
+			}
+		}
+		return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", file, src.Line))
+	}
+	return a
+}
+
+// DebugEnabled — включён ли debug (чтобы не собирать дорогие сообщения зря)
+func DebugEnabled() bool {
+	return level.Level() <= slog.LevelDebug
+}
+
+/* ========================= CONTEXT ========================= */
+
+type (
+	attrsKey struct{}
+	sinkKey  struct{}
+)
+
+// Sink — получает копию записей уровня Info и выше независимо от настроенного
+// уровня (журнал скана); атрибуты ctx в записи не входят
+type Sink func(r slog.Record)
+
+// WithSink — context, записи которого дополнительно уходят в sink
+func WithSink(ctx context.Context, sink Sink) context.Context {
+	return context.WithValue(ctx, sinkKey{}, sink)
+}
+
+func sinkFrom(ctx context.Context) Sink {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(sinkKey{}).(Sink)
+	return s
+}
+
+// With — context с дополнительными атрибутами логов ("scan_id", id, ...);
+// повторный ключ заменяет прежнее значение
+func With(ctx context.Context, args ...any) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rec := slog.Record{}
+	rec.Add(args...)
+
+	prev := attrs(ctx)
+	merged := make([]slog.Attr, 0, len(prev)+rec.NumAttrs())
+	rec.Attrs(func(a slog.Attr) bool {
+		merged = append(merged, a)
+		return true
+	})
+	for _, a := range prev {
+		if !hasKey(merged, a.Key) {
+			merged = append(merged, a)
+		}
+	}
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+func attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	list, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return list
+}
+
+func hasKey(list []slog.Attr, key string) bool {
+	for _, a := range list {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
 }
 
-func Infof(format string, v ...interface{}) {
-	stdLogger.Printf("[INFO] "+format, v...)
+// ctxHandler — добавляет к записи атрибуты из context
+type ctxHandler struct {
+	next slog.Handler
 }
 
-func Errorf(format string, v ...interface{}) {
-	stdLogger.Printf("[ERROR] "+format, v...)
+func (h *ctxHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	if l >= slog.LevelInfo && sinkFrom(ctx) != nil {
+		return true
+	}
+	return h.next.Enabled(ctx, l)
 }
 
-func Fatalf(format string, v ...interface{}) {
-	stdLogger.Fatalf("[FATAL] "+format, v...)
+func (h *ctxHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sink := sinkFrom(ctx); sink != nil && r.Level >= slog.LevelInfo {
+		sink(r.Clone())
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	if list := attrs(ctx); len(list) > 0 {
+		r = r.Clone()
+		r.AddAttrs(list...)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *ctxHandler) WithAttrs(as []slog.Attr) slog.Handler {
+	return &ctxHandler{next: h.next.WithAttrs(as)}
+}
+
+func (h *ctxHandler) WithGroup(name string) slog.Handler {
+	return &ctxHandler{next: h.next.WithGroup(name)}
+}
+
+/* ========================= API ========================= */
+
+// Debug/Info/Warn/Error — сообщение с парами ключ-значение и атрибутами ctx
+func Debug(ctx context.Context, msg string, args ...any) { write(ctx, slog.LevelDebug, msg, args...) }
+func Info(ctx context.Context, msg string, args ...any)  { write(ctx, slog.LevelInfo, msg, args...) }
+func Warn(ctx context.Context, msg string, args ...any)  { write(ctx, slog.LevelWarn, msg, args...) }
+func Error(ctx context.Context, msg string, args ...any) { write(ctx, slog.LevelError, msg, args...) }
+
+// Infof/Errorf/Fatalf — прежний printf-интерфейс, без атрибутов
+func Debugf(format string, v ...any) { writef(slog.LevelDebug, format, v...) }
+func Infof(format string, v ...any)  { writef(slog.LevelInfo, format, v...) }
+func Warnf(format string, v ...any)  { writef(slog.LevelWarn, format, v...) }
+func Errorf(format string, v ...any) { writef(slog.LevelError, format, v...) }
+
+func Fatalf(format string, v ...any) {
+	writef(LevelFatal, format, v...)
+	os.Exit(1)
+}
+
+// Fatal — как Error, затем выход с кодом 1
+func Fatal(ctx context.Context, msg string, args ...any) {
+	write(ctx, LevelFatal, msg, args...)
+	os.Exit(1)
+}
+
+func writef(l slog.Level, format string, v ...any) {
+	if !std.Enabled(context.Background(), l) {
+		return
+	}
+	emit(context.Background(), l, callerPC(), fmt.Sprintf(format, v...))
+}
+
+func write(ctx context.Context, l slog.Level, msg string, args ...any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !std.Enabled(ctx, l) {
+		return
+	}
+	emit(ctx, l, callerPC(), msg, args...)
+}
+
+// callerPC — код, вызвавший Info/Infof (а не этот пакет)
+func callerPC() uintptr {
+	var pcs [1]uintptr
+	runtime.Callers(4, pcs[:])
+	return pcs[0]
+}
+
+func emit(ctx context.Context, l slog.Level, pc uintptr, msg string, args ...any) {
+	r := slog.NewRecord(time.Now(), l, msg, pc)
+	r.Add(args...)
+	_ = std.Handler().Handle(ctx, r)
+}
+
+/* ========================= WRITER ========================= */
+
+// Writer — io.Writer, каждая строка которого пишется отдельной записью
+// уровня l (stderr/stdout внешних программ); Close дописывает неполную строку
+func Writer(ctx context.Context, l slog.Level, msg string, args ...any) io.WriteCloser {
+	var pcs [1]uintptr
+	runtime.Callers(2, pcs[:])
+	args = args[:len(args):len(args)]
+
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sc := bufio.NewScanner(pr)
+		sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for sc.Scan() {
+			line := strings.TrimRight(sc.Text(), "\r")
+			if line == "" {
+				continue
+			}
+			if std.Enabled(ctx, l) {
+				emit(ctx, l, pcs[0], msg, append(args, "line", line)...)
+			}
+		}
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+	return &lineWriter{pw: pw, done: done}
+}
+
+type lineWriter struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) { return w.pw.Write(b) }
+
+func (w *lineWriter) Close() error {
+	err := w.pw.Close()
+	<-w.done
+	return err
 }
@@ -2,20 +2,24 @@ package masscan
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/L1nMay/portscanner/internal/config"
 	"github.com/L1nMay/portscanner/internal/logger"
 )
 
-// {"ip":"192.168.0.1","timestamp":"1660000000","ports":[{"port":80,"proto":"tcp","status":"open"}]}
+// {"ip":"192.168.0.1","timestamp":"1660000000","ports":[{"port":80,"proto":"tcp","status":"open","ttl":64}]}
 type masscanPort struct {
 	Port  uint16 `json:"port"`
 	Proto string `json:"proto"`
+	TTL   int    `json:"ttl"`
 }
 
 type masscanEntry struct {
@@ -27,6 +31,11 @@ type Result struct {
 	IP    string
 	Port  uint16
 	Proto string
+	TTL   int // TTL ответа (0 — неизвестно), используется для OS-эвристик
+
+	// только из файлов с --banners (импорт)
+	Service string
+	Banner  string
 }
 
 // Run — legacy (без ctx)
@@ -52,7 +61,7 @@ func RunCtx(ctx context.Context, cfg *config.Config) ([]Result, error) {
 
 	args = append(args, cfg.Targets...)
 
-	logger.Infof("Running masscan: %s %s", cfg.MasscanPath, strings.Join(args, " "))
+	logger.Info(ctx, "running masscan", "cmd", cfg.MasscanPath+" "+strings.Join(args, " "), "targets", len(cfg.Targets))
 
 	cmd := exec.CommandContext(ctx, cfg.MasscanPath, args...)
 
@@ -61,13 +70,19 @@ func RunCtx(ctx context.Context, cfg *config.Config) ([]Result, error) {
 		return nil, fmt.Errorf("stdout pipe error: %w", err)
 	}
 
-	// masscan пишет ошибки в stderr — можно слить в stdout (по желанию)
-	// cmd.Stderr = cmd.Stdout
+	// stderr: предупреждения и ошибки (нет прав, нет интерфейса) и строка статуса
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("stderr pipe error: %w", err)
+	}
 
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("failed to start masscan: %w", err)
 	}
 
+	lastErr := make(chan string, 1)
+	go func() { lastErr <- logStderr(ctx, stderr) }()
+
 	scanner := bufio.NewScanner(stdout)
 	// на всякий случай увеличим буфер (иногда строки бывают длиннее)
 	buf := make([]byte, 0, 64*1024)
@@ -86,11 +101,12 @@ func RunCtx(ctx context.Context, cfg *config.Config) ([]Result, error) {
 		if line == "" {
 			continue
 		}
+		logger.Debug(ctx, "masscan stdout", "line", line)
 
 		var entry masscanEntry
 		if err := json.Unmarshal([]byte(line), &entry); err != nil {
 			// иногда masscan пишет не-json строки — не валим весь запуск
-			logger.Errorf("failed to parse masscan json: %v (line: %s)", err, line)
+			logger.Warn(ctx, "masscan: skip non-json line", "err", err, "line", line)
 			continue
 		}
 
@@ -99,21 +115,73 @@ func RunCtx(ctx context.Context, cfg *config.Config) ([]Result, error) {
 				IP:    entry.IP,
 				Port:  p.Port,
 				Proto: p.Proto,
+				TTL:   p.TTL,
 			})
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		logger.Errorf("masscan scanner error: %v", err)
+		logger.Error(ctx, "masscan output read error", "err", err)
 	}
 
+	// stderr дочитываем до Wait: Wait закрывает pipe
+	last := <-lastErr
+
 	if err := cmd.Wait(); err != nil {
 		// если отменили — это норм
 		if ctx.Err() != nil {
 			return results, ctx.Err()
 		}
+		if last != "" {
+			return results, fmt.Errorf("masscan finished with error: %w (%s)", err, last)
+		}
 		return results, fmt.Errorf("masscan finished with error: %w", err)
 	}
 
 	return results, nil
 }
+
+// statusEvery — как часто писать строку статуса masscan ("rate: ... done")
+const statusEvery = 30 * time.Second
+
+// logStderr — stderr masscan построчно в лог (и журнал скана); строка статуса
+// обновляется через \r раз в секунду, поэтому пишется не чаще statusEvery.
+// Возвращает последнюю не-статусную строку — для текста ошибки.
+func logStderr(ctx context.Context, r io.Reader) string {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 4096), 64*1024)
+	sc.Split(scanCRLF)
+
+	var (
+		last       string
+		lastStatus time.Time
+	)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "rate:") {
+			if time.Since(lastStatus) < statusEvery {
+				continue
+			}
+			lastStatus = time.Now()
+		} else {
+			last = line
+		}
+		logger.Info(ctx, "masscan stderr", "stream", "stderr", "line", line)
+	}
+	_, _ = io.Copy(io.Discard, r)
+	return last
+}
+
+// scanCRLF — bufio.ScanLines, но строкой считается и кусок до \r
+func scanCRLF(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
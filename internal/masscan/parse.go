@@ -0,0 +1,185 @@
+package masscan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// masscanService — запись --banners в JSON: {"port":80,"service":{"name":"http","banner":"..."}}
+type masscanService struct {
+	Name   string `json:"name"`
+	Banner string `json:"banner"`
+}
+
+type fileEntry struct {
+	IP    string `json:"ip"`
+	Ports []struct {
+		Port    uint16          `json:"port"`
+		Proto   string          `json:"proto"`
+		Status  string          `json:"status"`
+		TTL     int             `json:"ttl"`
+		Service *masscanService `json:"service"`
+	} `json:"ports"`
+}
+
+// ParseJSON — файл `masscan -oJ`: массив с записью на строку (в т.ч. с
+// висячими запятыми старых версий) или NDJSON. Баннеры сливаются с портами.
+func ParseJSON(r io.Reader) ([]Result, error) {
+	m := newMerger()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		line = strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ","))
+		if line == "" || line == "," {
+			continue
+		}
+
+		var e fileEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("masscan json: line %d: %w", n, err)
+		}
+		if e.IP == "" {
+			continue // {"finished": 1} и прочие служебные записи
+		}
+		for _, p := range e.Ports {
+			if p.Service != nil {
+				m.banner(e.IP, p.Port, p.Proto, p.Service.Name, p.Service.Banner)
+				continue
+			}
+			if p.Status == "" || p.Status == "open" {
+				m.open(e.IP, p.Port, p.Proto, p.TTL)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("masscan json: %w", err)
+	}
+	return m.results(), nil
+}
+
+// ParseList — файл `masscan -oL`:
+//
+//	open tcp 80 10.0.0.1 1660000000
+//	banner tcp 80 10.0.0.1 1660000000 http HTTP/1.0 200 OK...
+func ParseList(r io.Reader) ([]Result, error) {
+	m := newMerger()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	n := 0
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) < 4 {
+			return nil, fmt.Errorf("masscan list: line %d: too few fields", n)
+		}
+		port, err := strconv.ParseUint(f[2], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("masscan list: line %d: bad port %q", n, f[2])
+		}
+
+		switch f[0] {
+		case "open":
+			m.open(f[3], uint16(port), f[1], 0)
+		case "banner":
+			if len(f) < 6 {
+				continue
+			}
+			m.banner(f[3], uint16(port), f[1], f[5], afterFields(line, 6))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("masscan list: %w", err)
+	}
+	return m.results(), nil
+}
+
+// afterFields — остаток строки после n полей, с исходными пробелами
+func afterFields(s string, n int) string {
+	for i := 0; i < n; i++ {
+		s = strings.TrimLeft(s, " \t")
+		j := strings.IndexAny(s, " \t")
+		if j < 0 {
+			return ""
+		}
+		s = s[j:]
+	}
+	return strings.TrimSpace(s)
+}
+
+// merger — open-записи и баннеры приходят отдельными строками
+type merger struct {
+	order  []string
+	byKey  map[string]*Result
+	titles map[string]string
+}
+
+func newMerger() *merger {
+	return &merger{byKey: map[string]*Result{}, titles: map[string]string{}}
+}
+
+func (m *merger) get(ip string, port uint16, proto string) *Result {
+	proto = strings.ToLower(proto)
+	if proto == "" {
+		proto = "tcp"
+	}
+	key := mergeKey(ip, port, proto)
+	r, ok := m.byKey[key]
+	if !ok {
+		r = &Result{IP: ip, Port: port, Proto: proto}
+		m.byKey[key] = r
+		m.order = append(m.order, key)
+	}
+	return r
+}
+
+func (m *merger) open(ip string, port uint16, proto string, ttl int) {
+	if r := m.get(ip, port, proto); ttl > 0 {
+		r.TTL = ttl
+	}
+}
+
+func mergeKey(ip string, port uint16, proto string) string {
+	return ip + "|" + strconv.Itoa(int(port)) + "|" + strings.ToLower(proto)
+}
+
+// banner — masscan пишет несколько баннеров на порт (http, ssl, title...);
+// оставляем первый, title — только если другого нет
+func (m *merger) banner(ip string, port uint16, proto, service, banner string) {
+	r := m.get(ip, port, proto)
+	if service == "title" {
+		m.titles[mergeKey(ip, port, r.Proto)] = banner
+		return
+	}
+	if r.Service == "" {
+		r.Service = service
+	}
+	if r.Banner == "" {
+		r.Banner = banner
+	}
+}
+
+func (m *merger) results() []Result {
+	out := make([]Result, 0, len(m.order))
+	for _, k := range m.order {
+		r := *m.byKey[k]
+		if t, ok := m.titles[k]; ok && r.Banner == "" {
+			r.Banner = "<title>" + t + "</title>"
+		}
+		out = append(out, r)
+	}
+	return out
+}
@@ -0,0 +1,335 @@
+// Package metrics — минимальные метрики в текстовом формате Prometheus
+// (0.0.4): счётчики, gauge, гистограммы с метками и коллекторы,
+// которые считаются в момент scrape (например, запросом в БД).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets — границы гистограмм по умолчанию (секунды)
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample — одно значение коллектора; Labels — значения меток в порядке объявления
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+type family interface {
+	name() string
+	write(w *bufio.Writer) error
+}
+
+// Registry — набор метрик; Default — общий для процесса
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]family{}}
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.families[f.name()]; dup {
+		panic("metrics: duplicate metric " + f.name())
+	}
+	r.families[f.name()] = f
+}
+
+// Write — все метрики в текстовом формате, по имени
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	list := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		list = append(list, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].name() < list[j].name() })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range list {
+		if err := f.write(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ContentType — для ответа /metrics
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+/* ========================= COMMON ========================= */
+
+type desc struct {
+	fqName string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) name() string { return d.fqName }
+
+func (d *desc) header(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.fqName, escapeHelp(d.help), d.fqName, d.typ)
+}
+
+func (d *desc) check(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s: want %d label values, got %d", d.fqName, len(d.labels), len(values)))
+	}
+}
+
+// series — "name{a="x",b="y"}"; extra — дополнительная метка (le для гистограмм)
+func (d *desc) series(suffix string, values []string, extraName, extraValue string) string {
+	var sb strings.Builder
+	sb.WriteString(d.fqName + suffix)
+	if len(values) == 0 && extraName == "" {
+		return sb.String()
+	}
+	sb.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(d.labels[i] + `="` + escapeLabel(v) + `"`)
+	}
+	if extraName != "" {
+		if len(values) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extraName + `="` + extraValue + `"`)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func key(values []string) string { return strings.Join(values, "\xff") }
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+/* ========================= COUNTER / GAUGE ========================= */
+
+// vec — значения counter/gauge по наборам меток
+type vec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*point
+}
+
+type point struct {
+	labels []string
+	value  float64
+}
+
+func newVec(typ, name, help string, labels []string) *vec {
+	return &vec{desc: desc{fqName: name, help: help, typ: typ, labels: labels}, values: map[string]*point{}}
+}
+
+func (v *vec) get(values []string) *point {
+	v.check(values)
+	k := key(values)
+	p, ok := v.values[k]
+	if !ok {
+		p = &point{labels: append([]string(nil), values...)}
+		v.values[k] = p
+	}
+	return p
+}
+
+func (v *vec) write(w *bufio.Writer) error {
+	v.mu.Lock()
+	points := make([]*point, 0, len(v.values))
+	for _, p := range v.values {
+		points = append(points, &point{labels: p.labels, value: p.value})
+	}
+	v.mu.Unlock()
+	if len(points) == 0 && len(v.labels) > 0 {
+		return nil
+	}
+	sort.Slice(points, func(i, j int) bool { return key(points[i].labels) < key(points[j].labels) })
+
+	v.header(w)
+	if len(points) == 0 {
+		fmt.Fprintf(w, "%s 0\n", v.fqName)
+	}
+	for _, p := range points {
+		fmt.Fprintf(w, "%s %s\n", v.series("", p.labels, "", ""), formatFloat(p.value))
+	}
+	return nil
+}
+
+// CounterVec — монотонный счётчик
+type CounterVec struct{ *vec }
+
+// NewCounterVec — счётчик в Default; имя по соглашению оканчивается на _total
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec("counter", name, help, labels)}
+	Default.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(values ...string) { c.Add(1, values...) }
+
+func (c *CounterVec) Add(n float64, values ...string) {
+	if n < 0 {
+		panic("metrics: counter " + c.fqName + " cannot decrease")
+	}
+	c.mu.Lock()
+	c.get(values).value += n
+	c.mu.Unlock()
+}
+
+// GaugeVec — произвольное значение
+type GaugeVec struct{ *vec }
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec("gauge", name, help, labels)}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(n float64, values ...string) {
+	g.mu.Lock()
+	g.get(values).value = n
+	g.mu.Unlock()
+}
+
+/* ========================= HISTOGRAM ========================= */
+
+// HistogramVec — распределение (длительности, размеры)
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histPoint
+}
+
+type histPoint struct {
+	labels []string
+	counts []uint64 // по бакетам, не накопительно
+	count  uint64
+	sum    float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	h := &HistogramVec{
+		desc:    desc{fqName: name, help: help, typ: "histogram", labels: labels},
+		buckets: b,
+		values:  map[string]*histPoint{},
+	}
+	Default.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.check(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := key(values)
+	p, ok := h.values[k]
+	if !ok {
+		p = &histPoint{labels: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.values[k] = p
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		p.counts[i]++
+	}
+	p.count++
+	p.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) error {
+	h.mu.Lock()
+	points := make([]histPoint, 0, len(h.values))
+	for _, p := range h.values {
+		points = append(points, histPoint{
+			labels: p.labels,
+			counts: append([]uint64(nil), p.counts...),
+			count:  p.count,
+			sum:    p.sum,
+		})
+	}
+	h.mu.Unlock()
+	if len(points) == 0 {
+		return nil
+	}
+	sort.Slice(points, func(i, j int) bool { return key(points[i].labels) < key(points[j].labels) })
+
+	h.header(w)
+	for _, p := range points {
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += p.counts[i]
+			fmt.Fprintf(w, "%s %d\n", h.series("_bucket", p.labels, "le", formatFloat(b)), cum)
+		}
+		fmt.Fprintf(w, "%s %d\n", h.series("_bucket", p.labels, "le", "+Inf"), p.count)
+		fmt.Fprintf(w, "%s %s\n", h.series("_sum", p.labels, "", ""), formatFloat(p.sum))
+		fmt.Fprintf(w, "%s %d\n", h.series("_count", p.labels, "", ""), p.count)
+	}
+	return nil
+}
+
+/* ========================= COLLECTOR ========================= */
+
+// collector — значения считаются при каждом scrape
+type collector struct {
+	desc
+	fn func() ([]Sample, error)
+}
+
+// RegisterCollector — gauge/counter, значения которого отдаёт fn при scrape
+// (ошибка fn — метрика пропускается, остальные отдаются)
+func RegisterCollector(typ, name, help string, fn func() ([]Sample, error), labels ...string) {
+	Default.register(&collector{desc: desc{fqName: name, help: help, typ: typ, labels: labels}, fn: fn})
+}
+
+func (c *collector) write(w *bufio.Writer) error {
+	samples, err := c.fn()
+	if err != nil {
+		fmt.Fprintf(w, "# %s: %s\n", c.fqName, escapeHelp(err.Error()))
+		return nil
+	}
+	c.header(w)
+	for _, s := range samples {
+		c.check(s.Labels)
+		fmt.Fprintf(w, "%s %s\n", c.series("", s.Labels, "", ""), formatFloat(s.Value))
+	}
+	return nil
+}
@@ -15,6 +15,19 @@ type ScanResult struct {
 	LastSeen  time.Time `json:"last_seen"`
 }
 
+const (
+	SourceScan   = "scan"
+	SourceImport = "import"
+)
+
+// статусы строки scans
+const (
+	ScanRunning   = "running"
+	ScanFinished  = "finished"
+	ScanFailed    = "failed"
+	ScanCancelled = "cancelled"
+)
+
 func (r *ScanResult) Key() string {
 	return r.IP + ":" + fmt.Sprintf("%d", r.Port)
 }
@@ -27,9 +40,18 @@ type ScanRun struct {
 	TargetsCount int    `json:"targets_count"` // ✅ чтобы не падали struct literals
 	PortsSpec    string `json:"ports_spec"`
 	Engine       string `json:"engine"` // masscan|nmap|mixed
+	Source       string `json:"source"` // scan | import
 
 	Found    int `json:"found"`
 	NewFound int `json:"new_found"`
 
 	Notes string `json:"notes,omitempty"`
 }
+
+// Cert — сертификат, предъявленный портом при TLS-рукопожатии
+type Cert struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	DNSNames []string  `json:"dns_names,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+}
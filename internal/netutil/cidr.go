@@ -0,0 +1,68 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NormalizeCIDR — приводит IP или CIDR к канонической форме сети:
+// "10.0.0.5" -> "10.0.0.5/32", "10.0.0.5/24" -> "10.0.0.0/24"
+func NormalizeCIDR(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("empty cidr")
+	}
+
+	if ip := net.ParseIP(s); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid cidr: %s", s)
+	}
+	return ipnet.String(), nil
+}
+
+// NormalizeCIDRs — NormalizeCIDR для списка
+func NormalizeCIDRs(in []string) ([]string, error) {
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		c, err := NormalizeCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Within — целиком ли IP/CIDR target лежит внутри одной из сетей cidrs.
+// Имена хостов проверить нельзя, для них всегда false.
+func Within(target string, cidrs []string) bool {
+	t, err := NormalizeCIDR(target)
+	if err != nil {
+		return false
+	}
+	_, tn, err := net.ParseCIDR(t)
+	if err != nil {
+		return false
+	}
+	tOnes, tBits := tn.Mask.Size()
+
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			continue
+		}
+		ones, bits := n.Mask.Size()
+		if bits == tBits && ones <= tOnes && n.Contains(tn.IP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package netutil
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PortRange — закрытый интервал портов [From, To]
+type PortRange struct {
+	From int
+	To   int
+}
+
+// PortSet — набор портов из спецификации вида "22,80,443,8000-8100"
+type PortSet []PortRange
+
+// ParsePorts — разбирает спецификацию портов в формате masscan/nmap
+func ParsePorts(spec string) (PortSet, error) {
+	var out PortSet
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			from, to = part[:i], part[i+1:]
+		}
+
+		a, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %q", part)
+		}
+		b, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %q", part)
+		}
+		if a < 0 || b > 65535 || a > b {
+			return nil, fmt.Errorf("invalid port range: %q", part)
+		}
+
+		out = append(out, PortRange{From: a, To: b})
+	}
+
+	return out, nil
+}
+
+// ParseScanPorts — ParsePorts плюс синтаксис -p nmap/masscan: префиксы
+// протокола (T:80,U:53), "-" (1-65535) и открытые диапазоны ("1024-", "-1024").
+// Имена сервисов и маски nmap не понимает.
+func ParseScanPorts(spec string) (PortSet, error) {
+	var out PortSet
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) > 2 && part[1] == ':' && strings.ContainsRune("TtUuSs", rune(part[0])) {
+			part = strings.TrimSpace(part[2:])
+		}
+		switch {
+		case part == "":
+			continue
+		case part == "-":
+			part = "1-65535"
+		case strings.HasPrefix(part, "-"):
+			part = "1" + part
+		case strings.HasSuffix(part, "-"):
+			part += "65535"
+		}
+
+		ps, err := ParsePorts(part)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ps...)
+	}
+
+	return out, nil
+}
+
+func (s PortSet) Contains(port int) bool {
+	for _, r := range s {
+		if port >= r.From && port <= r.To {
+			return true
+		}
+	}
+	return false
+}
+
+// Count — количество различных портов (пересечения диапазонов считаются один раз)
+func (s PortSet) Count() int {
+	rs := append(PortSet(nil), s...)
+	sort.Slice(rs, func(i, j int) bool { return rs[i].From < rs[j].From })
+
+	n, end := 0, -1 // end — правая граница уже посчитанного
+	for _, r := range rs {
+		from := max(r.From, end+1)
+		if r.To >= from {
+			n += r.To - from + 1
+			end = r.To
+		}
+	}
+	return n
+}
+
+func (s PortSet) String() string {
+	parts := make([]string, 0, len(s))
+	for _, r := range s {
+		if r.From == r.To {
+			parts = append(parts, strconv.Itoa(r.From))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d-%d", r.From, r.To))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -4,7 +4,10 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/xml"
 	"fmt"
+	"io"
+	"log/slog"
 	"os/exec"
 	"strings"
 
@@ -16,6 +19,22 @@ type Result struct {
 	IP    string
 	Port  uint16
 	Proto string
+
+	// заполняются только в XML-режиме (nmap.os_detection)
+	TTL int      // reason_ttl из ответа (0 — неизвестно)
+	OS  *OSMatch // лучший вариант ОС для хоста (общий для всех портов хоста)
+
+	Service string // service/@name (-sV или таблица nmap)
+	Banner  string // product/version или вывод скрипта banner
+}
+
+// OSMatch — лучший вариант ОС из `nmap -O`
+type OSMatch struct {
+	Name     string
+	Family   string // osclass osfamily: Windows, Linux, FreeBSD, IOS...
+	Vendor   string
+	Type     string // general purpose, router, switch, firewall...
+	Accuracy int
 }
 
 // Run — legacy (без ctx)
@@ -25,6 +44,10 @@ func Run(cfg *config.Config) ([]Result, error) {
 
 // RunCtx — ctx-aware запуск nmap (для cancel)
 func RunCtx(ctx context.Context, cfg *config.Config) ([]Result, error) {
+	if cfg.Nmap.OSDetection {
+		return runXML(ctx, cfg)
+	}
+
 	var results []Result
 
 	args := []string{
@@ -35,9 +58,7 @@ func RunCtx(ctx context.Context, cfg *config.Config) ([]Result, error) {
 
 	args = append(args, cfg.Targets...)
 
-	logger.Infof("Running nmap: nmap %s", strings.Join(args, " "))
-
-	cmd := exec.CommandContext(ctx, "nmap", args...)
+	cmd := command(ctx, args)
 
 	var stdout bytes.Buffer
 	cmd.Stdout = &stdout
@@ -47,8 +68,11 @@ func RunCtx(ctx context.Context, cfg *config.Config) ([]Result, error) {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
+		// stdout и stderr общие — при ошибке весь вывод нужен для разбора
+		logRaw(ctx, slog.LevelInfo, "output", stdout.Bytes())
 		return nil, fmt.Errorf("nmap error: %w", err)
 	}
+	logRaw(ctx, slog.LevelDebug, "stdout", stdout.Bytes())
 
 	scanner := bufio.NewScanner(&stdout)
 	var currentIP string
@@ -81,3 +105,180 @@ func RunCtx(ctx context.Context, cfg *config.Config) ([]Result, error) {
 
 	return results, nil
 }
+
+// runXML — запуск с -O и разбором XML (-oX -). Требует root/CAP_NET_RAW.
+func runXML(ctx context.Context, cfg *config.Config) ([]Result, error) {
+	args := []string{
+		"-Pn",
+		"-sT",
+		"-O",
+		"--reason",
+		"-p", cfg.Ports,
+		"-oX", "-",
+	}
+
+	args = append(args, cfg.Targets...)
+
+	cmd := command(ctx, args)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("nmap error: %w (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+	logRaw(ctx, slog.LevelInfo, "stderr", stderr.Bytes())
+	logRaw(ctx, slog.LevelDebug, "stdout", stdout.Bytes())
+
+	return ParseXML(&stdout)
+}
+
+// command — exec.Cmd для nmap; командная строка — в лог и журнал скана
+func command(ctx context.Context, args []string) *exec.Cmd {
+	logger.Info(ctx, "running nmap", "cmd", "nmap "+strings.Join(args, " "), "os_detection", containsArg(args, "-O"))
+	return exec.CommandContext(ctx, "nmap", args...)
+}
+
+// logRaw — вывод nmap построчно: stdout — только в debug, stderr (предупреждения)
+// — в info и журнал скана
+func logRaw(ctx context.Context, l slog.Level, stream string, out []byte) {
+	if len(out) == 0 || (l < slog.LevelInfo && !logger.DebugEnabled()) {
+		return
+	}
+	w := logger.Writer(ctx, l, "nmap "+stream, "stream", stream)
+	_, _ = w.Write(out)
+	_ = w.Close()
+}
+
+func containsArg(args []string, want string) bool {
+	for _, a := range args {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
+
+/* ========================= XML ========================= */
+
+type xmlRun struct {
+	Hosts []xmlHost `xml:"host"`
+}
+
+type xmlHost struct {
+	Addresses []xmlAddress `xml:"address"`
+	Ports     []xmlPort    `xml:"ports>port"`
+	OSMatches []xmlOSMatch `xml:"os>osmatch"`
+}
+
+type xmlAddress struct {
+	Addr     string `xml:"addr,attr"`
+	AddrType string `xml:"addrtype,attr"`
+}
+
+type xmlPort struct {
+	Protocol string `xml:"protocol,attr"`
+	PortID   uint16 `xml:"portid,attr"`
+	State    struct {
+		State     string `xml:"state,attr"`
+		ReasonTTL int    `xml:"reason_ttl,attr"`
+	} `xml:"state"`
+	Service struct {
+		Name      string `xml:"name,attr"`
+		Product   string `xml:"product,attr"`
+		Version   string `xml:"version,attr"`
+		ExtraInfo string `xml:"extrainfo,attr"`
+	} `xml:"service"`
+	Scripts []struct {
+		ID     string `xml:"id,attr"`
+		Output string `xml:"output,attr"`
+	} `xml:"script"`
+}
+
+type xmlOSMatch struct {
+	Name     string       `xml:"name,attr"`
+	Accuracy int          `xml:"accuracy,attr"`
+	Classes  []xmlOSClass `xml:"osclass"`
+}
+
+type xmlOSClass struct {
+	Type     string `xml:"type,attr"`
+	Vendor   string `xml:"vendor,attr"`
+	Family   string `xml:"osfamily,attr"`
+	Accuracy int    `xml:"accuracy,attr"`
+}
+
+// ParseXML — разбирает вывод `nmap -oX`, возвращает только open-порты
+func ParseXML(r io.Reader) ([]Result, error) {
+	var run xmlRun
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return nil, fmt.Errorf("nmap xml parse error: %w", err)
+	}
+
+	var results []Result
+	for _, h := range run.Hosts {
+		ip := hostAddr(h)
+		if ip == "" {
+			continue
+		}
+		os := bestOSMatch(h)
+
+		for _, p := range h.Ports {
+			if p.State.State != "open" {
+				continue
+			}
+			results = append(results, Result{
+				IP:    ip,
+				Port:  p.PortID,
+				Proto: strings.ToLower(p.Protocol),
+				TTL:   p.State.ReasonTTL,
+				OS:    os,
+
+				Service: p.Service.Name,
+				Banner:  portBanner(p),
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// portBanner — вывод скрипта banner, иначе product/version из -sV
+func portBanner(p xmlPort) string {
+	for _, sc := range p.Scripts {
+		if sc.ID == "banner" && sc.Output != "" {
+			return sc.Output
+		}
+	}
+	return strings.TrimSpace(strings.Join(strings.Fields(
+		p.Service.Product+" "+p.Service.Version+" "+p.Service.ExtraInfo), " "))
+}
+
+func hostAddr(h xmlHost) string {
+	for _, a := range h.Addresses {
+		if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+			return a.Addr
+		}
+	}
+	return ""
+}
+
+// bestOSMatch — nmap сортирует osmatch по accuracy, берём первый
+func bestOSMatch(h xmlHost) *OSMatch {
+	if len(h.OSMatches) == 0 {
+		return nil
+	}
+	m := h.OSMatches[0]
+	out := &OSMatch{Name: m.Name, Accuracy: m.Accuracy}
+	if len(m.Classes) > 0 {
+		c := m.Classes[0]
+		out.Family = c.Family
+		out.Vendor = c.Vendor
+		out.Type = c.Type
+	}
+	return out
+}
@@ -0,0 +1,358 @@
+package notifier
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/L1nMay/portscanner/internal/config"
+)
+
+// capture — запросы, пришедшие на httptest-сервер
+type capture struct {
+	mu   sync.Mutex
+	reqs []captured
+}
+
+type captured struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+func (c *capture) server(t *testing.T, status int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.mu.Lock()
+		c.reqs = append(c.reqs, captured{method: r.Method, path: r.URL.EscapedPath(), header: r.Header.Clone(), body: body})
+		c.mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"event_id":"$1"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var c capture
+	srv := c.server(t, http.StatusOK)
+
+	secret := "s3cret"
+	wh, err := NewWebhook(config.ChannelConfig{
+		Name:    "hook",
+		URL:     srv.URL + "/hook",
+		Secret:  secret,
+		Headers: map[string]string{"X-Team": "secops"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wh.Send(context.Background(), Message{
+		Event:   "new_port",
+		Text:    "10.0.0.1:22 opened",
+		Payload: map[string]any{"ip": "10.0.0.1", "port": 22},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.reqs) != 1 {
+		t.Fatalf("requests = %d", len(c.reqs))
+	}
+	r := c.reqs[0]
+	if r.method != http.MethodPost || r.path != "/hook" {
+		t.Errorf("request = %s %s", r.method, r.path)
+	}
+	if got := r.header.Get("X-Team"); got != "secops" {
+		t.Errorf("custom header = %q", got)
+	}
+	if got := r.header.Get("X-Portscanner-Event"); got != "new_port" {
+		t.Errorf("event header = %q", got)
+	}
+
+	ts := r.header.Get("X-Portscanner-Timestamp")
+	if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+		t.Fatalf("timestamp = %q", ts)
+	}
+	sig := r.header.Get("X-Portscanner-Signature")
+	if sig != "sha256="+Sign([]byte(secret), ts, r.body) {
+		t.Errorf("signature %q does not match Sign over the received body", sig)
+	}
+
+	// получатель считает подпись сам, без Sign
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "." + string(r.body)))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); sig != want {
+		t.Errorf("signature = %q, want %q", sig, want)
+	}
+
+	var body webhookBody
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Event != "new_port" || body.Text != "10.0.0.1:22 opened" || body.Payload["ip"] != "10.0.0.1" {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestWebhookUnsignedWithoutSecret(t *testing.T) {
+	var c capture
+	srv := c.server(t, http.StatusOK)
+
+	wh, err := NewWebhook(config.ChannelConfig{Name: "hook", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wh.Send(context.Background(), Message{Event: "new_port", Text: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if sig := c.reqs[0].header.Get("X-Portscanner-Signature"); sig != "" {
+		t.Errorf("signature without secret: %q", sig)
+	}
+}
+
+func TestMatrixStableTxnID(t *testing.T) {
+	var c capture
+	srv := c.server(t, http.StatusOK)
+
+	mx, err := NewMatrix(config.ChannelConfig{
+		Name:        "mx",
+		Homeserver:  srv.URL + "/",
+		AccessToken: "syt_token",
+		RoomID:      "!room:example.org",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// повтор доставки того же события — тот же txnId
+	m := Message{Text: "hello", DeliveryID: "42-mx"}
+	for i := 0; i < 2; i++ {
+		if err := mx.Send(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mx.Send(context.Background(), Message{Text: "hello", DeliveryID: "43-mx"}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/portscanner-42-mx"
+	for i, r := range c.reqs[:2] {
+		if r.method != http.MethodPut || r.path != want {
+			t.Errorf("request %d = %s %s, want PUT %s", i, r.method, r.path, want)
+		}
+		if got := r.header.Get("Authorization"); got != "Bearer syt_token" {
+			t.Errorf("authorization = %q", got)
+		}
+	}
+	if c.reqs[2].path == want {
+		t.Error("another event reused the txnId")
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(c.reqs[0].body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["msgtype"] != "m.text" || body["body"] != "hello" {
+		t.Errorf("body = %v", body)
+	}
+}
+
+// smtpStub — SMTP-сервер на один сеанс: принимает письмо и отдаёт его в канал
+func smtpStub(t *testing.T) (addr string, got <-chan []byte) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		say := func(s string) { _, _ = io.WriteString(conn, s+"\r\n") }
+
+		say("220 stub ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				say("250 stub")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				say("250 ok")
+			case cmd == "DATA":
+				say("354 go ahead")
+				var data bytes.Buffer
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(strings.TrimPrefix(l, "."))
+				}
+				out <- data.Bytes()
+				say("250 queued")
+			case cmd == "QUIT":
+				say("221 bye")
+				return
+			default:
+				say("502 unknown")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestEmailWithAttachment(t *testing.T) {
+	addr, got := smtpStub(t)
+	host, port, _ := net.SplitHostPort(addr)
+	p, _ := strconv.Atoi(port)
+
+	em, err := NewEmail(config.ChannelConfig{
+		Name:         "mail",
+		SMTPHost:     host,
+		SMTPPort:     p,
+		From:         "scanner@example.org",
+		To:           []string{"soc@example.org", "ops@example.org"},
+		AttachReport: true,
+		TimeoutSec:   5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := []byte("<html><body>" + strings.Repeat("отчёт ", 40) + "</body></html>")
+	err = em.Send(context.Background(), Message{
+		Subject:     "Еженедельная сводка",
+		Text:        "Новых портов: 3",
+		Attachments: []Attachment{{Name: "report.html", ContentType: "text/html; charset=utf-8", Data: report}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(<-got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Еженедельная сводка" {
+		t.Errorf("subject = %q (%v)", subject, err)
+	}
+	if to := msg.Header.Get("To"); to != "soc@example.org, ops@example.org" {
+		t.Errorf("to = %q", to)
+	}
+
+	mt, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mt != "multipart/mixed" {
+		t.Fatalf("content-type = %q (%v)", mt, err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+
+	// multipart.Reader сам снимает quoted-printable
+	text, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := text.Header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("text part content-type = %q", ct)
+	}
+	if b, _ := io.ReadAll(text); string(b) != "Новых портов: 3" {
+		t.Errorf("text = %q", b)
+	}
+
+	att, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if att.FileName() != "report.html" {
+		t.Errorf("filename = %q", att.FileName())
+	}
+	if enc := att.Header.Get("Content-Transfer-Encoding"); enc != "base64" {
+		t.Fatalf("attachment encoding = %q", enc)
+	}
+	raw, _ := io.ReadAll(att)
+	for _, line := range strings.Split(strings.TrimSpace(string(raw)), "\r\n") {
+		if len(line) > 76 {
+			t.Fatalf("base64 line longer than 76: %d", len(line))
+		}
+	}
+	data, err := io.ReadAll(base64Decoder(raw))
+	if err != nil || !bytes.Equal(data, report) {
+		t.Errorf("attachment does not round-trip (%v)", err)
+	}
+
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("extra part: %v", err)
+	}
+}
+
+func base64Decoder(raw []byte) io.Reader {
+	return base64.NewDecoder(base64.StdEncoding, bytes.NewReader(bytes.ReplaceAll(raw, []byte("\r\n"), nil)))
+}
+
+func TestSendErrorsHideSecrets(t *testing.T) {
+	// адрес, на котором никто не слушает
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := "http://" + ln.Addr().String()
+	ln.Close()
+
+	const (
+		botToken = "123456:AAE-bot-token"
+		hookPath = "/services/T000/B000/XXXXSECRET"
+	)
+	tg, err := NewTelegram(config.ChannelConfig{Name: "tg", BotToken: botToken, ChatID: "1", APIURL: dead})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slack, err := NewSlack(config.ChannelConfig{Name: "slack", URL: dead + hookPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		ch     Channel
+		secret string
+	}{
+		{tg, botToken},
+		{slack, "XXXXSECRET"},
+	} {
+		err := tc.ch.Send(context.Background(), Message{Text: "x"})
+		if err == nil {
+			t.Fatalf("%s: send to a closed port succeeded", tc.ch.Name())
+		}
+		if strings.Contains(err.Error(), tc.secret) || strings.Contains(err.Error(), dead) {
+			t.Errorf("%s: error leaks the URL: %v", tc.ch.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/config"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/report"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// Digester — регулярная (daily/weekly) сводка активности.
+// Сводка ставится в очередь событий как "digest" и доставляется Worker'ом.
+type Digester struct {
+	cfg config.DigestConfig
+	pg  *storage.Postgres
+}
+
+func NewDigester(cfg config.DigestConfig, pg *storage.Postgres) *Digester {
+	return &Digester{cfg: cfg, pg: pg}
+}
+
+func (d *Digester) Run(ctx context.Context) {
+	if d.cfg.Schedule == "" {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	logger.Infof("digest scheduler started (%s at %s)", d.cfg.Schedule, d.cfg.At)
+
+	for {
+		d.tick(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Digester) tick(now time.Time) {
+	end, period := d.lastPeriod(now)
+
+	// ClaimDigestPeriod защищает от повторной отправки после рестарта
+	// и при нескольких инстансах webui
+	ok, err := d.pg.ClaimDigestPeriod(d.cfg.Schedule, end)
+	if err != nil {
+		logger.Errorf("digest claim error: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	dg, err := d.pg.BuildDigest(end.Add(-period), end, d.cfg.Top)
+	if err != nil {
+		logger.Errorf("digest build error: %v", err)
+		return
+	}
+	dg.Kind = d.cfg.Schedule
+
+	if d.cfg.Report {
+		rep, _, err := report.Generate(d.pg, report.Options{
+			From:      end.Add(-period),
+			To:        end,
+			Kind:      d.cfg.Schedule,
+			CreatedBy: "scheduler",
+			Save:      true,
+		})
+		if err != nil {
+			// сводка уйдёт и без отчёта
+			logger.Errorf("digest report error: %v", err)
+		} else {
+			dg.ReportID = rep.ID
+		}
+	}
+
+	payload, err := dg.Payload()
+	if err != nil {
+		logger.Errorf("digest build error: %v", err)
+		return
+	}
+	if err := d.pg.AddEvent("digest", payload); err != nil {
+		logger.Errorf("add event error: %v", err)
+	}
+}
+
+// lastPeriod — конец последнего наступившего периода и его длина
+func (d *Digester) lastPeriod(now time.Time) (time.Time, time.Duration) {
+	at, _ := time.Parse("15:04", d.cfg.At)
+	end := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+
+	if d.cfg.Schedule == "weekly" {
+		wd, _ := config.ParseWeekday(d.cfg.Weekday)
+		end = end.AddDate(0, 0, -((int(end.Weekday()) - int(wd) + 7) % 7))
+		if end.After(now) {
+			end = end.AddDate(0, 0, -7)
+		}
+		return end, 7 * 24 * time.Hour
+	}
+
+	if end.After(now) {
+		end = end.AddDate(0, 0, -1)
+	}
+	return end, 24 * time.Hour
+}
@@ -0,0 +1,36 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/L1nMay/portscanner/internal/config"
+)
+
+const discordMaxLen = 2000
+
+// Discord — webhook канала (https://discord.com/api/webhooks/...)
+type Discord struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func NewDiscord(c config.ChannelConfig) (*Discord, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("channel %s: url is required", c.Name)
+	}
+	return &Discord{name: c.Name, url: c.URL, client: httpClient(c.Timeout())}, nil
+}
+
+func (d *Discord) Name() string { return d.name }
+func (d *Discord) Type() string { return "discord" }
+
+func (d *Discord) Send(ctx context.Context, m Message) error {
+	body := map[string]any{"content": truncate(m.Text, discordMaxLen)}
+	if err := sendJSON(ctx, d.client, http.MethodPost, d.url, body, nil); err != nil {
+		return fmt.Errorf("discord: %w", err)
+	}
+	return nil
+}
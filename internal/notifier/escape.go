@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"html"
+	"strings"
+)
+
+// Форматы разметки каналов
+const (
+	FormatPlain      = "plain"
+	FormatMarkdownV2 = "markdownv2" // Telegram
+	FormatMrkdwn     = "mrkdwn"     // Slack
+	FormatDiscord    = "discord"
+	FormatHTML       = "html"
+)
+
+// FormatFor — разметка, которую понимает канал данного типа
+func FormatFor(channelType string) string {
+	switch channelType {
+	case "telegram":
+		return FormatMarkdownV2
+	case "slack":
+		return FormatMrkdwn
+	case "discord":
+		return FormatDiscord
+	default:
+		return FormatPlain
+	}
+}
+
+// EscapeMarkdownV2 — все спецсимволы Telegram MarkdownV2 экранируются обратным слэшем
+// (https://core.telegram.org/bots/api#markdownv2-style)
+func EscapeMarkdownV2(s string) string {
+	return backslash(s, "_*[]()~`>#+-=|{}.!\\")
+}
+
+// EscapeMrkdwn — Slack требует экранировать только &, < и >
+func EscapeMrkdwn(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// EscapeDiscord — markdown Discord + подавление @everyone/@here
+func EscapeDiscord(s string) string {
+	s = backslash(s, "\\*_~`|>#-[]()")
+	return strings.ReplaceAll(s, "@", "@\u200b")
+}
+
+func EscapeHTML(s string) string {
+	return html.EscapeString(s)
+}
+
+func backslash(s, specials string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 8)
+	for _, r := range s {
+		if strings.ContainsRune(specials, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escaper — функция экранирования для формата
+func escaper(format string) func(string) string {
+	switch format {
+	case FormatMarkdownV2:
+		return EscapeMarkdownV2
+	case FormatMrkdwn:
+		return EscapeMrkdwn
+	case FormatDiscord:
+		return EscapeDiscord
+	case FormatHTML:
+		return EscapeHTML
+	default:
+		return func(s string) string { return s }
+	}
+}
+
+// bolder — жирный текст; аргумент экранируется
+func bolder(format string) func(string) string {
+	esc := escaper(format)
+	switch format {
+	case FormatMarkdownV2, FormatMrkdwn:
+		return func(s string) string { return "*" + esc(s) + "*" }
+	case FormatDiscord:
+		return func(s string) string { return "**" + esc(s) + "**" }
+	case FormatHTML:
+		return func(s string) string { return "<b>" + esc(s) + "</b>" }
+	default:
+		return esc
+	}
+}
+
+// coder — моноширинный фрагмент (баннеры и т.п.)
+func coder(format string) func(string) string {
+	switch format {
+	case FormatMarkdownV2:
+		// внутри `...` экранируются только ` и \
+		return func(s string) string { return "`" + backslash(s, "`\\") + "`" }
+	case FormatMrkdwn:
+		return func(s string) string {
+			return "`" + strings.ReplaceAll(EscapeMrkdwn(s), "`", "'") + "`"
+		}
+	case FormatDiscord:
+		return func(s string) string { return "`" + strings.ReplaceAll(s, "`", "'") + "`" }
+	case FormatHTML:
+		return func(s string) string { return "<code>" + EscapeHTML(s) + "</code>" }
+	default:
+		return func(s string) string { return s }
+	}
+}
@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/config"
+)
+
+// Matrix — отправка m.text в комнату через client-server API
+type Matrix struct {
+	name       string
+	homeserver string
+	token      string
+	roomID     string
+	client     *http.Client
+}
+
+func NewMatrix(c config.ChannelConfig) (*Matrix, error) {
+	if c.Homeserver == "" || c.AccessToken == "" || c.RoomID == "" {
+		return nil, fmt.Errorf("channel %s: homeserver, access_token and room_id are required", c.Name)
+	}
+	return &Matrix{
+		name:       c.Name,
+		homeserver: strings.TrimRight(c.Homeserver, "/"),
+		token:      c.AccessToken,
+		roomID:     c.RoomID,
+		client:     httpClient(c.Timeout()),
+	}, nil
+}
+
+func (mx *Matrix) Name() string { return mx.name }
+func (mx *Matrix) Type() string { return "matrix" }
+
+func (mx *Matrix) Send(ctx context.Context, m Message) error {
+	// txnId делает PUT идемпотентным: повтор доставки того же события в тот же
+	// канал идёт с тем же id и не создаёт дубль
+	txn := m.DeliveryID
+	if txn == "" {
+		txn = newTxnID()
+	}
+	u := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		mx.homeserver, url.PathEscape(mx.roomID), url.PathEscape("portscanner-"+txn))
+
+	body := map[string]any{
+		"msgtype": "m.text",
+		"body":    m.Text,
+	}
+	headers := map[string]string{"Authorization": "Bearer " + mx.token}
+
+	if err := sendJSON(ctx, mx.client, http.MethodPut, u, body, headers); err != nil {
+		return fmt.Errorf("matrix: %w", err)
+	}
+	return nil
+}
+
+func newTxnID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,16 @@
+package notifier
+
+import "github.com/L1nMay/portscanner/internal/metrics"
+
+var (
+	deliveries = metrics.NewCounterVec(
+		"portscanner_notification_deliveries_total",
+		"Notifications delivered by channel.",
+		"channel",
+	)
+	deliveryFailures = metrics.NewCounterVec(
+		"portscanner_notification_delivery_failures_total",
+		"Failed notification delivery attempts by channel.",
+		"channel",
+	)
+)
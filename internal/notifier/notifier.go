@@ -1,7 +1,69 @@
 package notifier
 
-import "github.com/L1nMay/portscanner/internal/model"
+import (
+	"context"
+	"fmt"
 
-type Notifier interface {
-	NotifyNewOpenPorts(results []*model.ScanResult) error
+	"github.com/L1nMay/portscanner/internal/config"
+)
+
+// Message — то, что уходит в канал
+type Message struct {
+	Subject string         // тема письма / заголовок
+	Text    string         // готовый текст уведомления
+	Event   string         // тип события (new_port, policy_violation, ...)
+	Payload map[string]any // исходные данные события (webhook отдаёт их как есть)
+
+	// DeliveryID — «событие-канал», одинаковый у всех попыток доставки:
+	// ключ идемпотентности (Matrix txnId). Пустой — разовая отправка.
+	DeliveryID string
+
+	Attachments []Attachment // только email (attach_report), остальные каналы игнорируют
+}
+
+// Attachment — файл к сообщению
+type Attachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// Channel — драйвер канала уведомлений
+type Channel interface {
+	Name() string
+	Type() string // telegram | webhook | slack | ... — выбирает шаблон разметки
+	Send(ctx context.Context, m Message) error
+}
+
+// New — драйвер по описанию канала из конфига
+func New(c config.ChannelConfig) (Channel, error) {
+	switch c.Type {
+	case "telegram":
+		return NewTelegram(c)
+	case "webhook":
+		return NewWebhook(c)
+	case "slack":
+		return NewSlack(c)
+	case "discord":
+		return NewDiscord(c)
+	case "matrix":
+		return NewMatrix(c)
+	case "email":
+		return NewEmail(c)
+	default:
+		return nil, fmt.Errorf("channel %s: unknown type %q", c.Name, c.Type)
+	}
+}
+
+// FromConfig — все каналы из notifications.channels по имени
+func FromConfig(cfg *config.Config) (map[string]Channel, error) {
+	out := map[string]Channel{}
+	for _, c := range cfg.Notifications.Channels {
+		ch, err := New(c)
+		if err != nil {
+			return nil, err
+		}
+		out[c.Name] = ch
+	}
+	return out, nil
 }
@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/L1nMay/portscanner/internal/config"
+)
+
+// Slack — incoming webhook (https://hooks.slack.com/services/...)
+type Slack struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func NewSlack(c config.ChannelConfig) (*Slack, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("channel %s: url is required", c.Name)
+	}
+	return &Slack{name: c.Name, url: c.URL, client: httpClient(c.Timeout())}, nil
+}
+
+func (s *Slack) Name() string { return s.name }
+func (s *Slack) Type() string { return "slack" }
+
+func (s *Slack) Send(ctx context.Context, m Message) error {
+	body := map[string]any{"text": m.Text}
+	if err := sendJSON(ctx, s.client, http.MethodPost, s.url, body, nil); err != nil {
+		return fmt.Errorf("slack: %w", err)
+	}
+	return nil
+}
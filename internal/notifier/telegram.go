@@ -1,79 +1,73 @@
 package notifier
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/L1nMay/portscanner/internal/config"
-	"github.com/L1nMay/portscanner/internal/logger"
-	"github.com/L1nMay/portscanner/internal/model"
 )
 
-type TelegramNotifier struct {
-	cfg *config.Config
-}
-
-func NewTelegramNotifier(cfg *config.Config) *TelegramNotifier {
-	return &TelegramNotifier{cfg: cfg}
-}
+const telegramMaxLen = 4096
 
-type telegramMessage struct {
-	ChatID string `json:"chat_id"`
-	Text   string `json:"text"`
+type Telegram struct {
+	name   string
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
 }
 
-func (t *TelegramNotifier) NotifyNewOpenPorts(results []*model.ScanResult) error {
-	if !t.cfg.Telegram.Enabled || len(results) == 0 {
-		return nil
+func NewTelegram(c config.ChannelConfig) (*Telegram, error) {
+	if c.BotToken == "" || c.ChatID == "" {
+		return nil, fmt.Errorf("channel %s: bot_token and chat_id are required", c.Name)
+	}
+	api := strings.TrimRight(c.APIURL, "/")
+	if api == "" {
+		api = "https://api.telegram.org"
 	}
+	return &Telegram{
+		name:   c.Name,
+		apiURL: api,
+		token:  c.BotToken,
+		chatID: c.ChatID,
+		client: httpClient(c.Timeout()),
+	}, nil
+}
 
-	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("📡 %s — новые открытые порты:\n\n", t.cfg.ScanName))
+func (t *Telegram) Name() string { return t.name }
+func (t *Telegram) Type() string { return "telegram" }
 
-	for _, r := range results {
-		builder.WriteString(fmt.Sprintf(
-			"- %s:%d (%s)\n  Сервис: %s\n  Первый раз замечен: %s\n",
-			r.IP,
-			r.Port,
-			r.Proto,
-			r.Service,
-			r.FirstSeen.Format(time.RFC3339),
-		))
-		if r.Banner != "" {
-			banner := r.Banner
-			if len(banner) > 200 {
-				banner = banner[:200] + "..."
-			}
-			builder.WriteString(fmt.Sprintf("  Баннер: %s\n", banner))
-		}
-		builder.WriteString("\n")
+func (t *Telegram) Send(ctx context.Context, m Message) error {
+	body := map[string]any{
+		"chat_id":                  t.chatID,
+		"text":                     truncateMarkdownV2(m.Text, telegramMaxLen),
+		"parse_mode":               "MarkdownV2",
+		"disable_web_page_preview": true,
 	}
-
-	msg := telegramMessage{
-		ChatID: t.cfg.Telegram.ChatID,
-		Text:   builder.String(),
+	url := fmt.Sprintf("%s/bot%s/sendMessage", t.apiURL, t.token)
+	if err := sendJSON(ctx, t.client, http.MethodPost, url, body, nil); err != nil {
+		return fmt.Errorf("telegram: %w", err)
 	}
+	return nil
+}
 
-	body, err := json.Marshal(msg)
-	if err != nil {
-		return err
+// truncateMarkdownV2 — обрезка без «висящего» экранирующего слэша;
+// многоточие в MarkdownV2 безопасно, а вот оборванная сущность (*...) — нет,
+// поэтому длинные шаблоны лучше держать короче лимита
+func truncateMarkdownV2(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
 	}
-
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.cfg.Telegram.BotToken)
-
-	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
+	r = r[:max-1]
+	n := 0
+	for i := len(r) - 1; i >= 0 && r[i] == '\\'; i-- {
+		n++
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 300 {
-		logger.Errorf("telegram returned non-2xx status: %s", resp.Status)
+	if n%2 == 1 {
+		r = r[:len(r)-1]
 	}
-
-	return nil
+	return string(r) + "…"
 }
@@ -0,0 +1,336 @@
+package notifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/alerting"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// Template — тема и текст уведомления (text/template).
+//
+// Данные шаблона:
+//
+//	.ID, .Type, .CreatedAt — событие
+//	.P                     — payload события (.P.ip, .P.port, .P.service, ...)
+//	.Digest                — сводка (только для scan_digest / digest)
+//
+// Функции: esc (экранирование под разметку канала), b (жирный), code (моноширинный),
+// mdv2 / mrkdwn / discord / html / plain (явное экранирование), str, list, join,
+// ts, truncate, default, upper, title.
+// Весь текст вне esc/b/code уходит в канал как есть — в Telegram MarkdownV2
+// символы _*[]()~`>#+-=|{}.! в литералах нужно экранировать самому.
+type Template struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// builtin — шаблоны по умолчанию (литералы без спецсимволов MarkdownV2)
+var builtin = map[string]Template{
+	"new_port": {
+		Subject: `New open port {{str .P.ip}}:{{str .P.port}}`,
+		Body: `🟢 {{b "New open port"}}
+IP: {{esc .P.ip}}
+Port: {{esc .P.port}}
+Service: {{esc .P.service}}
+{{- with list .P.tags}}
+Tags: {{esc (join . ", ")}}
+{{- end}}`,
+	},
+	"policy_violation": {
+		Subject: `Policy violation ({{str .P.severity}}) on {{str .P.ip}}:{{str .P.port}}`,
+		Body: `🔴 {{b "Policy violation"}} {{esc (printf "(%s)" (str .P.severity))}}
+IP: {{esc .P.ip}}
+Port: {{esc .P.port}}/{{esc .P.proto}}
+Service: {{esc .P.service}}
+{{- range .P.violations}}
+• {{esc .policy}}: {{esc .reason}}
+{{- end}}`,
+	},
+	"scan_digest": {
+		Subject: `Scan summary: {{.Digest.NewPorts}} new, {{.Digest.ClosedPorts}} closed`,
+		Body:    digestBody,
+	},
+	"digest": {
+		Subject: `Port scanner {{.Digest.Kind}} digest`,
+		Body:    digestBody,
+	},
+}
+
+// EventTypes — типы событий со встроенными шаблонами
+func EventTypes() []string {
+	out := make([]string, 0, len(builtin))
+	for t := range builtin {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+var defaultTemplate = Template{
+	Subject: `Event: {{.Type}}`,
+	Body:    `{{b "Event"}}: {{esc .Type}}`,
+}
+
+const digestBody = `{{with .Digest -}}
+{{if eq .Kind "scan"}}📊 {{b "Scan summary"}}{{if .Targets}} — {{esc .Targets}}{{end}}
+Engine: {{esc .Engine}} • {{esc (ts .From)}} — {{esc (ts .To)}}
+{{else}}📊 {{b (printf "%s digest" (title .Kind))}}
+{{esc (ts .From)}} — {{esc (ts .To)}} • scans: {{.Scans}}
+{{end -}}
+New: {{.NewPorts}} • Closed: {{.ClosedPorts}} • Changed: {{.ChangedPorts}} • Open: {{.OpenPorts}}
+{{- if .TopServices}}
+
+{{b "Top services"}}
+{{- range .TopServices}}
+• {{esc .Service}}: {{.Count}}
+{{- end}}
+{{- end}}
+{{- if .NewSample}}
+
+{{b "New ports"}}{{if gt .NewPorts (len .NewSample)}} — first {{len .NewSample}}{{end}}
+{{- range .NewSample}}
+• {{esc .IP}}:{{.Port}}/{{esc .Proto}} {{esc .Service}}
+{{- end}}
+{{- end}}
+{{- if .Violations}}
+
+{{b (printf "Policy violations: %d" .Violations)}}
+{{- range .ViolationHighlights}}
+• {{esc (printf "[%s]" .Severity)}} {{esc .IP}}:{{.Port}} {{esc .Service}}{{if .Policies}} — {{esc (join .Policies ", ")}}{{end}}
+{{- end}}
+{{- end}}
+{{- end}}`
+
+type templateData struct {
+	ID        int64
+	Type      string
+	CreatedAt time.Time
+	P         map[string]any
+	Digest    *storage.Digest
+}
+
+// Templates — шаблоны по умолчанию + пользовательские из БД.
+// Поиск: (событие, имя канала) -> (событие, тип канала) -> (событие, "*") -> встроенный.
+type Templates struct {
+	mu        sync.RWMutex
+	overrides map[string]Template // event|channel
+}
+
+func NewTemplates() *Templates {
+	return &Templates{overrides: map[string]Template{}}
+}
+
+// SetOverrides — заменяет пользовательские шаблоны
+func (t *Templates) SetOverrides(list []storage.NotificationTemplate) {
+	m := make(map[string]Template, len(list))
+	for _, o := range list {
+		m[o.EventType+"|"+o.Channel] = Template{Subject: o.Subject, Body: o.Body}
+	}
+	t.mu.Lock()
+	t.overrides = m
+	t.mu.Unlock()
+}
+
+// Lookup — действующий шаблон и откуда он взят ("builtin" или ключ override)
+func (t *Templates) Lookup(eventType, channelName, channelType string) (Template, string) {
+	def, ok := builtin[eventType]
+	if !ok {
+		def = defaultTemplate
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, ch := range []string{channelName, channelType, "*"} {
+		if ch == "" {
+			continue
+		}
+		if o, ok := t.overrides[eventType+"|"+ch]; ok {
+			if o.Subject == "" {
+				o.Subject = def.Subject
+			}
+			return o, eventType + "|" + ch
+		}
+	}
+	return def, "builtin"
+}
+
+// Render — сообщение о событии для канала. Если пользовательский шаблон
+// сломан, используется встроенный (чтобы событие всё равно ушло).
+func (t *Templates) Render(e storage.Event, channelName, channelType string) (Message, error) {
+	tpl, src := t.Lookup(e.Type, channelName, channelType)
+	msg, err := RenderTemplate(tpl, e, FormatFor(channelType))
+	if err == nil || src == "builtin" {
+		return msg, err
+	}
+
+	def, ok := builtin[e.Type]
+	if !ok {
+		def = defaultTemplate
+	}
+	msg, err2 := RenderTemplate(def, e, FormatFor(channelType))
+	if err2 != nil {
+		return msg, err2
+	}
+	return msg, fmt.Errorf("template %s: %w (builtin used)", src, err)
+}
+
+// RenderTemplate — рендер шаблона в заданной разметке (тема всегда plain)
+func RenderTemplate(tpl Template, e storage.Event, format string) (Message, error) {
+	msg := Message{Event: e.Type, Payload: e.Payload}
+
+	data := templateData{ID: e.ID, Type: e.Type, CreatedAt: e.CreatedAt, P: e.Payload}
+	if isDigest(e.Type) {
+		d, err := storage.DigestFromPayload(e.Payload)
+		if err != nil {
+			return msg, err
+		}
+		data.Digest = d
+	}
+
+	subject, err := execute("subject", tpl.Subject, data, FormatPlain)
+	if err != nil {
+		return msg, fmt.Errorf("subject: %w", err)
+	}
+	body, err := execute("body", tpl.Body, data, format)
+	if err != nil {
+		return msg, fmt.Errorf("body: %w", err)
+	}
+
+	msg.Subject = strings.TrimSpace(subject)
+	msg.Text = strings.TrimSpace(body)
+	return msg, nil
+}
+
+// ValidateTemplate — синтаксис + пробный рендер на примере события
+func ValidateTemplate(eventType string, tpl Template) error {
+	if strings.TrimSpace(tpl.Body) == "" {
+		return fmt.Errorf("body is required")
+	}
+	for _, f := range []string{FormatPlain, FormatMarkdownV2} {
+		if _, err := RenderTemplate(tpl, SampleEvent(eventType), f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func execute(name, text string, data templateData, format string) (string, error) {
+	tp, err := template.New(name).Funcs(funcs(format)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tp.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func funcs(format string) template.FuncMap {
+	esc, b, code := escaper(format), bolder(format), coder(format)
+	return template.FuncMap{
+		"esc":     func(v any) string { return esc(str(v)) },
+		"b":       func(v any) string { return b(str(v)) },
+		"code":    func(v any) string { return code(str(v)) },
+		"mdv2":    func(v any) string { return EscapeMarkdownV2(str(v)) },
+		"mrkdwn":  func(v any) string { return EscapeMrkdwn(str(v)) },
+		"discord": func(v any) string { return EscapeDiscord(str(v)) },
+		"html":    func(v any) string { return EscapeHTML(str(v)) },
+		"plain":   str,
+		"str":     str,
+		"list":    alerting.PayloadStrings,
+		"join":    strings.Join,
+		"ts":      ts,
+		"upper":   func(v any) string { return strings.ToUpper(str(v)) },
+		"title": func(v any) string {
+			s := str(v)
+			if s == "" {
+				return s
+			}
+			return strings.ToUpper(s[:1]) + s[1:]
+		},
+		"truncate": func(n int, v any) string { return truncate(str(v), n) },
+		"default": func(def string, v any) string {
+			if s := str(v); s != "" {
+				return s
+			}
+			return def
+		},
+	}
+}
+
+// str — значение payload в строку (nil -> "")
+func str(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// ts — время (time.Time или RFC3339 из payload) в локальном формате
+func ts(v any) string {
+	switch x := v.(type) {
+	case time.Time:
+		return x.Local().Format("2006-01-02 15:04")
+	case string:
+		if t, err := time.Parse(time.RFC3339, x); err == nil {
+			return t.Local().Format("2006-01-02 15:04")
+		}
+		return x
+	default:
+		return str(v)
+	}
+}
+
+// SampleEvent — пример события для предпросмотра шаблонов
+func SampleEvent(eventType string) storage.Event {
+	e := storage.Event{ID: 1, Type: eventType, CreatedAt: time.Now().UTC()}
+
+	switch eventType {
+	case "new_port":
+		e.Payload = map[string]any{
+			"ip": "10.0.0.15", "port": 3389, "service": "rdp",
+			"tags": []any{"env:prod", "owner:it_ops"}, "groups": []any{"office-dmz"},
+		}
+	case "policy_violation":
+		e.Payload = map[string]any{
+			"ip": "10.0.0.15", "port": 3389, "proto": "tcp", "service": "rdp", "severity": "high",
+			"violations": []any{map[string]any{"policy": "no-rdp-in-dmz", "reason": "port 3389 (rdp) is denied"}},
+			"tags":       []any{"env:prod"}, "groups": []any{"office-dmz"},
+		}
+	case "scan_digest", "digest":
+		now := time.Now().UTC()
+		d := &storage.Digest{
+			Kind: "scan", ScanID: "00000000-0000-4000-8000-000000000000", Engine: "masscan",
+			Targets: "10.0.0.0/24 (+1)", From: now.Add(-10 * time.Minute), To: now,
+			Scans: 1, OpenPorts: 42, NewPorts: 3, ClosedPorts: 1, ChangedPorts: 2, Violations: 1,
+			TopServices: []storage.ServiceCount{{Service: "http", Count: 17}, {Service: "ssh", Count: 12}},
+			NewSample: []storage.DigestPort{
+				{IP: "10.0.0.15", Port: 3389, Proto: "tcp", Service: "rdp"},
+				{IP: "10.0.0.22", Port: 8080, Proto: "tcp", Service: "http"},
+			},
+			ViolationHighlights: []storage.DigestViolation{
+				{IP: "10.0.0.15", Port: 3389, Service: "rdp", Severity: "high", Policies: []string{"no-rdp-in-dmz"}},
+			},
+		}
+		if eventType == "digest" {
+			d.Kind, d.ScanID, d.Engine, d.Targets = "daily", "", "", ""
+			d.From, d.Scans = now.Add(-24*time.Hour), 96
+		}
+		e.Payload, _ = d.Payload()
+	default:
+		e.Payload = map[string]any{}
+	}
+	return e
+}
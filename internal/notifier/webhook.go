@@ -0,0 +1,93 @@
+package notifier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/config"
+)
+
+// Webhook — произвольный JSON-endpoint.
+// Если задан secret, запрос подписывается:
+//
+//	X-Portscanner-Timestamp: <unix>
+//	X-Portscanner-Signature: sha256=<hex(HMAC-SHA256(secret, timestamp + "." + body))>
+//
+// Получатель проверяет подпись и отбрасывает слишком старые timestamp (защита от replay).
+type Webhook struct {
+	name    string
+	url     string
+	secret  []byte
+	headers map[string]string
+	client  *http.Client
+}
+
+type webhookBody struct {
+	Event   string         `json:"event"`
+	Subject string         `json:"subject,omitempty"`
+	Text    string         `json:"text"`
+	Payload map[string]any `json:"payload,omitempty"`
+	SentAt  time.Time      `json:"sent_at"`
+}
+
+func NewWebhook(c config.ChannelConfig) (*Webhook, error) {
+	if c.URL == "" {
+		return nil, fmt.Errorf("channel %s: url is required", c.Name)
+	}
+	return &Webhook{
+		name:    c.Name,
+		url:     c.URL,
+		secret:  []byte(c.Secret),
+		headers: c.Headers,
+		client:  httpClient(c.Timeout()),
+	}, nil
+}
+
+func (w *Webhook) Name() string { return w.name }
+func (w *Webhook) Type() string { return "webhook" }
+
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	now := time.Now().UTC()
+	body, err := json.Marshal(webhookBody{
+		Event:   m.Event,
+		Subject: m.Subject,
+		Text:    m.Text,
+		Payload: m.Payload,
+		SentAt:  now,
+	})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{}
+	for k, v := range w.headers {
+		headers[k] = v
+	}
+	headers["X-Portscanner-Event"] = m.Event
+	if len(w.secret) > 0 {
+		ts := strconv.FormatInt(now.Unix(), 10)
+		headers["X-Portscanner-Timestamp"] = ts
+		headers["X-Portscanner-Signature"] = "sha256=" + Sign(w.secret, ts, body)
+	}
+
+	if err := sendRaw(ctx, w.client, http.MethodPost, w.url, body, headers); err != nil {
+		return fmt.Errorf("webhook %s: %w", w.name, err)
+	}
+	return nil
+}
+
+// Sign — HMAC-SHA256 от "timestamp.body" в hex (то же считает получатель)
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,358 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/alerting"
+	"github.com/L1nMay/portscanner/internal/config"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/report"
+	"github.com/L1nMay/portscanner/internal/storage"
+	"github.com/L1nMay/portscanner/internal/tracing"
+)
+
+// сколько событий берём за тик и на сколько «арендуем» их у других воркеров.
+// Пачка целиком может не уложиться в lease (20 событий × каналы × sendTimeout),
+// поэтому аренда продлевается перед каждой отправкой: lease > sendTimeout.
+const (
+	claimBatch  = 20
+	claimLease  = 2 * time.Minute
+	sendTimeout = 30 * time.Second
+)
+
+// Worker — доставка событий. Может работать в каждом экземпляре webui:
+// события делятся арендой (ClaimEvents), окна throttle/dedup — в Postgres.
+type Worker struct {
+	pg        *storage.Postgres
+	channels  map[string]Channel // имя канала -> драйвер
+	engine    *alerting.Engine
+	static    []alerting.Rule // правила из config.yaml
+	unmatched []string        // alerts.unmatched_channels
+
+	maxAttempts int
+	retryBase   time.Duration
+	retryMax    time.Duration
+
+	digest    config.DigestConfig
+	templates *Templates
+}
+
+func NewWorker(cfg *config.Config, pg *storage.Postgres, channels map[string]Channel, engine *alerting.Engine) *Worker {
+	n := cfg.Notifications
+	return &Worker{
+		pg:          pg,
+		channels:    channels,
+		engine:      engine,
+		static:      cfg.Alerts.Rules,
+		unmatched:   cfg.Alerts.UnmatchedChannels,
+		maxAttempts: n.MaxAttempts,
+		retryBase:   time.Duration(n.RetryBaseSeconds) * time.Second,
+		retryMax:    time.Duration(n.RetryMaxSeconds) * time.Second,
+		digest:      n.Digest,
+		templates:   NewTemplates(),
+	}
+}
+
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	logger.Infof("notification worker started (%d channels)", len(w.channels))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.process()
+		}
+	}
+}
+
+// reloadRules — config-правила + правила из БД (могли поменяться через API)
+func (w *Worker) reloadRules() {
+	rules := append([]alerting.Rule(nil), w.static...)
+
+	dbRules, err := w.pg.ListAlertRules()
+	if err != nil {
+		logger.Errorf("alert rules load error: %v", err)
+		return
+	}
+	for _, r := range dbRules {
+		if err := r.Compile(); err != nil {
+			logger.Errorf("alert rule skipped: %v", err)
+			continue
+		}
+		rules = append(rules, r)
+	}
+
+	w.engine.SetRules(rules)
+}
+
+// reloadMutes — /mute из Telegram хранится в БД (общий для экземпляров, переживает рестарт)
+func (w *Worker) reloadMutes() {
+	muted, err := w.pg.ListAlertMutes()
+	if err != nil {
+		logger.Errorf("alert mutes load error: %v", err)
+		return
+	}
+	w.engine.SetMutes(muted)
+}
+
+func (w *Worker) reloadTemplates() {
+	list, err := w.pg.ListNotificationTemplates()
+	if err != nil {
+		logger.Errorf("notification templates load error: %v", err)
+		return
+	}
+	w.templates.SetOverrides(list)
+}
+
+// route — каналы для события. Сводки идут в digest.channels (или во все каналы),
+// остальное — по правилам; не подошедшее ни под одно правило — в
+// alerts.unmatched_channels (по умолчанию никуда, но с записью в лог);
+// без правил — во все каналы (старое поведение), кроме типов из digest.suppress.
+// Маршрут сохраняется в БД: повторные попытки идут в те же каналы,
+// а не через правила заново (иначе событие съест dedup/throttle).
+func (w *Worker) route(e *storage.Event) error {
+	if e.Channels != nil {
+		return nil
+	}
+
+	var chs []string
+	switch {
+	case isDigest(e.Type):
+		chs = w.digest.Channels
+		if len(chs) == 0 {
+			chs = w.allChannels()
+		}
+	case w.engine.HasRules():
+		var (
+			matched bool
+			err     error
+		)
+		chs, matched, err = w.engine.Route(alerting.Event{
+			ID:        e.ID,
+			Type:      e.Type,
+			Payload:   e.Payload,
+			CreatedAt: e.CreatedAt,
+		}, time.Now())
+		if err != nil {
+			logger.Errorf("event %d: alert state: %v", e.ID, err)
+		}
+		if !matched {
+			chs = w.unmatched
+			if len(chs) == 0 {
+				logger.Infof("event %d (%s): no alert rule matched, not delivered", e.ID, e.Type)
+			} else {
+				logger.Infof("event %d (%s): no alert rule matched, sent to unmatched_channels", e.ID, e.Type)
+			}
+		}
+	case containsString(w.digest.Suppress, e.Type):
+		// попадёт в сводку
+	default:
+		chs = w.allChannels()
+	}
+
+	if err := w.pg.SetEventChannels(e.ID, chs); err != nil {
+		return err
+	}
+	e.Channels = chs
+	return nil
+}
+
+func (w *Worker) allChannels() []string {
+	out := make([]string, 0, len(w.channels))
+	for name := range w.channels {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func isDigest(eventType string) bool {
+	return eventType == "scan_digest" || eventType == "digest"
+}
+
+func (w *Worker) process() {
+	w.reloadRules()
+	w.reloadMutes()
+	w.reloadTemplates()
+
+	events, err := w.pg.ClaimEvents(claimBatch, claimLease)
+	if err != nil {
+		logger.Errorf("notification worker claim error: %v", err)
+		return
+	}
+
+	for i := range events {
+		w.deliver(&events[i])
+	}
+}
+
+// deliver — одна попытка доставки во все ещё не получившие событие каналы
+func (w *Worker) deliver(e *storage.Event) {
+	ctx, span := tracing.Start(context.Background(), "notify.deliver", tracing.WithAttrs(
+		tracing.Int64("event.id", e.ID),
+		tracing.String("event.type", e.Type),
+		tracing.Int("event.attempt", e.Attempts),
+	))
+	defer span.End()
+
+	if !w.renew(e) {
+		return
+	}
+	if err := w.route(e); err != nil {
+		span.RecordError(err)
+		w.fail(e, fmt.Sprintf("route: %v", err))
+		return
+	}
+
+	attachments := w.reportAttachments(e)
+
+	var errs []string
+	for _, name := range e.Channels {
+		if containsString(e.DeliveredTo, name) {
+			continue
+		}
+
+		ch, exists := w.channels[name]
+		if !exists {
+			deliveryFailures.Inc(name)
+			errs = append(errs, fmt.Sprintf("%s: channel is not configured", name))
+			continue
+		}
+
+		msg, err := w.templates.Render(*e, name, ch.Type())
+		if err != nil {
+			if msg.Text == "" {
+				deliveryFailures.Inc(name)
+				errs = append(errs, fmt.Sprintf("%s: render: %v", name, err))
+				continue
+			}
+			logger.Errorf("event %d: %v", e.ID, err)
+		}
+		msg.Attachments = attachments
+		msg.DeliveryID = fmt.Sprintf("%d-%s", e.ID, name)
+
+		if !w.renew(e) {
+			return
+		}
+		err = w.send(ctx, ch, name, msg)
+		if err != nil {
+			deliveryFailures.Inc(name)
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		deliveries.Inc(name)
+
+		if err := w.pg.MarkEventChannelDelivered(e.ID, name); err != nil {
+			logger.Errorf("event %d: mark %s delivered: %v", e.ID, name, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		span.SetStatus(tracing.StatusError, strings.Join(errs, "; "))
+		w.fail(e, strings.Join(errs, "; "))
+		return
+	}
+
+	if err := w.pg.MarkEventDelivered(e); err != nil {
+		// событие вернётся после lease; уже получившие его каналы пропустим по delivered_to
+		logger.Errorf("event %d: mark delivered: %v", e.ID, err)
+	}
+}
+
+// renew — продлить аренду перед очередным шагом; false — событие перехвачено
+// другим воркером (или не удалось продлить) и дальше его не трогаем
+func (w *Worker) renew(e *storage.Event) bool {
+	err := w.pg.RenewEventLease(e, claimLease)
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, storage.ErrLeaseLost) {
+		logger.Errorf("event %d: lease lost, left to the worker that reclaimed it", e.ID)
+	} else {
+		logger.Errorf("event %d: renew lease: %v", e.ID, err)
+	}
+	return false
+}
+
+// send — отправка в один канал (спан notify.send)
+func (w *Worker) send(ctx context.Context, ch Channel, name string, msg Message) error {
+	ctx, span := tracing.Start(ctx, "notify.send", tracing.WithKind(tracing.KindClient), tracing.WithAttrs(
+		tracing.String("channel", name),
+		tracing.String("channel.type", ch.Type()),
+	))
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	err := ch.Send(ctx, msg)
+	span.RecordError(err)
+	return err
+}
+
+// reportAttachments — HTML-отчёт регулярной сводки (report_id в payload)
+func (w *Worker) reportAttachments(e *storage.Event) []Attachment {
+	if e.Type != "digest" {
+		return nil
+	}
+	id, ok := e.Payload["report_id"].(float64)
+	if !ok || id <= 0 {
+		return nil
+	}
+	rep, html, err := w.pg.GetReport(int64(id))
+	if err != nil {
+		logger.Errorf("event %d: report %d: %v", e.ID, int64(id), err)
+		return nil
+	}
+	return []Attachment{{
+		Name:        report.FileName(rep),
+		ContentType: "text/html; charset=utf-8",
+		Data:        []byte(html),
+	}}
+}
+
+func (w *Worker) fail(e *storage.Event, msg string) {
+	dead := e.Attempts >= w.maxAttempts
+	next := time.Now().Add(w.backoff(e.Attempts))
+
+	if dead {
+		logger.Errorf("event %d dead after %d attempts: %s", e.ID, e.Attempts, msg)
+	} else {
+		logger.Errorf("event %d attempt %d failed, retry at %s: %s", e.ID, e.Attempts, next.Format(time.RFC3339), msg)
+	}
+
+	if err := w.pg.FailEvent(e, msg, next, dead); err != nil {
+		logger.Errorf("event %d: save failure: %v", e.ID, err)
+	}
+}
+
+// backoff — base * 2^(attempt-1) с ограничением сверху и джиттером ±10%
+func (w *Worker) backoff(attempt int) time.Duration {
+	d := w.retryBase
+	for i := 1; i < attempt && d < w.retryMax; i++ {
+		d *= 2
+	}
+	if d > w.retryMax {
+		d = w.retryMax
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/5+1)) - d/10
+	return d + jitter
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
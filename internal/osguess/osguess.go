@@ -0,0 +1,284 @@
+package osguess
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// семейства ОС, которые мы различаем при триаже
+const (
+	FamilyWindows = "windows"
+	FamilyLinux   = "linux"
+	FamilyBSD     = "bsd"
+	FamilyMacOS   = "macos"
+	FamilyNetwork = "network" // роутеры, коммутаторы, файрволы и прочие appliance
+)
+
+// Evidence — один признак ОС (источник + вес 0..100)
+type Evidence struct {
+	Source string // nmap | ssh | http | smb | ports | ttl
+	Family string
+	Name   string // конкретика, если есть ("Ubuntu", "MikroTik RouterOS")
+	Weight int
+}
+
+// Guess — итоговое предположение об ОС хоста
+type Guess struct {
+	Name       string   `json:"os_name,omitempty"`
+	Family     string   `json:"os_family"`
+	Confidence int      `json:"os_confidence"`
+	Sources    []string `json:"os_sources,omitempty"`
+}
+
+// FromNmap — результат `nmap -O` (osclass family/type + accuracy)
+func FromNmap(name, family, typ string, accuracy int) (Evidence, bool) {
+	f := strings.ToLower(family)
+	t := strings.ToLower(typ)
+
+	ev := Evidence{Source: "nmap", Name: name, Weight: accuracy}
+	if ev.Weight > 95 {
+		ev.Weight = 95
+	}
+
+	switch {
+	case t == "router" || t == "switch" || t == "firewall" || t == "wap" ||
+		t == "broadband router" || t == "load balancer" || t == "security-misc":
+		ev.Family = FamilyNetwork
+	case f == "windows":
+		ev.Family = FamilyWindows
+	case f == "linux":
+		ev.Family = FamilyLinux
+	case strings.HasSuffix(f, "bsd"):
+		ev.Family = FamilyBSD
+	case f == "mac os x" || f == "macos" || (f == "ios" && strings.Contains(strings.ToLower(name), "apple")):
+		ev.Family = FamilyMacOS
+	case f == "ios" || f == "routeros" || f == "junos" || f == "fortios" || f == "pan-os":
+		ev.Family = FamilyNetwork
+	default:
+		return Evidence{}, false
+	}
+
+	return ev, ev.Weight > 0
+}
+
+// FromTTL — начальный TTL: 64 (Linux/Unix), 128 (Windows), 255 (сетевое железо).
+// Слабый признак: TTL уменьшается на каждом хопе, поэтому берём «потолок».
+func FromTTL(ttl int) (Evidence, bool) {
+	switch {
+	case ttl <= 0:
+		return Evidence{}, false
+	case ttl <= 64:
+		return Evidence{Source: "ttl", Family: FamilyLinux, Weight: 20}, true
+	case ttl <= 128:
+		return Evidence{Source: "ttl", Family: FamilyWindows, Weight: 30}, true
+	case ttl <= 255:
+		return Evidence{Source: "ttl", Family: FamilyNetwork, Weight: 30}, true
+	}
+	return Evidence{}, false
+}
+
+// FromBanner — эвристики по баннеру (SSH version string, HTTP Server, SMB) и порту
+func FromBanner(port int, service, banner string) (Evidence, bool) {
+	lower := strings.ToLower(banner)
+
+	switch {
+	case strings.HasPrefix(lower, "ssh-"):
+		return fromSSH(lower)
+	case strings.Contains(lower, "smb2 dialect"):
+		return fromSMB(lower)
+	}
+
+	if srv := httpServer(banner); srv != "" {
+		if ev, ok := fromHTTPServer(srv); ok {
+			return ev, true
+		}
+	}
+
+	// открытые «виндовые» порты/сервисы без баннера — слабый признак
+	switch {
+	case port == 135 || port == 139 || port == 445 || port == 3389 || port == 5985,
+		service == "smb" || service == "rdp" || service == "msrpc":
+		return Evidence{Source: "ports", Family: FamilyWindows, Weight: 25}, true
+	}
+
+	return Evidence{}, false
+}
+
+// SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.4
+func fromSSH(lower string) (Evidence, bool) {
+	ev := Evidence{Source: "ssh"}
+
+	switch {
+	case strings.Contains(lower, "openssh_for_windows") || strings.Contains(lower, "windows"):
+		ev.Family, ev.Name, ev.Weight = FamilyWindows, "Windows (OpenSSH)", 75
+	case strings.Contains(lower, "ubuntu"):
+		ev.Family, ev.Name, ev.Weight = FamilyLinux, "Ubuntu", 70
+	case strings.Contains(lower, "debian") || strings.Contains(lower, "raspbian"):
+		ev.Family, ev.Name, ev.Weight = FamilyLinux, "Debian", 70
+	case strings.Contains(lower, "freebsd"):
+		ev.Family, ev.Name, ev.Weight = FamilyBSD, "FreeBSD", 70
+	case strings.Contains(lower, "cisco"):
+		ev.Family, ev.Name, ev.Weight = FamilyNetwork, "Cisco", 80
+	case strings.Contains(lower, "rosssh"):
+		ev.Family, ev.Name, ev.Weight = FamilyNetwork, "MikroTik RouterOS", 80
+	case strings.Contains(lower, "dropbear"):
+		ev.Family, ev.Name, ev.Weight = FamilyLinux, "Embedded Linux (Dropbear)", 40
+	case strings.Contains(lower, "openssh"):
+		// без суффикса дистрибутива: чаще Linux, но бывает BSD/macOS
+		ev.Family, ev.Weight = FamilyLinux, 30
+	default:
+		return Evidence{}, false
+	}
+
+	return ev, true
+}
+
+// banner.probeSMB: "SMB2 dialect=3.0.2". Диалект ОС не выдаёт: Samba и
+// NAS отвечают теми же 2.x/3.0.x, что и Windows, поэтому признак слабый —
+// на уровне открытого 445
+func fromSMB(string) (Evidence, bool) {
+	return Evidence{Source: "smb", Family: FamilyWindows, Weight: 25}, true
+}
+
+func fromHTTPServer(srv string) (Evidence, bool) {
+	lower := strings.ToLower(srv)
+	ev := Evidence{Source: "http"}
+
+	switch {
+	case strings.Contains(lower, "microsoft-iis"):
+		ev.Family, ev.Name, ev.Weight = FamilyWindows, "Windows ("+srv+")", 70
+	case strings.Contains(lower, "microsoft-httpapi") || strings.Contains(lower, "(win64)") || strings.Contains(lower, "(win32)"):
+		ev.Family, ev.Weight = FamilyWindows, 60
+	case strings.Contains(lower, "(ubuntu)"):
+		ev.Family, ev.Name, ev.Weight = FamilyLinux, "Ubuntu", 60
+	case strings.Contains(lower, "(debian)"):
+		ev.Family, ev.Name, ev.Weight = FamilyLinux, "Debian", 60
+	case strings.Contains(lower, "(centos)"):
+		ev.Family, ev.Name, ev.Weight = FamilyLinux, "CentOS", 60
+	case strings.Contains(lower, "(red hat)") || strings.Contains(lower, "(rhel)"):
+		ev.Family, ev.Name, ev.Weight = FamilyLinux, "Red Hat", 60
+	case strings.Contains(lower, "(fedora)"):
+		ev.Family, ev.Name, ev.Weight = FamilyLinux, "Fedora", 60
+	case strings.Contains(lower, "(freebsd)"):
+		ev.Family, ev.Name, ev.Weight = FamilyBSD, "FreeBSD", 60
+	case strings.Contains(lower, "mikrotik"):
+		ev.Family, ev.Name, ev.Weight = FamilyNetwork, "MikroTik", 70
+	case strings.Contains(lower, "cisco"):
+		ev.Family, ev.Name, ev.Weight = FamilyNetwork, "Cisco", 65
+	case strings.Contains(lower, "fortinet") || strings.Contains(lower, "fortigate"):
+		ev.Family, ev.Name, ev.Weight = FamilyNetwork, "Fortinet", 65
+	case strings.Contains(lower, "juniper"):
+		ev.Family, ev.Name, ev.Weight = FamilyNetwork, "Juniper", 65
+	default:
+		return Evidence{}, false
+	}
+
+	return ev, true
+}
+
+// httpServer — значение заголовка Server из сырого HTTP-ответа
+func httpServer(banner string) string {
+	for _, line := range strings.Split(banner, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) > 7 && strings.EqualFold(line[:7], "server:") {
+			return strings.TrimSpace(line[7:])
+		}
+	}
+	return ""
+}
+
+// Combine — сводит признаки одного хоста в одно предположение.
+// Признаки одного источника об одном семействе (TTL каждого порта, «виндовый»
+// порт за портом) не независимы: от каждой пары (источник, семейство) берётся
+// самый сильный. Веса разных источников одного семейства складываются как
+// независимые вероятности (1 - Π(1-w)), конкурирующее семейство снижает
+// уверенность победителя.
+func Combine(evs []Evidence) (Guess, bool) {
+	evs = strongest(evs)
+	if len(evs) == 0 {
+		return Guess{}, false
+	}
+
+	miss := map[string]float64{}
+	best := map[string]Evidence{}
+	sources := map[string]map[string]struct{}{}
+
+	for _, ev := range evs {
+		if ev.Family == "" || ev.Weight <= 0 {
+			continue
+		}
+		if _, ok := miss[ev.Family]; !ok {
+			miss[ev.Family] = 1
+			sources[ev.Family] = map[string]struct{}{}
+		}
+		miss[ev.Family] *= 1 - float64(ev.Weight)/100
+		sources[ev.Family][ev.Source] = struct{}{}
+
+		if b, ok := best[ev.Family]; !ok || (ev.Name != "" && (b.Name == "" || ev.Weight > b.Weight)) {
+			best[ev.Family] = ev
+		}
+	}
+
+	if len(miss) == 0 {
+		return Guess{}, false
+	}
+
+	type scored struct {
+		family string
+		score  float64
+	}
+	ranked := make([]scored, 0, len(miss))
+	for f, m := range miss {
+		ranked = append(ranked, scored{family: f, score: 1 - m})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score == ranked[j].score {
+			return ranked[i].family < ranked[j].family
+		}
+		return ranked[i].score > ranked[j].score
+	})
+
+	score := ranked[0].score
+	if len(ranked) > 1 {
+		score -= ranked[1].score / 2
+	}
+
+	win := ranked[0].family
+	g := Guess{
+		Name:       best[win].Name,
+		Family:     win,
+		Confidence: int(math.Round(math.Max(0, score) * 100)),
+	}
+	for s := range sources[win] {
+		g.Sources = append(g.Sources, s)
+	}
+	sort.Strings(g.Sources)
+
+	return g, g.Confidence > 0
+}
+
+// strongest — по одному признаку на (источник, семейство): с наибольшим весом,
+// при равенстве — с названием ОС
+func strongest(evs []Evidence) []Evidence {
+	type key struct{ source, family string }
+	idx := map[key]int{}
+	out := make([]Evidence, 0, len(evs))
+
+	for _, ev := range evs {
+		if ev.Family == "" || ev.Weight <= 0 {
+			continue
+		}
+		k := key{ev.Source, ev.Family}
+		i, ok := idx[k]
+		if !ok {
+			idx[k] = len(out)
+			out = append(out, ev)
+			continue
+		}
+		if b := out[i]; ev.Weight > b.Weight || (ev.Weight == b.Weight && b.Name == "" && ev.Name != "") {
+			out[i] = ev
+		}
+	}
+	return out
+}
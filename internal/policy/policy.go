@@ -0,0 +1,239 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/netutil"
+)
+
+// режимы политики
+const (
+	ModeAllow = "allow" // разрешены ТОЛЬКО перечисленные порты/сервисы ("web tier: 80,443 only")
+	ModeDeny  = "deny"  // перечисленные порты/сервисы запрещены ("no 3389 anywhere")
+)
+
+// область действия политики
+const (
+	ScopeGlobal = "global"
+	ScopeCIDR   = "cidr"
+	ScopeHost   = "host"
+	ScopeGroup  = "group"
+)
+
+// статус находки
+const (
+	Compliant = "compliant"
+	Violating = "violating"
+)
+
+var severities = []string{"low", "medium", "high", "critical"}
+
+// SeverityRank — порядковый номер severity (неизвестная = 0)
+func SeverityRank(s string) int {
+	for i, v := range severities {
+		if v == strings.ToLower(s) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+type Policy struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	Mode        string    `json:"mode"`       // allow | deny
+	ScopeType   string    `json:"scope_type"` // global | cidr | host | group
+	Scope       string    `json:"scope"`      // CIDR / IP / имя группы
+	Ports       string    `json:"ports"`      // "80,443,8000-8100"
+	Services    []string  `json:"services"`
+	Severity    string    `json:"severity"` // low | medium | high | critical
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	ports   netutil.PortSet
+	network *net.IPNet
+}
+
+// Finding — то, что проверяем: открытый порт на хосте
+type Finding struct {
+	IP      string
+	Port    int
+	Proto   string
+	Service string
+	Groups  []string
+}
+
+type Violation struct {
+	PolicyID int64  `json:"policy_id"`
+	Policy   string `json:"policy"`
+	Severity string `json:"severity"`
+	Reason   string `json:"reason"`
+}
+
+// Normalize — проверяет и приводит поля к каноническому виду (вызывать перед сохранением)
+func (p *Policy) Normalize() error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	p.Mode = strings.ToLower(strings.TrimSpace(p.Mode))
+	if p.Mode == "" {
+		p.Mode = ModeAllow
+	}
+	if p.Mode != ModeAllow && p.Mode != ModeDeny {
+		return fmt.Errorf("invalid mode: %s", p.Mode)
+	}
+
+	p.Severity = strings.ToLower(strings.TrimSpace(p.Severity))
+	if p.Severity == "" {
+		p.Severity = "medium"
+	}
+	if SeverityRank(p.Severity) == 0 {
+		return fmt.Errorf("invalid severity: %s", p.Severity)
+	}
+
+	p.ScopeType = strings.ToLower(strings.TrimSpace(p.ScopeType))
+	p.Scope = strings.TrimSpace(p.Scope)
+	switch p.ScopeType {
+	case "", ScopeGlobal:
+		p.ScopeType, p.Scope = ScopeGlobal, ""
+	case ScopeCIDR, ScopeHost:
+		c, err := netutil.NormalizeCIDR(p.Scope)
+		if err != nil {
+			return err
+		}
+		if p.ScopeType == ScopeHost && !strings.HasSuffix(c, "/32") && !strings.HasSuffix(c, "/128") {
+			return fmt.Errorf("host scope must be a single IP: %s", p.Scope)
+		}
+		p.Scope = c
+	case ScopeGroup:
+		if p.Scope == "" {
+			return fmt.Errorf("group scope requires group name")
+		}
+	default:
+		return fmt.Errorf("invalid scope_type: %s", p.ScopeType)
+	}
+
+	ports, err := netutil.ParsePorts(p.Ports)
+	if err != nil {
+		return err
+	}
+	p.Ports = ports.String()
+
+	svcs := make([]string, 0, len(p.Services))
+	for _, s := range p.Services {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			svcs = append(svcs, s)
+		}
+	}
+	p.Services = svcs
+
+	if p.Mode == ModeDeny && len(ports) == 0 && len(svcs) == 0 {
+		return fmt.Errorf("deny policy must list ports or services")
+	}
+
+	return nil
+}
+
+// compile — разбирает порты/сеть один раз перед проверками
+func (p *Policy) compile() {
+	if p.ports == nil {
+		p.ports, _ = netutil.ParsePorts(p.Ports)
+	}
+	if p.network == nil && (p.ScopeType == ScopeCIDR || p.ScopeType == ScopeHost) {
+		if c, err := netutil.NormalizeCIDR(p.Scope); err == nil {
+			_, p.network, _ = net.ParseCIDR(c)
+		}
+	}
+}
+
+func (p *Policy) applies(f Finding) bool {
+	switch p.ScopeType {
+	case ScopeGlobal:
+		return true
+	case ScopeCIDR, ScopeHost:
+		ip := net.ParseIP(f.IP)
+		return ip != nil && p.network != nil && p.network.Contains(ip)
+	case ScopeGroup:
+		for _, g := range f.Groups {
+			if g == p.Scope {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Policy) matches(f Finding) bool {
+	if p.ports.Contains(f.Port) {
+		return true
+	}
+	svc := strings.ToLower(f.Service)
+	for _, s := range p.Services {
+		if s == svc {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate — проверяет находку против всех включённых политик
+func Evaluate(pols []Policy, f Finding) []Violation {
+	var out []Violation
+
+	for i := range pols {
+		p := &pols[i]
+		if !p.Enabled {
+			continue
+		}
+		p.compile()
+		if !p.applies(f) {
+			continue
+		}
+
+		matched := p.matches(f)
+		var reason string
+		switch {
+		case p.Mode == ModeAllow && !matched:
+			reason = fmt.Sprintf("%d/%s (%s) is not in allowed set", f.Port, f.Proto, f.Service)
+		case p.Mode == ModeDeny && matched:
+			reason = fmt.Sprintf("%d/%s (%s) is denied", f.Port, f.Proto, f.Service)
+		default:
+			continue
+		}
+
+		out = append(out, Violation{
+			PolicyID: p.ID,
+			Policy:   p.Name,
+			Severity: p.Severity,
+			Reason:   reason,
+		})
+	}
+
+	return out
+}
+
+// MaxSeverity — самая высокая severity среди нарушений
+func MaxSeverity(vs []Violation) string {
+	best := ""
+	for _, v := range vs {
+		if SeverityRank(v.Severity) > SeverityRank(best) {
+			best = v.Severity
+		}
+	}
+	return best
+}
+
+// Status — compliant / violating
+func Status(vs []Violation) string {
+	if len(vs) > 0 {
+		return Violating
+	}
+	return Compliant
+}
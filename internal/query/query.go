@@ -0,0 +1,296 @@
+// Package query — язык поиска по находкам:
+//
+//	port:3389 AND NOT tag:vpn
+//	service:http title:"Grafana"
+//	net:10.20.0.0/16 first_seen:>7d
+//	banner:~"OpenSSH_7\."
+//	cert:example.com cert_expires:<30d
+//
+// Термы "поле:значение" объединяются AND (в т.ч. неявным — через пробел),
+// OR, NOT / "-" и скобками; AND связывает сильнее OR. Слово без поля
+// ищется по ip, порту, сервису и баннеру.
+//
+// HTTP-атрибуты (title, server) отдельно не хранятся и ищутся регулярным
+// выражением по сохранённому баннеру. Сканер шлёт HEAD, поэтому server есть
+// у HTTP-портов, а title — только в баннерах с телом ответа (импорт
+// masscan --banners). TLS-атрибуты (cert, cert_issuer, cert_expires)
+// берутся из сертификата, снятого при скане с TLS-портов.
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node — узел разобранного запроса
+type Node interface{ node() }
+
+type And struct{ L, R Node }
+type Or struct{ L, R Node }
+type Not struct{ X Node }
+
+// Term — "поле:[оп]значение"; Field пустой для свободного текста.
+// Op: "" | "=" | ">" | ">=" | "<" | "<=" | "~" (регулярное выражение)
+type Term struct {
+	Field  string
+	Op     string
+	Value  string
+	Quoted bool
+	Pos    int
+}
+
+func (And) node()  {}
+func (Or) node()   {}
+func (Not) node()  {}
+func (Term) node() {}
+
+// SyntaxError — ошибка с позицией (в байтах от начала запроса)
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query: at position %d: %s", e.Pos+1, e.Msg)
+}
+
+func errorf(pos int, format string, args ...any) error {
+	return &SyntaxError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+/* ========================= LEXER ========================= */
+
+type tokenKind int
+
+const (
+	tEOF tokenKind = iota
+	tLParen
+	tRParen
+	tAnd
+	tOr
+	tNot
+	tTerm
+)
+
+type token struct {
+	kind tokenKind
+	pos  int
+	term Term
+}
+
+var ops = []string{">=", "<=", ">", "<", "~", "="} // длинные раньше коротких
+
+func lex(s string) ([]token, error) {
+	var out []token
+	i := 0
+	for {
+		for i < len(s) && isSpace(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			return append(out, token{kind: tEOF, pos: i}), nil
+		}
+
+		start := i
+		switch c := s[i]; {
+		case c == '(':
+			out = append(out, token{kind: tLParen, pos: i})
+			i++
+			continue
+		case c == ')':
+			out = append(out, token{kind: tRParen, pos: i})
+			i++
+			continue
+		case c == '-' && i+1 < len(s) && !isSpace(s[i+1]) && !isDigit(s[i+1]):
+			out = append(out, token{kind: tNot, pos: i})
+			i++
+			continue
+		case c == '"':
+			v, n, err := readQuoted(s, i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, token{kind: tTerm, pos: start, term: Term{Value: v, Quoted: true, Pos: start}})
+			i = n
+			continue
+		}
+
+		j := i
+		for j < len(s) && !isSpace(s[j]) && s[j] != '(' && s[j] != ')' && s[j] != '"' {
+			j++
+		}
+		word := s[i:j]
+		i = j
+
+		switch word {
+		case "AND", "&&":
+			out = append(out, token{kind: tAnd, pos: start})
+			continue
+		case "OR", "||":
+			out = append(out, token{kind: tOr, pos: start})
+			continue
+		case "NOT":
+			out = append(out, token{kind: tNot, pos: start})
+			continue
+		}
+
+		t := Term{Value: word, Pos: start}
+		if k := strings.IndexByte(word, ':'); k > 0 {
+			t.Field = strings.ToLower(word[:k])
+			rest := word[k+1:]
+			for _, op := range ops {
+				if strings.HasPrefix(rest, op) {
+					t.Op, rest = op, rest[len(op):]
+					break
+				}
+			}
+			t.Value = rest
+			if rest == "" {
+				if i < len(s) && s[i] == '"' {
+					v, n, err := readQuoted(s, i)
+					if err != nil {
+						return nil, err
+					}
+					t.Value, t.Quoted = v, true
+					i = n
+				} else {
+					return nil, errorf(start, "missing value for %q", t.Field)
+				}
+			}
+		} else if i < len(s) && s[i] == '"' {
+			return nil, errorf(i, "unexpected quote after %q (did you mean %s:\"...\"?)", word, word)
+		}
+		out = append(out, token{kind: tTerm, pos: start, term: t})
+	}
+}
+
+// readQuoted — строка в кавычках с i; \" и \\ — экранирование,
+// остальные обратные слэши сохраняются (для регулярных выражений)
+func readQuoted(s string, i int) (string, int, error) {
+	start := i
+	i++ // "
+	var sb strings.Builder
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\'):
+			sb.WriteByte(s[i+1])
+			i += 2
+		case c == '"':
+			return sb.String(), i + 1, nil
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, errorf(start, "unterminated quoted string")
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+/* ========================= PARSER ========================= */
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+// Parse — разбор запроса в дерево
+func Parse(s string) (Node, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	if p.peek().kind == tEOF {
+		return nil, errorf(0, "empty query")
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tEOF {
+		if t.kind == tRParen {
+			return nil, errorf(t.pos, "unbalanced ')'")
+		}
+		return nil, errorf(t.pos, "unexpected token")
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+func (p *parser) next() token { t := p.toks[p.i]; p.i++; return t }
+
+func (p *parser) parseOr() (Node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tOr {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = Or{L: l, R: r}
+	}
+	return l, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tAnd:
+			p.next()
+		case tTerm, tNot, tLParen:
+			// неявный AND: "service:http title:x"
+		default:
+			return l, nil
+		}
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = And{L: l, R: r}
+	}
+}
+
+func (p *parser) parseNot() (Node, error) {
+	if p.peek().kind == tNot {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return Not{X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+	switch t.kind {
+	case tTerm:
+		return t.term, nil
+	case tLParen:
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tRParen {
+			return nil, errorf(t.pos, "missing ')' for '(' here")
+		}
+		return n, nil
+	case tEOF:
+		return nil, errorf(t.pos, "unexpected end of query")
+	case tRParen:
+		return nil, errorf(t.pos, "unexpected ')'")
+	default:
+		return nil, errorf(t.pos, "expected a term")
+	}
+}
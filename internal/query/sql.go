@@ -0,0 +1,419 @@
+package query
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/netutil"
+)
+
+// Compile — SQL-условие для WHERE над ports p JOIN hosts h.
+// Значения уходят только через arg (плейсхолдеры $N), в текст SQL не попадают.
+//
+// first_seen/last_seen: относительное значение — это давность,
+// "first_seen:>7d" = впервые замечен больше 7 дней назад,
+// "first_seen:<7d" или "first_seen:7d" — за последние 7 дней;
+// дата ("2024-05-01" или RFC3339) сравнивается как есть.
+func Compile(n Node, now time.Time, arg func(any) string) (string, error) {
+	c := &compiler{now: now, arg: arg}
+	return c.node(n)
+}
+
+// CompileString — Parse + Compile
+func CompileString(q string, now time.Time, arg func(any) string) (string, error) {
+	n, err := Parse(q)
+	if err != nil {
+		return "", err
+	}
+	return Compile(n, now, arg)
+}
+
+type compiler struct {
+	now time.Time
+	arg func(any) string
+}
+
+type fieldFunc func(c *compiler, t Term) (string, error)
+
+var fields map[string]fieldFunc
+
+func init() {
+	fields = map[string]fieldFunc{
+		"ip":           (*compiler).ip,
+		"net":          (*compiler).net,
+		"cidr":         (*compiler).net,
+		"port":         (*compiler).port,
+		"proto":        eqField("p.proto"),
+		"state":        eqField("p.state"),
+		"service":      (*compiler).service,
+		"banner":       (*compiler).banner,
+		"title":        bannerPattern(`<title>[^<]*`),
+		"server":       bannerPattern(`(^|\n)server:[^\r\n]*`),
+		"cert":         (*compiler).cert,
+		"cert_issuer":  textField("p.tls_issuer"),
+		"cert_expires": (*compiler).certExpires,
+		"os":           (*compiler).os,
+		"tag":          existsField("SELECT 1 FROM host_tags ht WHERE ht.host_id = h.id AND ht.tag = "),
+		"group":        existsField("SELECT 1 FROM host_groups hg WHERE hg.host_id = h.id AND hg.group_name = "),
+		"compliance":   (*compiler).compliance,
+		"first_seen":   timeField("p.first_seen"),
+		"last_seen":    timeField("p.last_seen"),
+	}
+}
+
+// Fields — поддерживаемые поля (для подсказок и ошибок)
+func Fields() []string {
+	out := make([]string, 0, len(fields))
+	for k := range fields {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (c *compiler) node(n Node) (string, error) {
+	switch n := n.(type) {
+	case And:
+		return c.binary(n.L, n.R, "AND")
+	case Or:
+		return c.binary(n.L, n.R, "OR")
+	case Not:
+		x, err := c.node(n.X)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + x + ")", nil
+	case Term:
+		if n.Field == "" {
+			return c.text(n)
+		}
+		f, ok := fields[n.Field]
+		if !ok {
+			return "", errorf(n.Pos, "unknown field %q (known: %s)", n.Field, strings.Join(Fields(), ", "))
+		}
+		return f(c, n)
+	default:
+		return "", errorf(0, "unsupported node %T", n)
+	}
+}
+
+func (c *compiler) binary(l, r Node, op string) (string, error) {
+	a, err := c.node(l)
+	if err != nil {
+		return "", err
+	}
+	b, err := c.node(r)
+	if err != nil {
+		return "", err
+	}
+	return "(" + a + " " + op + " " + b + ")", nil
+}
+
+func onlyOps(t Term, allowed ...string) error {
+	for _, op := range allowed {
+		if t.Op == op {
+			return nil
+		}
+	}
+	return errorf(t.Pos, "operator %q is not supported for %s", t.Op, t.Field)
+}
+
+// regex — проверка выражения; синтаксис Go RE2 в основном совпадает с ARE Postgres
+func (c *compiler) regex(t Term, pattern string) (string, error) {
+	if _, err := regexp.Compile("(?i)" + pattern); err != nil {
+		return "", errorf(t.Pos, "invalid regular expression for %s: %v", t.Field, err)
+	}
+	return c.arg(pattern), nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (c *compiler) contains(v string) string {
+	return c.arg("%" + likeEscaper.Replace(v) + "%")
+}
+
+/* ========================= FIELDS ========================= */
+
+// text — слово без поля: ip, порт, сервис или баннер
+func (c *compiler) text(t Term) (string, error) {
+	if t.Op != "" {
+		return "", errorf(t.Pos, "operator %q needs a field", t.Op)
+	}
+	v := c.contains(t.Value)
+	conds := []string{
+		"host(h.ip) LIKE " + v,
+		"COALESCE(p.service, '') ILIKE " + v,
+		"p.banner ILIKE " + v,
+	}
+	if n, err := strconv.Atoi(t.Value); err == nil {
+		conds = append(conds, "p.port = "+c.arg(n))
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", nil
+}
+
+func (c *compiler) ip(t Term) (string, error) {
+	if err := onlyOps(t, "", "="); err != nil {
+		return "", err
+	}
+	cidr, err := netutil.NormalizeCIDR(t.Value)
+	if err != nil {
+		return "", errorf(t.Pos, "invalid ip %q", t.Value)
+	}
+	if strings.Contains(t.Value, "/") {
+		return "h.ip <<= " + c.arg(cidr) + "::inet", nil
+	}
+	return "h.ip = " + c.arg(t.Value) + "::inet", nil
+}
+
+func (c *compiler) net(t Term) (string, error) {
+	if err := onlyOps(t, ""); err != nil {
+		return "", err
+	}
+	cidr, err := netutil.NormalizeCIDR(t.Value)
+	if err != nil {
+		return "", errorf(t.Pos, "invalid network %q", t.Value)
+	}
+	return "h.ip <<= " + c.arg(cidr) + "::inet", nil
+}
+
+func (c *compiler) port(t Term) (string, error) {
+	switch t.Op {
+	case "", "=":
+		ps, err := netutil.ParsePorts(t.Value)
+		if err != nil || len(ps) == 0 {
+			return "", errorf(t.Pos, "invalid port %q", t.Value)
+		}
+		conds := make([]string, 0, len(ps))
+		for _, r := range ps {
+			if r.From == r.To {
+				conds = append(conds, "p.port = "+c.arg(r.From))
+			} else {
+				conds = append(conds, "p.port BETWEEN "+c.arg(r.From)+" AND "+c.arg(r.To))
+			}
+		}
+		return "(" + strings.Join(conds, " OR ") + ")", nil
+	case ">", ">=", "<", "<=":
+		n, err := strconv.Atoi(t.Value)
+		if err != nil || n < 0 || n > 65535 {
+			return "", errorf(t.Pos, "invalid port %q", t.Value)
+		}
+		return "p.port " + t.Op + " " + c.arg(n), nil
+	default:
+		return "", onlyOps(t, "", "=", ">", ">=", "<", "<=")
+	}
+}
+
+func eqField(col string) fieldFunc {
+	return func(c *compiler, t Term) (string, error) {
+		if err := onlyOps(t, "", "="); err != nil {
+			return "", err
+		}
+		return col + " = " + c.arg(strings.ToLower(t.Value)), nil
+	}
+}
+
+func existsField(sub string) fieldFunc {
+	return func(c *compiler, t Term) (string, error) {
+		if err := onlyOps(t, "", "="); err != nil {
+			return "", err
+		}
+		return "EXISTS (" + sub + c.arg(t.Value) + ")", nil
+	}
+}
+
+func (c *compiler) service(t Term) (string, error) {
+	col := "lower(COALESCE(p.service, 'unknown'))"
+	switch t.Op {
+	case "", "=":
+		return col + " = " + c.arg(strings.ToLower(t.Value)), nil
+	case "~":
+		re, err := c.regex(t, t.Value)
+		if err != nil {
+			return "", err
+		}
+		return col + " ~* " + re, nil
+	default:
+		return "", onlyOps(t, "", "=", "~")
+	}
+}
+
+func (c *compiler) banner(t Term) (string, error) {
+	switch t.Op {
+	case "":
+		return "p.banner ILIKE " + c.contains(t.Value), nil
+	case "=":
+		return "p.banner = " + c.arg(t.Value), nil
+	case "~":
+		re, err := c.regex(t, t.Value)
+		if err != nil {
+			return "", err
+		}
+		return "p.banner ~* " + re, nil
+	default:
+		return "", onlyOps(t, "", "=", "~")
+	}
+}
+
+// bannerPattern — HTTP-атрибуты, извлекаемые из сохранённого баннера
+// (отдельных колонок для title/server нет)
+func bannerPattern(prefix string) fieldFunc {
+	return func(c *compiler, t Term) (string, error) {
+		var pattern string
+		switch t.Op {
+		case "":
+			pattern = prefix + regexp.QuoteMeta(t.Value)
+		case "~":
+			pattern = prefix + "(" + t.Value + ")"
+		default:
+			return "", onlyOps(t, "", "~")
+		}
+		re, err := c.regex(t, pattern)
+		if err != nil {
+			return "", err
+		}
+		return "p.banner ~* " + re, nil
+	}
+}
+
+func (c *compiler) os(t Term) (string, error) {
+	if err := onlyOps(t, "", "="); err != nil {
+		return "", err
+	}
+	v := strings.ToLower(t.Value)
+	if v == "unknown" {
+		return "h.os_family IS NULL", nil
+	}
+	return "h.os_family = " + c.arg(v), nil
+}
+
+func (c *compiler) compliance(t Term) (string, error) {
+	if err := onlyOps(t, "", "="); err != nil {
+		return "", err
+	}
+	v := strings.ToLower(t.Value)
+	if v == "unchecked" {
+		return "p.compliance IS NULL", nil
+	}
+	return "p.compliance = " + c.arg(v), nil
+}
+
+func timeField(col string) fieldFunc {
+	return func(c *compiler, t Term) (string, error) {
+		if err := onlyOps(t, "", "=", ">", ">=", "<", "<="); err != nil {
+			return "", err
+		}
+
+		// давность: 7d, 12h, 30m
+		if age, ok := parseAge(t.Value); ok {
+			ts := c.arg(c.now.Add(-age))
+			switch t.Op {
+			case ">": // старше
+				return col + " < " + ts, nil
+			case ">=":
+				return col + " <= " + ts, nil
+			case "<=":
+				return col + " >= " + ts, nil
+			default: // "", "=", "<" — за последние age
+				return col + " > " + ts, nil
+			}
+		}
+
+		if cond, ok := c.date(col, t); ok {
+			return cond, nil
+		}
+		return "", errorf(t.Pos, "invalid time %q for %s (age like 7d/12h or date 2006-01-02)", t.Value, t.Field)
+	}
+}
+
+// date — сравнение с абсолютной датой ("2006-01-02" — весь день) или RFC3339
+func (c *compiler) date(col string, t Term) (string, bool) {
+	if d, err := time.Parse("2006-01-02", t.Value); err == nil {
+		if t.Op == "" || t.Op == "=" {
+			return "(" + col + " >= " + c.arg(d) + " AND " + col + " < " + c.arg(d.Add(24*time.Hour)) + ")", true
+		}
+		return col + " " + t.Op + " " + c.arg(d), true
+	}
+	if ts, err := time.Parse(time.RFC3339, t.Value); err == nil {
+		op := t.Op
+		if op == "" {
+			op = "="
+		}
+		return col + " " + op + " " + c.arg(ts), true
+	}
+	return "", false
+}
+
+// cert — субъект или DNS-имя сертификата TLS-порта ("=" — точное совпадение)
+func (c *compiler) cert(t Term) (string, error) {
+	switch t.Op {
+	case "":
+		v := c.contains(t.Value)
+		return "(p.tls_subject ILIKE " + v + " OR array_to_string(p.tls_dns_names, ' ') ILIKE " + v + ")", nil
+	case "=":
+		v := c.arg(t.Value)
+		return "(p.tls_subject = " + v + " OR " + v + " = ANY(p.tls_dns_names))", nil
+	default:
+		return "", onlyOps(t, "", "=")
+	}
+}
+
+func textField(col string) fieldFunc {
+	return func(c *compiler, t Term) (string, error) {
+		switch t.Op {
+		case "":
+			return col + " ILIKE " + c.contains(t.Value), nil
+		case "=":
+			return col + " = " + c.arg(t.Value), nil
+		default:
+			return "", onlyOps(t, "", "=")
+		}
+	}
+}
+
+// certExpires — срок сертификата; относительное значение — время до
+// истечения: "cert_expires:<30d" (или "30d") — истекает в ближайшие 30 дней
+// либо уже истёк, "cert_expires:>30d" — действует дольше
+func (c *compiler) certExpires(t Term) (string, error) {
+	const col = "p.tls_not_after"
+	if err := onlyOps(t, "", "=", ">", ">=", "<", "<="); err != nil {
+		return "", err
+	}
+	if left, ok := parseAge(t.Value); ok {
+		ts := c.arg(c.now.Add(left))
+		switch t.Op {
+		case ">", ">=", "<=":
+			return col + " " + t.Op + " " + ts, nil
+		default: // "", "=", "<"
+			return col + " < " + ts, nil
+		}
+	}
+	if cond, ok := c.date(col, t); ok {
+		return cond, nil
+	}
+	return "", errorf(t.Pos, "invalid time %q for %s (time left like 30d or date 2006-01-02)", t.Value, t.Field)
+}
+
+func parseAge(v string) (time.Duration, bool) {
+	if n, ok := strings.CutSuffix(v, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil || days < 0 {
+			return 0, false
+		}
+		return time.Duration(days) * 24 * time.Hour, true
+	}
+	if n, ok := strings.CutSuffix(v, "w"); ok {
+		weeks, err := strconv.Atoi(n)
+		if err != nil || weeks < 0 {
+			return 0, false
+		}
+		return time.Duration(weeks) * 7 * 24 * time.Hour, true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
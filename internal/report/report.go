@@ -0,0 +1,104 @@
+// Package report — HTML-отчёт о поверхности атаки: один файл со встроенным
+// CSS, который можно открыть в браузере или приложить к письму.
+package report
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+//go:embed report.html
+var reportHTML string
+
+var tmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04") },
+	"pct": func(n, max int) int {
+		if max <= 0 {
+			return 0
+		}
+		return n * 100 / max
+	},
+	"more": func(total, shown int) int { return total - shown },
+	"days": func(from, to time.Time) int { return int(to.Sub(from).Hours() / 24) },
+}).Parse(reportHTML))
+
+// DefaultPeriod — период первого отчёта, когда предыдущего нет
+const DefaultPeriod = 7 * 24 * time.Hour
+
+// Options — параметры генерации
+type Options struct {
+	From, To  time.Time // zero From — с конца прошлого отчёта; zero To — сейчас
+	Kind      string    // manual | daily | weekly
+	CreatedBy string
+	Save      bool // сохранить в reports (и сдвинуть «с прошлого отчёта»)
+}
+
+// Generate — собирает данные, рендерит HTML и (опционально) сохраняет
+func Generate(pg *storage.Postgres, opts Options) (*storage.Report, []byte, error) {
+	to := opts.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := opts.From
+	if from.IsZero() {
+		last, err := pg.LastReportEnd()
+		if err != nil {
+			return nil, nil, err
+		}
+		from = last
+		if from.IsZero() || !from.Before(to) {
+			from = to.Add(-DefaultPeriod)
+		}
+	}
+
+	d, err := pg.BuildReportData(from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	if err := Render(&buf, d); err != nil {
+		return nil, nil, err
+	}
+
+	kind := opts.Kind
+	if kind == "" {
+		kind = "manual"
+	}
+	r := &storage.Report{
+		Kind:       kind,
+		CreatedBy:  opts.CreatedBy,
+		PeriodFrom: d.From,
+		PeriodTo:   d.To,
+		Summary:    d.Summary,
+	}
+	if opts.Save {
+		if err := pg.SaveReport(r, buf.String()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return r, buf.Bytes(), nil
+}
+
+// Render — HTML-отчёт по готовым данным
+func Render(w io.Writer, d *storage.ReportData) error {
+	maxSvc := 0
+	for _, s := range d.TopServices {
+		if s.Count > maxSvc {
+			maxSvc = s.Count
+		}
+	}
+	return tmpl.Execute(w, struct {
+		*storage.ReportData
+		MaxService int
+	}{d, maxSvc})
+}
+
+// FileName — имя файла отчёта для Content-Disposition и вложений
+func FileName(r *storage.Report) string {
+	return "exposure-report-" + r.PeriodTo.UTC().Format("20060102-1504") + ".html"
+}
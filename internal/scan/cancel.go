@@ -6,20 +6,37 @@ import (
 )
 
 type cancelState struct {
-	mu     sync.Mutex
-	cancel context.CancelFunc
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	scanID  string
+	targets []string // как заданы при запуске (могут быть group:<name>)
 }
 
-func (r *Runner) setCancel(fn context.CancelFunc) {
+func (r *Runner) setCancel(fn context.CancelFunc, scanID string, targets []string) {
 	r.muCancel.mu.Lock()
 	defer r.muCancel.mu.Unlock()
 	r.muCancel.cancel = fn
+	r.muCancel.scanID = scanID
+	r.muCancel.targets = append([]string(nil), targets...)
 }
 
 func (r *Runner) clearCancel() {
 	r.muCancel.mu.Lock()
 	defer r.muCancel.mu.Unlock()
 	r.muCancel.cancel = nil
+	r.muCancel.scanID = ""
+	r.muCancel.targets = nil
+}
+
+// Running — ID и цели текущего скана (для проверки прав перед отменой);
+// пустые цели — автоопределение локальных сетей
+func (r *Runner) Running() (scanID string, targets []string, ok bool) {
+	r.muCancel.mu.Lock()
+	defer r.muCancel.mu.Unlock()
+	if r.muCancel.cancel == nil {
+		return "", nil, false
+	}
+	return r.muCancel.scanID, append([]string(nil), r.muCancel.targets...), true
 }
 
 func (r *Runner) IsRunning() bool {
@@ -32,18 +49,27 @@ func (r *Runner) IsRunning() bool {
 CancelRunning — безопасно останавливает текущий скан
 */
 func (r *Runner) CancelRunning() bool {
+	return r.CancelScan("")
+}
+
+// CancelScan — отмена, только если идёт именно скан scanID ("" — любой):
+// между проверкой прав по Running и отменой мог стартовать другой скан
+func (r *Runner) CancelScan(scanID string) bool {
 	r.muCancel.mu.Lock()
 	defer r.muCancel.mu.Unlock()
 
 	if r.muCancel.cancel == nil {
 		return false
 	}
+	if scanID != "" && r.muCancel.scanID != scanID {
+		return false
+	}
 
 	r.muCancel.cancel()
 	r.muCancel.cancel = nil
 
 	if r.hub != nil {
-		r.hub.Publish(Progress{
+		r.hub.Publish(r.muCancel.scanID, EventProgress, Progress{
 			Percent: 100,
 			Message: "Scan cancelled by user",
 		})
@@ -0,0 +1,101 @@
+package scan
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/model"
+	"github.com/L1nMay/portscanner/internal/netutil"
+)
+
+// finishRun — пост-обработка сохранённого ScanRun: закрытые порты и сводка скана
+func (r *Runner) finishRun(ctx context.Context, run *model.ScanRun) {
+	if r.pg == nil {
+		return
+	}
+
+	// импорт ничего не говорит о закрытых портах
+	if run.Source != model.SourceImport {
+		r.markClosedPorts(ctx, run)
+	}
+
+	if err := r.pg.RecordObservations(run.ID, run.StartedAt); err != nil {
+		logger.Error(ctx, "record observations", "err", err)
+	}
+
+	if r.cfg.Notifications.Digest.PerScan {
+		r.emitScanDigest(ctx, run)
+	}
+}
+
+// markClosedPorts — всё, что входит в цели и порты скана, но не найдено им, закрыто
+func (r *Runner) markClosedPorts(ctx context.Context, run *model.ScanRun) {
+	ports, err := netutil.ParsePorts(run.PortsSpec)
+	if err != nil {
+		logger.Error(ctx, "closed ports: skip, cannot parse ports", "ports", run.PortsSpec, "err", err)
+		return
+	}
+
+	cidrs := make([]string, 0, len(r.cfg.Targets))
+	for _, t := range r.cfg.Targets {
+		// hostname-цели пропускаем: их адрес мог смениться
+		if c, err := netutil.NormalizeCIDR(t); err == nil {
+			cidrs = append(cidrs, c)
+		}
+	}
+
+	n, err := r.pg.MarkClosedPorts(cidrs, ports, "tcp", run.StartedAt)
+	if err != nil {
+		logger.Error(ctx, "closed ports", "err", err)
+		return
+	}
+	if n > 0 {
+		logger.Info(ctx, "closed ports", "count", n)
+	}
+}
+
+func (r *Runner) emitScanDigest(ctx context.Context, run *model.ScanRun) {
+	dc := r.cfg.Notifications.Digest
+
+	// +1s: closed_at/last_seen пишутся now() базы уже после run.FinishedAt
+	d, err := r.pg.BuildDigest(run.StartedAt, time.Now().Add(time.Second), dc.Top)
+	if err != nil {
+		logger.Error(ctx, "scan digest", "err", err)
+		return
+	}
+	d.Kind = "scan"
+	d.ScanID = run.ID
+	d.Engine = run.Engine
+	d.Targets = targetsLabel(r.cfg.Targets)
+
+	if !dc.SendEmpty && d.NewPorts+d.ClosedPorts+d.ChangedPorts+d.Violations == 0 {
+		return
+	}
+
+	payload, err := d.Payload()
+	if err != nil {
+		logger.Error(ctx, "scan digest", "err", err)
+		return
+	}
+	if err := r.pg.AddEvent("scan_digest", payload); err != nil {
+		logger.Error(ctx, "add scan_digest event", "err", err)
+	}
+}
+
+// withRun — атрибуты логов прогона: scan_id, engine, target (цели скана)
+func withRun(ctx context.Context, run *model.ScanRun, targets []string) context.Context {
+	return logger.With(ctx, "scan_id", run.ID, "engine", run.Engine, "target", targetsLabel(targets))
+}
+
+func targetsLabel(targets []string) string {
+	switch len(targets) {
+	case 0:
+		return ""
+	case 1:
+		return targets[0]
+	default:
+		return targets[0] + " (+" + strconv.Itoa(len(targets)-1) + ")"
+	}
+}
@@ -0,0 +1,116 @@
+package scan
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/config"
+	"github.com/L1nMay/portscanner/internal/netutil"
+)
+
+// nmapProbeRate — условная скорость nmap -sT без --max-rate (проб/с):
+// для оценки, не для запуска
+const nmapProbeRate = 500
+
+// Условное число открытых портов для оценки фазы баннеров (реальное заранее
+// не знает никто): 1% проб, но не больше 20 на адрес
+const (
+	expectedOpenPerMille   = 10
+	expectedOpenPerHostMax = 20
+)
+
+// countAddresses — адресов в целях: IP и hostname — 1, CIDR — размер сети
+// (пересечения не вычитаются; огромные IPv6-сети — math.MaxInt64)
+func countAddresses(targets []string) int64 {
+	var total int64
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		n := int64(1)
+		if _, ipnet, err := net.ParseCIDR(t); err == nil {
+			ones, bits := ipnet.Mask.Size()
+			if bits-ones >= 63 {
+				return math.MaxInt64
+			}
+			n = int64(1) << (bits - ones)
+		}
+		if total > math.MaxInt64-n {
+			return math.MaxInt64
+		}
+		total += n
+	}
+	return total
+}
+
+// mulSat — a*b без переполнения
+func mulSat(a, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	if a > math.MaxInt64/b {
+		return math.MaxInt64
+	}
+	return a * b
+}
+
+// estimate — объём и длительность плана: время движка (для nmap — по условной
+// скорости) + ожидание + баннеры (ожидаемые открытые порты × худший случай на
+// порт ÷ banner_concurrency); предупреждения по plan_limits. Спецификацию
+// портов, которую оценка не понимает (имена сервисов nmap), скан всё равно
+// примет: тогда оценка «unknown» с предупреждением, а не ошибка.
+func (p *ScanPlan) estimate(cfg *config.Config) {
+	p.Addresses = countAddresses(p.Targets)
+
+	p.Rate = cfg.Rate
+	if p.Engine != "masscan" {
+		p.Rate = nmapProbeRate
+	}
+	if p.Rate <= 0 {
+		p.Rate = 1
+	}
+	p.BannerConcurrency = max(cfg.BannerConcurrency, 1)
+	p.BannerSecondsPerPort = (cfg.ConnectTimeout() + cfg.ReadTimeout()).Seconds()
+
+	lim := cfg.PlanLimits
+	if lim.MaxAddresses > 0 && p.Addresses > lim.MaxAddresses {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%d addresses exceed plan_limits.max_addresses (%d)", p.Addresses, lim.MaxAddresses))
+	}
+	if lim.MaxRate > 0 && p.Engine == "masscan" && cfg.Rate > lim.MaxRate {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("rate %d pps exceeds plan_limits.max_rate (%d)", cfg.Rate, lim.MaxRate))
+	}
+
+	ps, err := netutil.ParseScanPorts(p.Ports)
+	if err != nil {
+		p.Estimated = "unknown"
+		p.Warnings = append(p.Warnings, fmt.Sprintf("cannot estimate port spec %q: %v", p.Ports, err))
+		return
+	}
+	p.PortCount = ps.Count()
+	p.Probes = mulSat(p.Addresses, int64(p.PortCount))
+
+	engine := math.Ceil(float64(p.Probes) / float64(p.Rate))
+	p.ExpectedOpenPorts = min(
+		int64(math.Ceil(float64(p.Probes)*expectedOpenPerMille/1000)),
+		mulSat(p.Addresses, expectedOpenPerHostMax),
+	)
+	p.BannerSeconds = math.Ceil(float64(p.ExpectedOpenPorts) * p.BannerSecondsPerPort / float64(p.BannerConcurrency))
+	p.EstimatedSeconds = engine + float64(p.WaitSeconds) + p.BannerSeconds
+	p.Estimated = formatSeconds(p.EstimatedSeconds)
+
+	if lim.MaxProbes > 0 && p.Probes > lim.MaxProbes {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%d probes exceed plan_limits.max_probes (%d)", p.Probes, lim.MaxProbes))
+	}
+	if lim.MaxDurationSec > 0 && p.EstimatedSeconds > float64(lim.MaxDurationSec) {
+		p.Warnings = append(p.Warnings, fmt.Sprintf("estimated %s exceeds plan_limits.max_duration_seconds (%ds)", p.Estimated, lim.MaxDurationSec))
+	}
+}
+
+// formatSeconds — "4h37m0s"; за пределами time.Duration — в годах
+func formatSeconds(sec float64) string {
+	if sec >= float64(math.MaxInt64/int64(time.Second)) {
+		return fmt.Sprintf("~%.0f years", sec/(365*24*3600))
+	}
+	return (time.Duration(sec) * time.Second).String()
+}
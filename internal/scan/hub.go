@@ -2,7 +2,28 @@ package scan
 
 import (
 	"encoding/json"
+	"sort"
 	"sync"
+	"time"
+)
+
+// Типы событий потока скана (SSE event:)
+const (
+	EventProgress = "progress" // Progress
+	EventPhase    = "phase"    // Phase
+	EventLog      = "log"      // LogEntry — строка журнала скана
+	EventFinding  = "finding"  // Finding — как только порт записан
+	EventDone     = "done"     // Done — последнее событие скана
+
+	// только в SSE, не в буфере Hub (без id):
+	EventStatus = "status" // Done — скан running в БД, но идёт не в этом процессе
+	EventReset  = "reset"  // Reset — часть событий уже вытеснена из буфера
+)
+
+const (
+	hubRingSize  = 4096 // событий на скан
+	hubKeepScans = 16   // сканов с буфером событий
+	hubSubBuffer = 256  // очередь подписчика; переполнение — отключение
 )
 
 type Progress struct {
@@ -10,38 +31,210 @@ type Progress struct {
 	Message string `json:"message"`
 }
 
+// Phase — переход между фазами: preflight, engine, banners, finish
+type Phase struct {
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// LogEntry — строка журнала скана (как в /api/scans/{id}/log)
+type LogEntry struct {
+	Time    time.Time `json:"ts"`
+	Level   string    `json:"level"`
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+}
+
+// Finding — открытый порт, записанный в БД во время скана или импорта;
+// New — порт раньше не встречался
+type Finding struct {
+	IP      string `json:"ip"`
+	Port    int    `json:"port"`
+	Proto   string `json:"proto"`
+	Service string `json:"service"`
+	Banner  string `json:"banner,omitempty"`
+	New     bool   `json:"new"`
+}
+
+// Done — итог скана
+type Done struct {
+	Status  string `json:"status"` // finished | failed | cancelled
+	Message string `json:"message"`
+	Error   string `json:"error,omitempty"`
+	Found   int    `json:"found"`
+	New     int    `json:"new"`
+}
+
+// Reset — клиент отстал сильнее буфера: пропущенное нужно перечитать из
+// /api/results и /api/scans/{id}/log
+type Reset struct {
+	Message string `json:"message"`
+}
+
+// Event — событие скана; ID сквозной по процессу и растёт монотонно,
+// это SSE id для Last-Event-ID
+type Event struct {
+	ID     uint64          `json:"id"`
+	ScanID string          `json:"scan_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Subscription — подписка на события одного скана ("" — всех). C закрывается
+// при Unsubscribe или если подписчик не успевает читать: клиент
+// переподключается с последним ID и дочитывает пропущенное из буфера.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	scanID string
+}
+
+// scanRing — последние события скана
+type scanRing struct {
+	events  []Event
+	done    bool
+	evicted uint64 // ID последнего вытесненного события
+}
+
 type Hub struct {
-	mu   sync.Mutex
-	subs map[chan []byte]struct{}
+	mu    sync.Mutex
+	seq   uint64
+	rings map[string]*scanRing
+	order []string // сканы в порядке появления, для вытеснения
+	subs  map[*Subscription]struct{}
+	last  Progress
 }
 
 func NewHub() *Hub {
-	return &Hub{subs: make(map[chan []byte]struct{})}
+	return &Hub{
+		// ID от времени старта: после рестарта Last-Event-ID клиента меньше
+		// новых и не «съедает» события
+		seq:   uint64(time.Now().UnixMicro()),
+		rings: make(map[string]*scanRing),
+		subs:  make(map[*Subscription]struct{}),
+	}
 }
 
-func (h *Hub) Subscribe() chan []byte {
-	ch := make(chan []byte, 64)
+// Open — буфер скана до первого события, чтобы поток /api/scans/{id}/stream
+// можно было открыть сразу после запуска
+func (h *Hub) Open(scanID string) {
 	h.mu.Lock()
-	h.subs[ch] = struct{}{}
+	h.ring(scanID)
 	h.mu.Unlock()
-	return ch
 }
 
-func (h *Hub) Unsubscribe(ch chan []byte) {
+// Known — есть ли буфер скана; done — скан завершился
+func (h *Hub) Known(scanID string) (known, done bool) {
 	h.mu.Lock()
-	delete(h.subs, ch)
-	h.mu.Unlock()
-	close(ch)
+	defer h.mu.Unlock()
+	r, ok := h.rings[scanID]
+	if !ok {
+		return false, false
+	}
+	return true, r.done
+}
+
+func (h *Hub) ring(scanID string) *scanRing {
+	if r, ok := h.rings[scanID]; ok {
+		return r
+	}
+	r := &scanRing{}
+	h.rings[scanID] = r
+	h.order = append(h.order, scanID)
+	if len(h.order) > hubKeepScans {
+		delete(h.rings, h.order[0])
+		h.order = h.order[1:]
+	}
+	return r
 }
 
-func (h *Hub) Publish(p Progress) {
-	b, _ := json.Marshal(p)
+// Subscribe — подписка и события после after (0 — весь буфер) одним шагом,
+// без пропусков между ними; scanID "" — все сканы. gap — часть событий
+// после after уже вытеснена из буфера скана.
+func (h *Hub) Subscribe(scanID string, after uint64) (sub *Subscription, replay []Event, gap bool) {
+	ch := make(chan Event, hubSubBuffer)
+	sub = &Subscription{C: ch, ch: ch, scanID: scanID}
+
 	h.mu.Lock()
-	for ch := range h.subs {
+	defer h.mu.Unlock()
+
+	for _, id := range h.order {
+		if scanID != "" && id != scanID {
+			continue
+		}
+		if h.rings[id].evicted > after {
+			gap = true
+		}
+		for _, e := range h.rings[id].events {
+			if e.ID > after {
+				replay = append(replay, e)
+			}
+		}
+	}
+	if scanID == "" {
+		// буферы разных сканов перемежаются
+		sort.Slice(replay, func(i, j int) bool { return replay[i].ID < replay[j].ID })
+	}
+
+	h.subs[sub] = struct{}{}
+	return sub, replay, gap
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish — событие скана в буфер и подписчикам; отстающий подписчик
+// отключается, а не теряет события молча
+func (h *Hub) Publish(scanID, typ string, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	e := Event{ID: h.seq, ScanID: scanID, Type: typ, Data: b}
+
+	if p, ok := v.(Progress); ok {
+		h.last = p
+	}
+	if scanID != "" {
+		r := h.ring(scanID)
+		r.events = append(r.events, e)
+		if len(r.events) > hubRingSize {
+			cut := len(r.events) - hubRingSize
+			r.evicted = r.events[cut-1].ID
+			r.events = r.events[cut:]
+		}
+		if typ == EventDone {
+			r.done = true
+		}
+	}
+
+	for sub := range h.subs {
+		if sub.scanID != "" && sub.scanID != scanID {
+			continue
+		}
 		select {
-		case ch <- b:
+		case sub.ch <- e:
 		default:
+			delete(h.subs, sub)
+			close(sub.ch)
 		}
 	}
-	h.mu.Unlock()
+}
+
+// Last — последнее опубликованное состояние (для /status и т.п.)
+func (h *Hub) Last() Progress {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
 }
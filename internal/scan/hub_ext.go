@@ -1,17 +1,98 @@
 package scan
 
-// HubSubscribe позволяет WebUI подписаться на прогресс сканирования (SSE)
-func (r *Runner) HubSubscribe() chan []byte {
-	if r.hub == nil {
-		return nil
-	}
-	return r.hub.Subscribe()
+import (
+	"context"
+	"encoding/json"
+)
+
+// Subscribe — события скана scanID ("" — всех сканов) после after для SSE;
+// replay — то, что уже есть в буфере; gap — часть событий уже вытеснена
+func (r *Runner) Subscribe(scanID string, after uint64) (sub *Subscription, replay []Event, gap bool) {
+	return r.hub.Subscribe(scanID, after)
 }
 
-// HubUnsubscribe отписывает клиента от SSE
-func (r *Runner) HubUnsubscribe(ch chan []byte) {
-	if r.hub == nil || ch == nil {
+// Unsubscribe отписывает клиента от SSE
+func (r *Runner) Unsubscribe(sub *Subscription) {
+	if sub == nil {
 		return
 	}
-	r.hub.Unsubscribe(ch)
+	r.hub.Unsubscribe(sub)
+}
+
+// StreamState — есть ли у скана буфер событий в этом процессе и завершён ли он
+func (r *Runner) StreamState(scanID string) (known, done bool) {
+	return r.hub.Known(scanID)
+}
+
+// WaitDone — ждёт итог скана; при отключении за отставание
+// переподписывается с последнего полученного события
+func (r *Runner) WaitDone(ctx context.Context, scanID string) (Done, error) {
+	var after uint64
+	for {
+		sub, replay, _ := r.hub.Subscribe(scanID, after)
+		for _, e := range replay {
+			if d, ok := doneOf(e); ok {
+				r.hub.Unsubscribe(sub)
+				return d, nil
+			}
+			after = e.ID
+		}
+
+	read:
+		for {
+			select {
+			case <-ctx.Done():
+				r.hub.Unsubscribe(sub)
+				return Done{}, ctx.Err()
+			case e, ok := <-sub.C:
+				if !ok {
+					break read
+				}
+				if d, ok := doneOf(e); ok {
+					r.hub.Unsubscribe(sub)
+					return d, nil
+				}
+				after = e.ID
+			}
+		}
+	}
+}
+
+func doneOf(e Event) (Done, bool) {
+	var d Done
+	if e.Type != EventDone || json.Unmarshal(e.Data, &d) != nil {
+		return Done{}, false
+	}
+	return d, true
+}
+
+// LastProgress — последний прогресс текущего (или завершившегося) скана
+func (r *Runner) LastProgress() Progress {
+	if r.hub == nil {
+		return Progress{}
+	}
+	return r.hub.Last()
+}
+
+type scanIDKey struct{}
+
+// withScanID — ID скана, под которым публикуются события (задаётся до
+// запуска, чтобы API сразу вернул его клиенту)
+func withScanID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, scanIDKey{}, id)
+}
+
+func scanIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(scanIDKey{}).(string)
+	return id
+}
+
+// publish — событие текущего скана в Hub
+func (r *Runner) publish(ctx context.Context, typ string, v any) {
+	r.hub.Publish(scanIDFrom(ctx), typ, v)
+}
+
+// phase — событие смены фазы скана
+func (r *Runner) phase(ctx context.Context, name, detail string) {
+	r.publish(ctx, EventPhase, Phase{Name: name, Detail: detail})
 }
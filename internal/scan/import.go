@@ -0,0 +1,196 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/masscan"
+	"github.com/L1nMay/portscanner/internal/model"
+	"github.com/L1nMay/portscanner/internal/netutil"
+	"github.com/L1nMay/portscanner/internal/nmap"
+)
+
+// Форматы импорта
+const (
+	ImportNmapXML     = "nmap"
+	ImportMasscanJSON = "masscan-json"
+	ImportMasscanList = "masscan-list"
+)
+
+// ImportFormats — для подсказок в CLI/API ("" или "auto" — определить по содержимому)
+var ImportFormats = []string{ImportNmapXML, ImportMasscanJSON, ImportMasscanList}
+
+// ErrBadImport — файл не разобран (для 400)
+var ErrBadImport = errors.New("bad import file")
+
+// ErrImportScope — в файле есть адреса вне разрешённых сетей (для 403)
+var ErrImportScope = errors.New("import outside allowed networks")
+
+// ImportOptions — параметры импорта
+type ImportOptions struct {
+	Format string   // ImportFormats; "" или "auto" — по содержимому
+	Name   string   // имя файла, попадает в notes прогона
+	Scope  []string // разрешённые сети (allowed_cidrs токена); пусто — без ограничений
+}
+
+// DetectImportFormat — формат по первым байтам: <?xml/<nmaprun, [/{, #masscan/open
+func DetectImportFormat(head []byte) (string, error) {
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(head, []byte("<")):
+		return ImportNmapXML, nil
+	case bytes.HasPrefix(head, []byte("[")), bytes.HasPrefix(head, []byte("{")):
+		return ImportMasscanJSON, nil
+	case bytes.HasPrefix(head, []byte("#")),
+		bytes.HasPrefix(head, []byte("open ")),
+		bytes.HasPrefix(head, []byte("banner ")):
+		return ImportMasscanList, nil
+	}
+	return "", fmt.Errorf("%w: cannot detect format (%s)", ErrBadImport, strings.Join(ImportFormats, "|"))
+}
+
+// Import — результаты чужого сканера (nmap -oX, masscan -oJ/-oL) как
+// отдельный прогон с source=import. Находки идут тем же путём, что и при
+// скане: события new_port, политики, история наблюдений. Баннеры не
+// снимаются (сегмент может быть недоступен), закрытые порты не помечаются.
+func (r *Runner) Import(src io.Reader, opts ImportOptions) (*model.ScanRun, error) {
+	return r.ImportCtx(context.Background(), src, opts)
+}
+
+// ImportCtx — Import с атрибутами логов из ctx (request_id)
+func (r *Runner) ImportCtx(ctx context.Context, src io.Reader, opts ImportOptions) (*model.ScanRun, error) {
+	if r.pg == nil {
+		return nil, fmt.Errorf("import requires postgres")
+	}
+
+	br := bufio.NewReader(src)
+	format := opts.Format
+	if format == "" || format == "auto" {
+		head, _ := br.Peek(512)
+		f, err := DetectImportFormat(head)
+		if err != nil {
+			return nil, err
+		}
+		format = f
+	}
+
+	var (
+		found  []masscan.Result
+		engine string
+		osEv   = newOSEvidence()
+		err    error
+	)
+	switch format {
+	case ImportNmapXML:
+		engine = "nmap"
+		var nr []nmap.Result
+		if nr, err = nmap.ParseXML(br); err == nil {
+			seenOS := map[string]struct{}{}
+			for _, rr := range nr {
+				osEv.addNmap(rr, seenOS)
+				found = append(found, masscan.Result{
+					IP: rr.IP, Port: rr.Port, Proto: rr.Proto, TTL: rr.TTL,
+					Service: rr.Service, Banner: rr.Banner,
+				})
+			}
+		}
+	case ImportMasscanJSON:
+		engine = "masscan"
+		found, err = masscan.ParseJSON(br)
+	case ImportMasscanList:
+		engine = "masscan"
+		found, err = masscan.ParseList(br)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q (%s)", ErrBadImport, format, strings.Join(ImportFormats, "|"))
+	}
+	if err != nil {
+		// оба %w: http.MaxBytesError под ним нужен API для 413
+		return nil, fmt.Errorf("%w: %w", ErrBadImport, err)
+	}
+	for _, fr := range found {
+		if net.ParseIP(normalizeIP(fr.IP)) == nil {
+			return nil, fmt.Errorf("%w: invalid ip %q", ErrBadImport, fr.IP)
+		}
+	}
+
+	// весь файл или ничего: частичный импорт сложно объяснить в отчёте
+	if len(opts.Scope) > 0 {
+		for _, fr := range found {
+			if !netutil.Within(fr.IP, opts.Scope) {
+				return nil, fmt.Errorf("%w: %s", ErrImportScope, fr.IP)
+			}
+		}
+	}
+
+	// импорт пишет в те же таблицы — не пересекаемся со сканом
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run := &model.ScanRun{
+		ID:        newUUID(),
+		StartedAt: time.Now().UTC(),
+		Engine:    engine,
+		Source:    model.SourceImport,
+		Notes:     strings.TrimSpace("import " + format + " " + opts.Name),
+	}
+
+	ctx, rl := r.startRunLog(ctx, run.ID)
+	defer rl.close()
+	ctx = logger.With(ctx, "engine", engine)
+	in := r.newIngest(ctx, osEv)
+	seen := map[string]struct{}{}
+	var hosts []string
+
+	for _, fr := range found {
+		if fr.IP == "" {
+			continue
+		}
+		proto := strings.ToLower(fr.Proto)
+		key := fmt.Sprintf("%s:%d/%s", fr.IP, fr.Port, proto)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		if _, ok := seen[fr.IP]; !ok {
+			seen[fr.IP] = struct{}{}
+			hosts = append(hosts, fr.IP)
+		}
+		seen[key] = struct{}{}
+		run.Found++
+
+		svc := strings.ToLower(fr.Service)
+		if svc == "" {
+			svc = "unknown"
+		}
+		osEv.addTTL(fr.IP, fr.TTL)
+		osEv.addBanner(fr.IP, int(fr.Port), svc, fr.Banner)
+
+		isNew, err := r.storeFinding(in, fr.IP, int(fr.Port), proto, svc, fr.Banner)
+		if err != nil {
+			logger.Error(ctx, "import: store finding", "target", key, "err", err)
+			continue
+		}
+		if isNew {
+			run.NewFound++
+		}
+	}
+
+	r.storeOSGuesses(osEv)
+
+	run.TargetsCount = len(hosts)
+	run.FinishedAt = time.Now().UTC()
+	if err := r.pg.AddScanRun(run, hosts); err != nil {
+		return nil, err
+	}
+	r.finishRun(ctx, run)
+
+	logger.Info(ctx, "import finished", "format", format, "hosts", len(hosts), "found", run.Found, "new", run.NewFound)
+	return run, nil
+}
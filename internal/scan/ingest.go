@@ -0,0 +1,63 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/policy"
+)
+
+// ingest — состояние одного прогона (скан или импорт) для записи находок
+type ingest struct {
+	ctx    context.Context // атрибуты логов прогона (scan_id, engine)
+	pols   []policy.Policy
+	assets map[string]hostAssets
+	osEv   *osEvidence
+}
+
+func (r *Runner) newIngest(ctx context.Context, osEv *osEvidence) *ingest {
+	return &ingest{
+		ctx:    ctx,
+		pols:   r.loadPolicies(),
+		assets: map[string]hostAssets{},
+		osEv:   osEv,
+	}
+}
+
+// storeFinding — единый путь записи находки в Postgres: хост, порт,
+// событие new_port, проверка политик и событие finding в поток скана.
+// true — порт новый.
+func (r *Runner) storeFinding(in *ingest, rawIP string, port int, proto, svc, bnr string) (bool, error) {
+	ip := normalizeIP(rawIP)
+
+	hostID, err := r.pg.UpsertHost(ip)
+	if err != nil {
+		return false, fmt.Errorf("upsert host %s: %w", ip, err)
+	}
+	in.osEv.setHost(ip, hostID)
+
+	isNew, err := r.pg.UpsertPort(hostID, port, proto, svc, bnr)
+	if err != nil {
+		return false, fmt.Errorf("upsert port %s:%d: %w", ip, port, err)
+	}
+
+	a := r.hostAssetsCached(in.assets, ip)
+
+	if isNew {
+		if err := r.pg.AddEvent("new_port", map[string]any{
+			"ip":      rawIP,
+			"port":    port,
+			"service": svc,
+			"tags":    a.tags,
+			"groups":  a.groups,
+		}); err != nil {
+			logger.Error(in.ctx, "add new_port event", "target", fmt.Sprintf("%s:%d", ip, port), "err", err)
+		}
+	}
+
+	r.checkPolicies(in.pols, hostID, ip, port, proto, svc, a)
+
+	r.publish(in.ctx, EventFinding, Finding{IP: ip, Port: port, Proto: proto, Service: svc, Banner: bnr, New: isNew})
+	return isNew, nil
+}
@@ -0,0 +1,51 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/metrics"
+	"github.com/L1nMay/portscanner/internal/model"
+)
+
+var (
+	scanDuration = metrics.NewHistogramVec(
+		"portscanner_scan_duration_seconds",
+		"Scan duration by engine and status (success, failed, cancelled).",
+		[]float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200},
+		"engine", "status",
+	)
+	scanFindings = metrics.NewHistogramVec(
+		"portscanner_scan_findings",
+		"Open ports found per scan.",
+		[]float64{0, 1, 5, 10, 50, 100, 500, 1000, 5000, 10000},
+		"engine",
+	)
+	scanNewFindings = metrics.NewHistogramVec(
+		"portscanner_scan_new_findings",
+		"Ports seen for the first time per scan.",
+		[]float64{0, 1, 5, 10, 50, 100, 500, 1000},
+		"engine",
+	)
+)
+
+// observeScan — метрики завершённого (или упавшего) скана
+func observeScan(run *model.ScanRun, started time.Time, err error) {
+	engine, status := "unknown", "success"
+	if run != nil && run.Engine != "" {
+		engine = run.Engine
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		status = "cancelled"
+	case err != nil:
+		status = "failed"
+	}
+	scanDuration.Observe(time.Since(started).Seconds(), engine, status)
+
+	if err == nil && run != nil {
+		scanFindings.Observe(float64(run.Found), engine)
+		scanNewFindings.Observe(float64(run.NewFound), engine)
+	}
+}
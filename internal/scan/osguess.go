@@ -0,0 +1,87 @@
+package scan
+
+import (
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/nmap"
+	"github.com/L1nMay/portscanner/internal/osguess"
+)
+
+// osEvidence — признаки ОС по хостам, накопленные за один прогон
+type osEvidence struct {
+	byIP   map[string][]osguess.Evidence
+	hostID map[string]int64
+}
+
+func newOSEvidence() *osEvidence {
+	return &osEvidence{
+		byIP:   map[string][]osguess.Evidence{},
+		hostID: map[string]int64{},
+	}
+}
+
+func (e *osEvidence) add(ip string, ev osguess.Evidence, ok bool) {
+	if !ok {
+		return
+	}
+	ip = normalizeIP(ip)
+	e.byIP[ip] = append(e.byIP[ip], ev)
+}
+
+func (e *osEvidence) addTTL(ip string, ttl int) {
+	ev, ok := osguess.FromTTL(ttl)
+	e.add(ip, ev, ok)
+}
+
+func (e *osEvidence) addBanner(ip string, port int, service, banner string) {
+	ev, ok := osguess.FromBanner(port, service, banner)
+	e.add(ip, ev, ok)
+}
+
+// addNmap — OS match из XML-режима nmap (один раз на хост) + TTL порта
+func (e *osEvidence) addNmap(rr nmap.Result, seenOS map[string]struct{}) {
+	e.addTTL(rr.IP, rr.TTL)
+
+	if rr.OS == nil {
+		return
+	}
+	if _, ok := seenOS[rr.IP]; ok {
+		return
+	}
+	seenOS[rr.IP] = struct{}{}
+	ev, ok := osguess.FromNmap(rr.OS.Name, rr.OS.Family, rr.OS.Type, rr.OS.Accuracy)
+	e.add(rr.IP, ev, ok)
+}
+
+func (e *osEvidence) setHost(ip string, id int64) {
+	e.hostID[normalizeIP(ip)] = id
+}
+
+// storeOSGuesses — сводит признаки и пишет предположение в hosts
+func (r *Runner) storeOSGuesses(e *osEvidence) {
+	if r.pg == nil {
+		return
+	}
+	for ip, evs := range e.byIP {
+		id, ok := e.hostID[ip]
+		if !ok {
+			continue
+		}
+		g, ok := osguess.Combine(evs)
+		if !ok {
+			continue
+		}
+		if err := r.pg.UpdateHostOS(id, g); err != nil {
+			logger.Errorf("update host os error: %v (ip=%q)", err, ip)
+		}
+	}
+}
+
+// normalizeIP — нормализация IP для inet (убираем скобки и мусор)
+func normalizeIP(ip string) string {
+	ip = strings.TrimSpace(ip)
+	ip = strings.TrimPrefix(ip, "(")
+	ip = strings.TrimSuffix(ip, ")")
+	return ip
+}
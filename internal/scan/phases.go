@@ -0,0 +1,176 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/L1nMay/portscanner/internal/banner"
+	"github.com/L1nMay/portscanner/internal/config"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/masscan"
+	"github.com/L1nMay/portscanner/internal/model"
+	"github.com/L1nMay/portscanner/internal/nmap"
+	"github.com/L1nMay/portscanner/internal/tracing"
+)
+
+// Фазы скана со спанами трассировки: scan.engine (masscan/nmap),
+// scan.host → banner.grab + storage.batch
+
+func runMasscan(ctx context.Context, cfg *config.Config) ([]masscan.Result, error) {
+	ctx, span := tracing.Start(ctx, "scan.engine", tracing.WithAttrs(
+		tracing.String("engine", "masscan"),
+		tracing.Int("targets", len(cfg.Targets)),
+		tracing.String("ports", cfg.Ports),
+	))
+	defer span.End()
+
+	res, err := masscan.RunCtx(ctx, cfg)
+	span.SetAttrs(tracing.Int("results", len(res)))
+	span.RecordError(err)
+	return res, err
+}
+
+func runNmap(ctx context.Context, cfg *config.Config) ([]nmap.Result, error) {
+	ctx, span := tracing.Start(logger.With(ctx, "engine", "nmap"), "scan.engine", tracing.WithAttrs(
+		tracing.String("engine", "nmap"),
+		tracing.Int("targets", len(cfg.Targets)),
+		tracing.String("ports", cfg.Ports),
+	))
+	defer span.End()
+
+	res, err := nmap.RunCtx(ctx, cfg)
+	span.SetAttrs(tracing.Int("results", len(res)))
+	span.RecordError(err)
+	return res, err
+}
+
+// hostFindings — открытые порты одного хоста в порядке выдачи движка
+type hostFindings struct {
+	ip    string
+	ports []masscan.Result
+}
+
+// groupByHost — находки по хостам (порядок первого появления) без дублей
+// ip:port; total — число уникальных находок
+func groupByHost(found []masscan.Result) (hosts []hostFindings, total int) {
+	idx := map[string]int{}
+	seen := map[string]struct{}{}
+	for _, fr := range found {
+		key := fmt.Sprintf("%s:%d", fr.IP, fr.Port)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		total++
+
+		i, ok := idx[fr.IP]
+		if !ok {
+			i = len(hosts)
+			idx[fr.IP] = i
+			hosts = append(hosts, hostFindings{ip: fr.IP})
+		}
+		hosts[i].ports = append(hosts[i].ports, fr)
+	}
+	return hosts, total
+}
+
+// grabbed — находка со снятым баннером
+type grabbed struct {
+	masscan.Result
+	svc    string
+	banner string
+	cert   *model.Cert // TLS-порты; nil, если рукопожатие не удалось
+}
+
+func (g grabbed) scanResult() *model.ScanResult {
+	return &model.ScanResult{
+		IP:      g.IP,
+		Port:    int(g.Port),
+		Proto:   strings.ToLower(g.Proto),
+		Banner:  g.banner,
+		Service: g.svc,
+	}
+}
+
+// grabHost — баннеры всех портов хоста (спан banner.grab), до
+// banner_concurrency портов одновременно; при отмене возвращает ctx.Err()
+func grabHost(ctx context.Context, h hostFindings, cfg *config.Config, osEv *osEvidence) ([]grabbed, error) {
+	_, span := tracing.Start(ctx, "banner.grab", tracing.WithAttrs(
+		tracing.String("host.ip", h.ip),
+		tracing.Int("ports", len(h.ports)),
+		tracing.Int("concurrency", cfg.BannerConcurrency),
+	))
+	defer span.End()
+
+	out := make([]grabbed, len(h.ports))
+	failed := make([]bool, len(h.ports))
+	sem := make(chan struct{}, max(cfg.BannerConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, fr := range h.ports {
+		if err := ctx.Err(); err != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int, fr masscan.Result) {
+			defer func() { <-sem; wg.Done() }()
+
+			bnr, svc, err := banner.GrabBanner(fr.IP, fr.Port, cfg)
+			if svc == "" {
+				svc = "unknown"
+			}
+			out[i] = grabbed{Result: fr, svc: svc, banner: bnr}
+			failed[i] = err != nil
+			if !strings.EqualFold(fr.Proto, "udp") && banner.WantsCert(fr.Port, svc) {
+				out[i].cert, _ = banner.GrabCert(fr.IP, fr.Port, cfg)
+			}
+		}(i, fr)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	errs := 0
+	for i, g := range out {
+		if failed[i] {
+			errs++
+		}
+		osEv.addBanner(g.IP, int(g.Port), g.svc, g.banner)
+	}
+	span.SetAttrs(tracing.Int("errors", errs))
+	return out, nil
+}
+
+// storeHost — запись находок хоста в Postgres (спан storage.batch);
+// возвращает новые порты
+func (r *Runner) storeHost(ctx context.Context, in *ingest, grabs []grabbed) []grabbed {
+	_, span := tracing.Start(ctx, "storage.batch", tracing.WithAttrs(tracing.Int("findings", len(grabs))))
+	defer span.End()
+
+	var fresh []grabbed
+	failed := 0
+	for _, g := range grabs {
+		isNew, err := r.storeFinding(in, g.IP, int(g.Port), strings.ToLower(g.Proto), g.svc, g.banner)
+		if err != nil {
+			failed++
+			span.RecordError(err)
+			logger.Error(ctx, "store finding error", "target", fmt.Sprintf("%s:%d", g.IP, g.Port), "err", err)
+			continue
+		}
+		if g.cert != nil {
+			if err := r.pg.SetPortCert(normalizeIP(g.IP), int(g.Port), strings.ToLower(g.Proto), g.cert); err != nil {
+				span.RecordError(err)
+				logger.Error(ctx, "store cert error", "target", fmt.Sprintf("%s:%d", g.IP, g.Port), "err", err)
+			}
+		}
+		if isNew {
+			fresh = append(fresh, g)
+		}
+	}
+	span.SetAttrs(tracing.Int("new", len(fresh)), tracing.Int("failed", failed))
+	return fresh
+}
@@ -15,9 +15,42 @@ type ScanPlan struct {
 	Interface   string   `json:"interface"`
 	WaitSeconds int      `json:"wait_seconds"`
 	Reason      string   `json:"reason"`
+
+	// оценка (estimate.go)
+	Addresses            int64    `json:"addresses"`
+	PortCount            int      `json:"port_count"`
+	Probes               int64    `json:"probes"`
+	Rate                 int      `json:"rate"`                // проб/с, для nmap — условная
+	EstimatedSeconds     float64  `json:"estimated_seconds"`   // движок + ожидание + баннеры
+	Estimated            string   `json:"estimated"`           // "unknown" — порты не разобраны
+	ExpectedOpenPorts    int64    `json:"expected_open_ports"` // условно: 1% проб, ≤20 на адрес
+	BannerConcurrency    int      `json:"banner_concurrency"`
+	BannerSecondsPerPort float64  `json:"banner_seconds_per_port"` // худший случай
+	BannerSeconds        float64  `json:"banner_seconds"`
+	Warnings             []string `json:"warnings,omitempty"`
 }
 
+// Plan — что и как будет сканироваться, с оценкой объёма и времени;
+// ничего не запускает (dry-run)
 func (r *Runner) Plan(cfg *config.Config) (*ScanPlan, error) {
+	p, err := r.plan(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.estimate(cfg)
+	return p, nil
+}
+
+func (r *Runner) plan(cfg *config.Config) (*ScanPlan, error) {
+	// group:<name> -> сети группы
+	expanded, err := r.ExpandTargets(cfg.Targets)
+	if err != nil {
+		return nil, err
+	}
+	c := *cfg
+	c.Targets = expanded
+	cfg = &c
+
 	// --- SINGLE HOST HANDLING ---
 	if len(cfg.Targets) == 1 {
 		t := cfg.Targets[0]
@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/policy"
+)
+
+// loadPolicies — включённые политики на время прогона
+func (r *Runner) loadPolicies() []policy.Policy {
+	if r.pg == nil {
+		return nil
+	}
+	pols, err := r.pg.ListPolicies(true)
+	if err != nil {
+		logger.Errorf("load policies error: %v", err)
+		return nil
+	}
+	return pols
+}
+
+// checkPolicies — проверяет находку, сохраняет статус compliant/violating и
+// поднимает policy_violation только на нарушения, которых раньше не было.
+// Возвращает все текущие нарушения находки.
+func (r *Runner) checkPolicies(
+	pols []policy.Policy,
+	hostID int64,
+	ip string,
+	port int,
+	proto string,
+	svc string,
+	a hostAssets,
+) []policy.Violation {
+	vs := policy.Evaluate(pols, policy.Finding{
+		IP:      ip,
+		Port:    port,
+		Proto:   proto,
+		Service: svc,
+		Groups:  a.groups,
+	})
+
+	prev, err := r.pg.SetPortCompliance(hostID, port, proto, vs)
+	if err != nil {
+		logger.Errorf("set port compliance error: %v (ip=%q port=%d)", err, ip, port)
+		return vs
+	}
+
+	seen := map[int64]struct{}{}
+	for _, v := range prev {
+		seen[v.PolicyID] = struct{}{}
+	}
+	fresh := make([]policy.Violation, 0, len(vs))
+	for _, v := range vs {
+		if _, ok := seen[v.PolicyID]; !ok {
+			fresh = append(fresh, v)
+		}
+	}
+	if len(fresh) == 0 {
+		return vs
+	}
+
+	if err := r.pg.AddEvent("policy_violation", map[string]any{
+		"ip":         ip,
+		"port":       port,
+		"proto":      proto,
+		"service":    svc,
+		"severity":   policy.MaxSeverity(fresh),
+		"violations": fresh,
+		"tags":       a.tags,
+		"groups":     a.groups,
+	}); err != nil {
+		logger.Errorf("add event error: %v", err)
+	}
+
+	return vs
+}
+
+// EvaluatePolicies — перепроверка всех известных находок (после изменения политик)
+func (r *Runner) EvaluatePolicies() (checked int, violating int, err error) {
+	if r.pg == nil {
+		return 0, 0, nil
+	}
+
+	pols, err := r.pg.ListPolicies(true)
+	if err != nil {
+		return 0, 0, err
+	}
+	findings, err := r.pg.ListPortFindings()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, f := range findings {
+		vs := r.checkPolicies(pols, f.HostID, f.IP, f.Port, f.Proto, f.Service, hostAssets{
+			tags:   f.Tags,
+			groups: f.Groups,
+		})
+		checked++
+		if len(vs) > 0 {
+			violating++
+		}
+	}
+
+	return checked, violating, nil
+}
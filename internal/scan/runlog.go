@@ -0,0 +1,219 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/model"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+const (
+	runLogFlushEvery = time.Second
+	runLogMaxLine    = 2048 // длиннее — обрезается
+)
+
+// runLog — журнал одного прогона: прогресс, записи logger уровня Info и выше
+// с ctx скана (командные строки, stderr движков, предупреждения). Пишется в
+// scan_logs пачками раз в секунду; сверх лимита байт строки отбрасываются.
+type runLog struct {
+	pg     *storage.Postgres
+	hub    *Hub
+	scanID string
+	limit  int
+
+	mu        sync.Mutex
+	buf       []storage.ScanLogLine
+	size      int
+	truncated bool
+	lastPct   int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type runLogKey struct{}
+
+// startRunLog — ctx с журналом прогона (без Postgres — только атрибут scan_id);
+// close обязателен, он дописывает остаток
+func (r *Runner) startRunLog(ctx context.Context, scanID string) (context.Context, *runLog) {
+	ctx = withScanID(logger.With(ctx, "scan_id", scanID), scanID)
+	if r.pg == nil {
+		return ctx, nil
+	}
+
+	limit := r.cfg.ScanLogMaxBytes
+	if limit <= 0 {
+		limit = 1 << 20
+	}
+	l := &runLog{
+		pg:      r.pg,
+		hub:     r.hub,
+		scanID:  scanID,
+		limit:   limit,
+		lastPct: -1,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go l.loop()
+
+	ctx = logger.WithSink(ctx, l.record)
+	return context.WithValue(ctx, runLogKey{}, l), l
+}
+
+// beginRun — журнал прогона и строка scans со статусом running до запуска движка
+func (r *Runner) beginRun(ctx context.Context, run *model.ScanRun) (context.Context, *runLog) {
+	ctx, l := r.startRunLog(ctx, run.ID)
+	if r.pg != nil {
+		if err := r.pg.StartScanRun(run, r.cfg.Targets); err != nil {
+			logger.Error(ctx, "start scan run", "err", err)
+		}
+	}
+	return ctx, l
+}
+
+func runLogFrom(ctx context.Context) *runLog {
+	l, _ := ctx.Value(runLogKey{}).(*runLog)
+	return l
+}
+
+// progress — сообщение в Hub (SSE, Telegram /status) и в журнал скана;
+// повтор того же процента («Processed N/M») в журнал не пишется
+func (r *Runner) progress(ctx context.Context, pct int, msg string) {
+	r.publish(ctx, EventProgress, Progress{Percent: pct, Message: msg})
+
+	l := runLogFrom(ctx)
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	repeat := pct == l.lastPct
+	l.lastPct = pct
+	l.mu.Unlock()
+	if !repeat {
+		l.add("INFO", "progress", fmt.Sprintf("%d%% %s", pct, msg))
+	}
+}
+
+// failRun — статус failed/cancelled и причина в строке scans
+func (r *Runner) failRun(ctx context.Context, run *model.ScanRun, err error) {
+	status := model.ScanFailed
+	if errors.Is(err, context.Canceled) {
+		status = model.ScanCancelled
+	}
+	logger.Error(ctx, "scan "+status, "err", err)
+
+	if r.pg == nil {
+		return
+	}
+	if ferr := r.pg.FailScanRun(run.ID, status, err.Error()); ferr != nil {
+		logger.Error(ctx, "mark scan run "+status, "err", ferr)
+	}
+}
+
+// record — logger.Sink: «сообщение: line k=v ...»; stream=stderr → source stderr
+func (l *runLog) record(rec slog.Record) {
+	source := "log"
+	var (
+		line  string
+		extra strings.Builder
+	)
+	rec.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "stream":
+			if a.Value.String() == "stderr" {
+				source = "stderr"
+			}
+		case "line":
+			line = a.Value.String()
+		default:
+			v := a.Value.String()
+			if strings.ContainsAny(v, " \t\"=") || v == "" {
+				v = strconv.Quote(v)
+			}
+			extra.WriteString(" " + a.Key + "=" + v)
+		}
+		return true
+	})
+
+	msg := rec.Message
+	if line != "" {
+		msg += ": " + line
+	}
+	l.addAt(rec.Time, rec.Level.String(), source, msg+extra.String())
+}
+
+func (l *runLog) add(level, source, msg string) {
+	l.addAt(time.Now(), level, source, msg)
+}
+
+func (l *runLog) addAt(ts time.Time, level, source, msg string) {
+	if l == nil {
+		return
+	}
+	if len(msg) > runLogMaxLine {
+		msg = strings.ToValidUTF8(msg[:runLogMaxLine], "") + "…"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.truncated {
+		return
+	}
+	if l.size+len(msg) > l.limit {
+		l.truncated = true
+		msg = fmt.Sprintf("scan log limit reached (%d bytes), further lines dropped", l.limit)
+		level, source = "WARN", "log"
+	}
+	l.size += len(msg)
+	l.buf = append(l.buf, storage.ScanLogLine{Time: ts.UTC(), Level: level, Source: source, Message: msg})
+
+	// прогресс уходит в поток своим событием
+	if source != "progress" {
+		l.hub.Publish(l.scanID, EventLog, LogEntry{Time: ts.UTC(), Level: level, Source: source, Message: msg})
+	}
+}
+
+func (l *runLog) loop() {
+	defer close(l.done)
+	t := time.NewTicker(runLogFlushEvery)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			l.flush()
+		case <-l.stop:
+			l.flush()
+			return
+		}
+	}
+}
+
+func (l *runLog) flush() {
+	l.mu.Lock()
+	batch := l.buf
+	l.buf = nil
+	l.mu.Unlock()
+
+	// без ctx: ошибка записи журнала в сам журнал не попадает
+	if err := l.pg.AppendScanLog(l.scanID, batch); err != nil {
+		logger.Errorf("scan %s: write scan log (%d lines): %v", l.scanID, len(batch), err)
+	}
+}
+
+// close — остановить фоновую запись и дописать остаток
+func (l *runLog) close() {
+	if l == nil {
+		return
+	}
+	close(l.stop)
+	<-l.done
+}
@@ -3,34 +3,50 @@ package scan
 import (
 	"context"
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/L1nMay/portscanner/internal/banner"
 	"github.com/L1nMay/portscanner/internal/config"
 	"github.com/L1nMay/portscanner/internal/envdetect"
 	"github.com/L1nMay/portscanner/internal/logger"
 	"github.com/L1nMay/portscanner/internal/masscan"
 	"github.com/L1nMay/portscanner/internal/model"
-	"github.com/L1nMay/portscanner/internal/nmap"
+	"github.com/L1nMay/portscanner/internal/tracing"
 )
 
 /*
 RunAsync — неблокирующий запуск скана
 */
-func (r *Runner) RunAsync(cfg *config.Config) {
+func (r *Runner) RunAsync(cfg *config.Config) string {
+	return r.RunAsyncCtx(context.Background(), cfg)
+}
+
+/*
+RunAsyncCtx — RunAsync с атрибутами логов из ctx (request_id);
+отмена ctx скан не останавливает — для этого Cancel. Возвращает ID скана:
+его поток событий (/api/scans/{id}/stream) доступен сразу
+*/
+func (r *Runner) RunAsyncCtx(ctx context.Context, cfg *config.Config) string {
+	id := newUUID()
+	r.hub.Open(id)
+
+	ctx = withScanID(context.WithoutCancel(ctx), id)
 	go func() {
-		_, err := r.RunOnceWithContext(cfg)
+		_, err := r.runWithContext(ctx, cfg)
 		if err != nil {
-			logger.Errorf("async scan error: %v", err)
+			logger.Error(ctx, "async scan error", "err", err)
 		}
 	}()
+	return id
 }
 
 /*
 RunOnceWithContext — mutex + ctx/cancel + hub
 */
 func (r *Runner) RunOnceWithContext(cfg *config.Config) (*model.ScanRun, error) {
+	return r.runWithContext(context.Background(), cfg)
+}
+
+func (r *Runner) runWithContext(parent context.Context, cfg *config.Config) (*model.ScanRun, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -38,30 +54,41 @@ func (r *Runner) RunOnceWithContext(cfg *config.Config) (*model.ScanRun, error)
 	r.cfg = cfg
 	defer func() { r.cfg = orig }()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	r.setCancel(cancel)
+	if scanIDFrom(parent) == "" {
+		parent = withScanID(parent, newUUID())
+	}
+	ctx, cancel := context.WithCancel(parent)
+	r.setCancel(cancel, scanIDFrom(ctx), cfg.Targets)
 	defer r.clearCancel()
 
-	r.hub.Publish(Progress{Percent: 5, Message: "Scan started"})
+	r.publish(ctx, EventProgress, Progress{Percent: 5, Message: "Scan started"})
 
+	started := time.Now()
 	run, err := r.RunOnceCtx(ctx)
+	observeScan(run, started, err)
+
+	d := Done{Status: model.ScanFinished, Message: "Scan finished"}
+	switch {
+	case err != nil && ctx.Err() != nil:
+		d.Status, d.Message, d.Error = model.ScanCancelled, "Scan cancelled", err.Error()
+	case err != nil:
+		d.Status, d.Message, d.Error = model.ScanFailed, err.Error(), err.Error()
+	default:
+		d.Found, d.New = run.Found, run.NewFound
+	}
+	r.publish(ctx, EventProgress, Progress{Percent: 100, Message: d.Message})
+	r.publish(ctx, EventDone, d)
+
 	if err != nil {
-		msg := err.Error()
-		if ctx.Err() != nil {
-			msg = "Scan cancelled"
-		}
-		r.hub.Publish(Progress{Percent: 100, Message: msg})
 		return nil, err
 	}
-
-	r.hub.Publish(Progress{Percent: 100, Message: "Scan finished"})
 	return run, nil
 }
 
 /*
 RunOnceCtx — ctx-aware scan
 */
-func (r *Runner) RunOnceCtx(ctx context.Context) (*model.ScanRun, error) {
+func (r *Runner) RunOnceCtx(ctx context.Context) (_ *model.ScanRun, err error) {
 	if r.pg == nil && r.store == nil {
 		return nil, fmt.Errorf("no storage configured (pg=nil and store=nil)")
 	}
@@ -72,177 +99,152 @@ func (r *Runner) RunOnceCtx(ctx context.Context) (*model.ScanRun, error) {
 	default:
 	}
 
-	r.hub.Publish(Progress{Percent: 10, Message: "Pre-flight checks"})
-
-	if err := checkBinary("nmap"); err != nil {
-		return nil, err
+	id := scanIDFrom(ctx)
+	if id == "" {
+		id = newUUID()
 	}
-	if err := checkBinary(r.cfg.MasscanPath); err != nil {
-		logger.Errorf("masscan check failed: %v", err)
+	run := &model.ScanRun{
+		ID:           id,
+		StartedAt:    time.Now().UTC(),
+		TargetsCount: len(r.cfg.Targets),
+		PortsSpec:    r.cfg.Ports,
+		Source:       model.SourceScan,
 	}
+	ctx, rl := r.beginRun(ctx, run)
+	defer rl.close()
 
-	// auto targets
-	if r.cfg.AutoTargets && len(r.cfg.Targets) == 0 {
-		ni, err := envdetect.DetectNetInfo()
-		if err == nil && ni.SrcIP != "" {
-			cidr := ni.SrcIP + "/24"
-			r.cfg.Targets = []string{cidr}
-			logger.Infof("Auto targets enabled: %s", cidr)
+	ctx, span := tracing.Start(ctx, "scan")
+	defer func() {
+		if err != nil {
+			r.failRun(ctx, run, err)
 		}
-	}
-
-	if len(r.cfg.Targets) == 0 {
-		return nil, fmt.Errorf("no scan targets specified")
-	}
+		span.RecordError(err)
+		span.End()
+	}()
 
-	dec := envdetect.Decide(r.cfg.Targets)
+	r.phase(ctx, "preflight", "")
+	r.progress(ctx, 10, "Pre-flight checks")
 
-	// auto iface
-	if r.cfg.Interface == "" {
-		ifc, err := envdetect.DetectDefaultInterface()
-		if err == nil {
-			r.cfg.Interface = ifc
-		}
-	}
-
-	// wait
-	wait := r.cfg.WaitSeconds
-	if wait <= 0 {
-		wait = dec.WaitSeconds
+	pf, err := r.preflight(ctx)
+	if err != nil {
+		return nil, err
 	}
+	dec := pf.dec
 
-	resolvedPorts := resolvePorts(r.cfg.Ports, dec.PreferredEngine)
-
-	run := &model.ScanRun{
-		ID:           newUUID(),
-		StartedAt:    time.Now().UTC(),
-		TargetsCount: len(r.cfg.Targets),
-		PortsSpec:    resolvedPorts,
-		Engine:       dec.PreferredEngine,
-		Notes:        dec.Reason,
-	}
+	run.PortsSpec = pf.ports
+	run.Engine = dec.PreferredEngine
+	run.Notes = dec.Reason
 
 	engineCfg := *r.cfg
-	engineCfg.Ports = resolvedPorts
-	engineCfg.WaitSeconds = wait
+	engineCfg.Ports = pf.ports
+	engineCfg.WaitSeconds = pf.wait
+
+	ctx = withRun(ctx, run, r.cfg.Targets)
+	span.SetAttrs(
+		tracing.String("scan.id", run.ID),
+		tracing.String("targets", targetsLabel(r.cfg.Targets)),
+		tracing.Int("targets_count", run.TargetsCount),
+	)
+	logger.Info(ctx, "scan started", "targets_count", run.TargetsCount, "ports", pf.ports, "reason", dec.Reason)
 
-	r.hub.Publish(Progress{Percent: 20, Message: "Launching scan engine"})
+	r.phase(ctx, "engine", dec.PreferredEngine)
+	r.progress(ctx, 20, "Launching scan engine")
 
 	var (
 		found      []masscan.Result
 		engineUsed = dec.PreferredEngine
+		osEv       = newOSEvidence()
+		seenOS     = map[string]struct{}{}
 	)
 
 	if dec.PreferredEngine == "masscan" {
-		r.hub.Publish(Progress{Percent: 30, Message: "Running masscan"})
-		mr, err := masscan.RunCtx(ctx, &engineCfg)
+		r.progress(ctx, 30, "Running masscan")
+		mr, err := runMasscan(ctx, &engineCfg)
 		if err != nil && ctx.Err() == nil {
-			logger.Errorf("masscan error: %v", err)
+			logger.Error(ctx, "masscan error", "err", err)
 		}
 		found = mr
+		for _, m := range mr {
+			osEv.addTTL(m.IP, m.TTL)
+		}
 
 		if len(found) == 0 {
-			r.hub.Publish(Progress{Percent: 45, Message: "Masscan returned 0, fallback to nmap"})
+			r.phase(ctx, "engine", "nmap (fallback)")
+			r.progress(ctx, 45, "Masscan returned 0, fallback to nmap")
 			engineUsed = "mixed"
+			logger.Info(ctx, "masscan returned 0 results, falling back to nmap")
 
-			nr, err := nmap.RunCtx(ctx, &engineCfg)
+			nr, err := runNmap(ctx, &engineCfg)
 			if err != nil {
 				return nil, err
 			}
 			for _, rr := range nr {
+				osEv.addNmap(rr, seenOS)
 				found = append(found, masscan.Result{
 					IP:    rr.IP,
 					Port:  rr.Port,
 					Proto: rr.Proto,
+					TTL:   rr.TTL,
 				})
 			}
 		}
 	} else {
-		r.hub.Publish(Progress{Percent: 35, Message: "Running nmap"})
-		nr, err := nmap.RunCtx(ctx, &engineCfg)
+		r.progress(ctx, 35, "Running nmap")
+		nr, err := runNmap(ctx, &engineCfg)
 		if err != nil {
 			return nil, err
 		}
 		engineUsed = "nmap"
 		for _, rr := range nr {
+			osEv.addNmap(rr, seenOS)
 			found = append(found, masscan.Result{
 				IP:    rr.IP,
 				Port:  rr.Port,
 				Proto: rr.Proto,
+				TTL:   rr.TTL,
 			})
 		}
 	}
 
-	r.hub.Publish(Progress{Percent: 70, Message: "Analyzing banners & storing results"})
+	hosts, totalFound := groupByHost(found)
+	r.phase(ctx, "banners", fmt.Sprintf("%d hosts, %d ports", len(hosts), totalFound))
+	r.progress(ctx, 70, "Analyzing banners & storing results")
 
-	totalFound := 0
+	in := r.newIngest(ctx, osEv)
 	newFound := 0
-	seen := map[string]struct{}{}
+	processed := 0
 	lastTick := time.Now()
 
-	for i, fr := range found {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		key := fmt.Sprintf("%s:%d", fr.IP, fr.Port)
-		if _, ok := seen[key]; ok {
-			continue
-		}
-		seen[key] = struct{}{}
-		totalFound++
+	for _, h := range hosts {
+		hctx, hs := tracing.Start(ctx, "scan.host", tracing.WithAttrs(
+			tracing.String("host.ip", h.ip),
+			tracing.Int("ports", len(h.ports)),
+		))
 
-		bnr, svc, _ := banner.GrabBanner(fr.IP, fr.Port, &engineCfg)
-		if svc == "" {
-			svc = "unknown"
+		grabs, err := grabHost(hctx, h, &engineCfg, osEv)
+		if err != nil {
+			hs.RecordError(err)
+			hs.End()
+			return nil, err
 		}
 
-		// ✅ Пишем результаты в Postgres (если подключен)
+		// ✅ Пишем результаты в Postgres (если подключен), иначе — в legacy sqlite
 		if r.pg != nil {
-			// нормализация IP для inet (убираем скобки и мусор)
-			ip := strings.TrimSpace(fr.IP)
-			ip = strings.TrimPrefix(ip, "(")
-			ip = strings.TrimSuffix(ip, ")")
-
-			hostID, err := r.pg.UpsertHost(ip)
-			if err != nil {
-				logger.Errorf("upsert host error: %v (ip=%q)", err, ip)
-				continue
-			}
-
-			isNew, err := r.pg.UpsertPort(
-				hostID,
-				int(fr.Port),
-				strings.ToLower(fr.Proto),
-				svc,
-				bnr,
-			)
-
-			if err != nil {
-				logger.Errorf("upsert port error: %v", err)
-				continue
-			}
-
-			if isNew {
-				newFound++
-				_ = r.pg.AddEvent("new_port", map[string]any{
-					"ip":      fr.IP,
-					"port":    int(fr.Port),
-					"service": svc,
-				})
-			}
+			newFound += len(r.storeHost(hctx, in, grabs))
+		} else {
+			newFound += r.storeLegacy(ctx, grabs)
 		}
+		hs.End()
 
 		// прогресс
-		if time.Since(lastTick) > 700*time.Millisecond && len(found) > 0 {
+		processed += len(h.ports)
+		if time.Since(lastTick) > 700*time.Millisecond {
 			lastTick = time.Now()
-			p := 70 + int(float64(i+1)/float64(len(found))*25.0)
+			p := 70 + int(float64(processed)/float64(totalFound)*25.0)
 			if p > 95 {
 				p = 95
 			}
-			r.hub.Publish(Progress{Percent: p, Message: fmt.Sprintf("Processed %d/%d", i+1, len(found))})
+			r.progress(ctx, p, fmt.Sprintf("Processed %d/%d", processed, totalFound))
 		}
 	}
 
@@ -250,16 +252,109 @@ func (r *Runner) RunOnceCtx(ctx context.Context) (*model.ScanRun, error) {
 	run.Found = totalFound
 	run.NewFound = newFound
 	run.Engine = engineUsed
+	span.SetAttrs(
+		tracing.String("engine", engineUsed),
+		tracing.Int("found", totalFound),
+		tracing.Int("new", newFound),
+	)
+
+	r.phase(ctx, "finish", "")
+	r.finishScan(ctx, run, osEv)
+
+	logger.Info(ctx, "scan finished", "engine", engineUsed, "found", totalFound, "new", newFound)
+	r.progress(ctx, 98, "Finalizing")
+	return run, nil
+}
+
+// scanPreflight — решения до запуска движка
+type scanPreflight struct {
+	dec   envdetect.Decision
+	wait  int
+	ports string
+}
+
+// preflight — проверка бинарников, цели, выбор движка, интерфейс, wait и
+// порты (спан scan.preflight); меняет r.cfg (Targets, Interface)
+func (r *Runner) preflight(ctx context.Context) (pf scanPreflight, err error) {
+	_, span := tracing.Start(ctx, "scan.preflight")
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := checkBinary("nmap"); err != nil {
+		return pf, err
+	}
+	if err := checkBinary(r.cfg.MasscanPath); err != nil {
+		logger.Errorf("masscan check failed: %v", err)
+	}
+
+	// auto targets
+	if r.cfg.AutoTargets && len(r.cfg.Targets) == 0 {
+		ni, err := envdetect.DetectNetInfo()
+		if err == nil && ni.SrcIP != "" {
+			cidr := ni.SrcIP + "/24"
+			r.cfg.Targets = []string{cidr}
+			logger.Infof("Auto targets enabled: %s", cidr)
+		} else {
+			logger.Errorf("Auto targets failed: %v", err)
+		}
+	}
+
+	targets, err := r.ExpandTargets(r.cfg.Targets)
+	if err != nil {
+		return pf, err
+	}
+	r.cfg.Targets = targets
+
+	if len(r.cfg.Targets) == 0 {
+		return pf, fmt.Errorf("no scan targets specified")
+	}
+
+	pf.dec = envdetect.Decide(r.cfg.Targets)
+
+	// auto iface
+	if r.cfg.Interface == "" {
+		ifc, err := envdetect.DetectDefaultInterface()
+		if err == nil {
+			r.cfg.Interface = ifc
+			logger.Infof("Auto-detected interface: %s", ifc)
+		} else {
+			logger.Errorf("Failed to auto-detect interface: %v", err)
+		}
+	}
+
+	// wait
+	pf.wait = r.cfg.WaitSeconds
+	if pf.wait <= 0 {
+		pf.wait = pf.dec.WaitSeconds
+		logger.Infof("Auto wait_seconds=%d (reason: %s)", pf.wait, pf.dec.Reason)
+	}
+
+	pf.ports = resolvePorts(r.cfg.Ports, pf.dec.PreferredEngine)
+
+	span.SetAttrs(
+		tracing.String("engine", pf.dec.PreferredEngine),
+		tracing.Int("targets_count", len(r.cfg.Targets)),
+	)
+	return pf, nil
+}
+
+// finishScan — OS-догадки, scan-run и пост-обработка (спан scan.finish)
+func (r *Runner) finishScan(ctx context.Context, run *model.ScanRun, osEv *osEvidence) {
+	ctx, span := tracing.Start(ctx, "scan.finish")
+	defer span.End()
+
+	r.storeOSGuesses(osEv)
 
 	// ✅ scan-run в Postgres
 	if r.pg != nil {
 		if err := r.pg.AddScanRun(run, r.cfg.Targets); err != nil {
-			logger.Errorf("add scan run failed: %v", err)
+			span.RecordError(err)
+			logger.Error(ctx, "add scan run failed", "err", err)
 		}
+		r.finishRun(ctx, run)
 	} else if r.store != nil {
 		_ = r.store.AddScanRun(run)
 	}
-
-	r.hub.Publish(Progress{Percent: 98, Message: "Finalizing"})
-	return run, nil
 }
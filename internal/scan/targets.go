@@ -0,0 +1,61 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+)
+
+// GroupTargetPrefix — цель вида "group:prod-dmz" раскрывается в сети asset-группы
+const GroupTargetPrefix = "group:"
+
+// ExpandTargets — раскрывает "group:<name>" в CIDR-ы группы, остальные цели оставляет как есть
+func (r *Runner) ExpandTargets(targets []string) ([]string, error) {
+	out := make([]string, 0, len(targets))
+
+	for _, t := range targets {
+		t = strings.TrimSpace(t)
+		if !strings.HasPrefix(t, GroupTargetPrefix) {
+			out = append(out, t)
+			continue
+		}
+
+		name := strings.TrimSpace(strings.TrimPrefix(t, GroupTargetPrefix))
+		if r.pg == nil {
+			return nil, fmt.Errorf("target %s: asset groups require postgres", t)
+		}
+		g, err := r.pg.GetAssetGroupByName(name)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", t, err)
+		}
+		if len(g.CIDRs) == 0 {
+			return nil, fmt.Errorf("target %s: group has no cidrs", t)
+		}
+		out = append(out, g.CIDRs...)
+	}
+
+	return out, nil
+}
+
+type hostAssets struct {
+	tags   []string
+	groups []string
+}
+
+// hostAssetsCached — теги/группы хоста для payload событий (кэш на один прогон)
+func (r *Runner) hostAssetsCached(cache map[string]hostAssets, ip string) hostAssets {
+	if a, ok := cache[ip]; ok {
+		return a
+	}
+	var a hostAssets
+	if r.pg != nil {
+		tags, groups, err := r.pg.HostAssets(ip)
+		if err != nil {
+			logger.Errorf("host assets error: %v (ip=%q)", err, ip)
+		}
+		a = hostAssets{tags: tags, groups: groups}
+	}
+	cache[ip] = a
+	return a
+}
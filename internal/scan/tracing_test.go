@@ -0,0 +1,149 @@
+package scan
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/L1nMay/portscanner/internal/config"
+	"github.com/L1nMay/portscanner/internal/storage"
+	"github.com/L1nMay/portscanner/internal/tracing"
+)
+
+// fakeNmap — nmap из PATH заменяется скриптом, который «находит» открытый port
+func fakeNmap(t *testing.T, port int) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\n" +
+		"echo 'Nmap scan report for 127.0.0.1'\n" +
+		"echo 'PORT STATE SERVICE'\n" +
+		"echo '" + strconv.Itoa(port) + "/tcp open unknown'\n"
+	if err := os.WriteFile(filepath.Join(dir, "nmap"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// sshStub — порт, отдающий SSH-баннер
+func sshStub(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte("SSH-2.0-OpenSSH_9.6\r\n"))
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestRunSpans(t *testing.T) {
+	port := sshStub(t)
+	fakeNmap(t, port)
+
+	exp := tracing.NewMemoryExporter()
+	shutdown := tracing.Use(exp, 1)
+	t.Cleanup(func() { _ = shutdown(context.Background()) })
+
+	store, err := storage.NewStorage(filepath.Join(t.TempDir(), "scan.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	cfg := &config.Config{
+		Targets:           []string{"localhost"},
+		Ports:             strconv.Itoa(port),
+		MasscanPath:       "masscan-not-installed",
+		ConnectTimeoutSec: 2,
+		ReadTimeoutSec:    1,
+		BannerMaxBytes:    256,
+		BannerConcurrency: 1,
+		WaitSeconds:       1,
+	}
+	run, err := NewRunner(cfg, store).RunOnceCtx(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Found != 1 {
+		t.Fatalf("found = %d, want 1", run.Found)
+	}
+
+	tracing.ForceFlush(context.Background())
+	byName := map[string]tracing.SpanData{}
+	for _, s := range exp.Spans() {
+		if _, dup := byName[s.Name]; dup {
+			t.Fatalf("span %s recorded twice", s.Name)
+		}
+		byName[s.Name] = s
+	}
+
+	root, ok := byName["scan"]
+	if !ok {
+		t.Fatalf("no scan span, got %v", names(exp.Spans()))
+	}
+	if root.ParentID.IsValid() {
+		t.Errorf("scan span has parent %s", root.ParentID)
+	}
+
+	parents := map[string]string{
+		"scan.preflight": "scan",
+		"scan.engine":    "scan",
+		"scan.host":      "scan",
+		"banner.grab":    "scan.host",
+		"scan.finish":    "scan",
+	}
+	for name, parent := range parents {
+		s, ok := byName[name]
+		if !ok {
+			t.Errorf("no %s span, got %v", name, names(exp.Spans()))
+			continue
+		}
+		if s.TraceID != root.TraceID {
+			t.Errorf("%s: trace %s, want %s", name, s.TraceID, root.TraceID)
+		}
+		if want := byName[parent].SpanID; s.ParentID != want {
+			t.Errorf("%s: parent %s, want %s (%s)", name, s.ParentID, want, parent)
+		}
+		if s.Status == tracing.StatusError {
+			t.Errorf("%s: error status: %s", name, s.Message)
+		}
+	}
+
+	if got := attr(byName["scan.engine"], "engine"); got != "nmap" {
+		t.Errorf("scan.engine engine = %v", got)
+	}
+	if got := attr(byName["banner.grab"], "host.ip"); got != "127.0.0.1" {
+		t.Errorf("banner.grab host.ip = %v", got)
+	}
+}
+
+func names(spans []tracing.SpanData) []string {
+	out := make([]string, 0, len(spans))
+	for _, s := range spans {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+// attr — последнее значение атрибута (SetAttrs дописывает)
+func attr(s tracing.SpanData, key string) any {
+	var v any
+	for _, a := range s.Attrs {
+		if a.Key == key {
+			v = a.Value
+		}
+	}
+	return v
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/alerting"
+)
+
+// ruleDefinition — то, что лежит в alert_rules.definition
+type ruleDefinition struct {
+	Match           alerting.Match `json:"match"`
+	Channels        []string       `json:"channels"`
+	ThrottleCount   int            `json:"throttle_count,omitempty"`
+	ThrottleSeconds int            `json:"throttle_seconds,omitempty"`
+	DedupSeconds    int            `json:"dedup_seconds,omitempty"`
+}
+
+func scanAlertRule(row interface{ Scan(...any) error }) (*alerting.Rule, error) {
+	var (
+		r   alerting.Rule
+		raw []byte
+		def ruleDefinition
+	)
+	if err := row.Scan(&r.ID, &r.Name, &r.Enabled, &raw); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, err
+	}
+	r.Source = "api"
+	r.Match = def.Match
+	r.Channels = def.Channels
+	r.ThrottleCount = def.ThrottleCount
+	r.ThrottleSeconds = def.ThrottleSeconds
+	r.DedupSeconds = def.DedupSeconds
+	return &r, nil
+}
+
+func marshalRuleDefinition(r *alerting.Rule) ([]byte, error) {
+	return json.Marshal(ruleDefinition{
+		Match:           r.Match,
+		Channels:        r.Channels,
+		ThrottleCount:   r.ThrottleCount,
+		ThrottleSeconds: r.ThrottleSeconds,
+		DedupSeconds:    r.DedupSeconds,
+	})
+}
+
+func (p *Postgres) ListAlertRules() ([]alerting.Rule, error) {
+	rows, err := p.db.Query(`SELECT id, name, enabled, definition FROM alert_rules ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]alerting.Rule, 0)
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *r)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) GetAlertRule(id int64) (*alerting.Rule, error) {
+	r, err := scanAlertRule(p.db.QueryRow(`SELECT id, name, enabled, definition FROM alert_rules WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return r, err
+}
+
+func (p *Postgres) CreateAlertRule(r *alerting.Rule) error {
+	def, err := marshalRuleDefinition(r)
+	if err != nil {
+		return err
+	}
+	r.Source = "api"
+	return p.db.QueryRow(`
+		INSERT INTO alert_rules (name, enabled, definition)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, r.Name, r.Enabled, def).Scan(&r.ID)
+}
+
+func (p *Postgres) UpdateAlertRule(r *alerting.Rule) error {
+	def, err := marshalRuleDefinition(r)
+	if err != nil {
+		return err
+	}
+	r.Source = "api"
+	res, err := p.db.Exec(`
+		UPDATE alert_rules
+		SET name = $2, enabled = $3, definition = $4, updated_at = now()
+		WHERE id = $1
+	`, r.ID, r.Name, r.Enabled, def)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) DeleteAlertRule(id int64) error {
+	res, err := p.db.Exec(`DELETE FROM alert_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetAlertMute — глушит правило до until; until в прошлом снимает mute
+func (p *Postgres) SetAlertMute(rule string, until time.Time) error {
+	if !until.After(time.Now()) {
+		_, err := p.db.Exec(`DELETE FROM alert_mutes WHERE rule = $1`, rule)
+		return err
+	}
+	_, err := p.db.Exec(`
+		INSERT INTO alert_mutes (rule, until) VALUES ($1, $2)
+		ON CONFLICT (rule) DO UPDATE SET until = EXCLUDED.until
+	`, rule, until.UTC())
+	return err
+}
+
+// ListAlertMutes — действующие mute: правило -> до какого момента
+func (p *Postgres) ListAlertMutes() (map[string]time.Time, error) {
+	rows, err := p.db.Query(`SELECT rule, until FROM alert_mutes WHERE until > now()`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]time.Time{}
+	for rows.Next() {
+		var (
+			rule  string
+			until time.Time
+		)
+		if err := rows.Scan(&rule, &until); err != nil {
+			return nil, err
+		}
+		out[rule] = until
+	}
+	return out, rows.Err()
+}
+
+// alertRuleLockClass — пространство advisory lock'ов правил (второй ключ — hashtext(имя))
+const alertRuleLockClass = 0x706f7274 // "port"
+
+// FireAlertRule — проверка и фиксация окон dedup/throttle правила r за одну
+// транзакцию; false — срабатывание подавлено. Lock по имени правила
+// сериализует воркеры только на этом правиле, события разных правил
+// маршрутизируются параллельно.
+func (p *Postgres) FireAlertRule(r *alerting.Rule, dedupKey string, now time.Time) (bool, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, alertRuleLockClass, r.Name); err != nil {
+		return false, err
+	}
+
+	now = now.UTC()
+	if r.DedupSeconds > 0 {
+		if _, err := tx.Exec(`DELETE FROM alert_dedup WHERE rule = $1 AND until <= $2`, r.Name, now); err != nil {
+			return false, err
+		}
+		var dup bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM alert_dedup WHERE key = $1)`, dedupKey).Scan(&dup); err != nil {
+			return false, err
+		}
+		if dup {
+			return false, nil
+		}
+	}
+
+	throttled := r.ThrottleCount > 0 && r.ThrottleSeconds > 0
+	if throttled {
+		from := now.Add(-time.Duration(r.ThrottleSeconds) * time.Second)
+		if _, err := tx.Exec(`DELETE FROM alert_sends WHERE rule = $1 AND sent_at <= $2`, r.Name, from); err != nil {
+			return false, err
+		}
+		var sent int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM alert_sends WHERE rule = $1`, r.Name).Scan(&sent); err != nil {
+			return false, err
+		}
+		if sent >= r.ThrottleCount {
+			return false, nil
+		}
+	}
+
+	// правило срабатывает — фиксируем окна
+	if r.DedupSeconds > 0 {
+		if _, err := tx.Exec(`
+			INSERT INTO alert_dedup (key, rule, until) VALUES ($1, $2, $3)
+			ON CONFLICT (key) DO UPDATE SET until = EXCLUDED.until
+		`, dedupKey, r.Name, now.Add(time.Duration(r.DedupSeconds)*time.Second)); err != nil {
+			return false, err
+		}
+	}
+	if throttled {
+		if _, err := tx.Exec(`INSERT INTO alert_sends (rule, sent_at) VALUES ($1, $2)`, r.Name, now); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type AssetGroup struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Owner       string    `json:"owner,omitempty"`
+	Environment string    `json:"environment,omitempty"` // prod | stage | lab | ...
+	Description string    `json:"description,omitempty"`
+	CIDRs       []string  `json:"cidrs"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Tag — тег на хост (/32) или диапазон, наследуется всеми хостами внутри
+type Tag struct {
+	ID        int64     `json:"id"`
+	CIDR      string    `json:"cidr"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const assetGroupColumns = `
+	id,
+	name,
+	COALESCE(owner, ''),
+	COALESCE(environment, ''),
+	COALESCE(description, ''),
+	cidrs::text[],
+	tags,
+	created_at,
+	updated_at
+`
+
+func scanAssetGroup(row interface{ Scan(...any) error }) (*AssetGroup, error) {
+	var g AssetGroup
+	if err := row.Scan(
+		&g.ID,
+		&g.Name,
+		&g.Owner,
+		&g.Environment,
+		&g.Description,
+		pq.Array(&g.CIDRs),
+		pq.Array(&g.Tags),
+		&g.CreatedAt,
+		&g.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if g.CIDRs == nil {
+		g.CIDRs = []string{}
+	}
+	if g.Tags == nil {
+		g.Tags = []string{}
+	}
+	return &g, nil
+}
+
+func (p *Postgres) ListAssetGroups() ([]AssetGroup, error) {
+	rows, err := p.db.Query(`SELECT ` + assetGroupColumns + ` FROM asset_groups ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]AssetGroup, 0)
+	for rows.Next() {
+		g, err := scanAssetGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *g)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) GetAssetGroup(id int64) (*AssetGroup, error) {
+	g, err := scanAssetGroup(p.db.QueryRow(`SELECT `+assetGroupColumns+` FROM asset_groups WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return g, err
+}
+
+func (p *Postgres) GetAssetGroupByName(name string) (*AssetGroup, error) {
+	g, err := scanAssetGroup(p.db.QueryRow(`SELECT `+assetGroupColumns+` FROM asset_groups WHERE name = $1`, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return g, err
+}
+
+// CreateAssetGroup — CIDR-ы должны быть уже нормализованы (netutil.NormalizeCIDRs)
+func (p *Postgres) CreateAssetGroup(g *AssetGroup) error {
+	return p.db.QueryRow(`
+		INSERT INTO asset_groups (name, owner, environment, description, cidrs, tags)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), $5::cidr[], $6)
+		RETURNING id, created_at, updated_at
+	`,
+		g.Name,
+		g.Owner,
+		g.Environment,
+		g.Description,
+		pq.Array(g.CIDRs),
+		pq.Array(g.Tags),
+	).Scan(&g.ID, &g.CreatedAt, &g.UpdatedAt)
+}
+
+func (p *Postgres) UpdateAssetGroup(g *AssetGroup) error {
+	err := p.db.QueryRow(`
+		UPDATE asset_groups
+		SET
+			name = $2,
+			owner = NULLIF($3, ''),
+			environment = NULLIF($4, ''),
+			description = NULLIF($5, ''),
+			cidrs = $6::cidr[],
+			tags = $7,
+			updated_at = now()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`,
+		g.ID,
+		g.Name,
+		g.Owner,
+		g.Environment,
+		g.Description,
+		pq.Array(g.CIDRs),
+		pq.Array(g.Tags),
+	).Scan(&g.CreatedAt, &g.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (p *Postgres) DeleteAssetGroup(id int64) error {
+	res, err := p.db.Exec(`DELETE FROM asset_groups WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) ListTags() ([]Tag, error) {
+	rows, err := p.db.Query(`
+		SELECT id, cidr::text, name, created_at
+		FROM tags
+		ORDER BY cidr, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Tag, 0)
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.CIDR, &t.Name, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// AddTag — идемпотентно: повторный тег на тот же диапазон возвращает существующую запись
+func (p *Postgres) AddTag(cidr, name string) (*Tag, error) {
+	var t Tag
+	err := p.db.QueryRow(`
+		INSERT INTO tags (cidr, name)
+		VALUES ($1::cidr, $2)
+		ON CONFLICT (cidr, name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id, cidr::text, name, created_at
+	`, cidr, name).Scan(&t.ID, &t.CIDR, &t.Name, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (p *Postgres) DeleteTag(id int64) error {
+	res, err := p.db.Exec(`DELETE FROM tags WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// HostAssets — эффективные теги и группы хоста (с учётом наследования от диапазонов)
+func (p *Postgres) HostAssets(ip string) (tags []string, groups []string, err error) {
+	err = p.db.QueryRow(`
+		SELECT
+			ARRAY(
+				SELECT ht.tag
+				FROM host_tags ht
+				JOIN hosts h ON h.id = ht.host_id
+				WHERE h.ip = $1::inet
+				ORDER BY ht.tag
+			),
+			ARRAY(
+				SELECT hg.group_name
+				FROM host_groups hg
+				JOIN hosts h ON h.id = hg.host_id
+				WHERE h.ip = $1::inet
+				ORDER BY hg.group_name
+			)
+	`, ip).Scan(pq.Array(&tags), pq.Array(&groups))
+	return tags, groups, err
+}
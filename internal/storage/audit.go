@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditEntry — запись журнала действий
+type AuditEntry struct {
+	ID        int64          `json:"id"`
+	TS        time.Time      `json:"ts"`
+	Actor     string         `json:"actor"`
+	ActorType string         `json:"actor_type"`
+	UserID    *int64         `json:"user_id,omitempty"`
+	TokenID   *int64         `json:"token_id,omitempty"`
+	Action    string         `json:"action"`
+	Params    map[string]any `json:"params"`
+	SourceIP  string         `json:"source_ip"`
+	Outcome   string         `json:"outcome"`
+	Status    int            `json:"status"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// AuditFilter — фильтры /api/audit; Action с "*" на конце — префикс ("scan.*")
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	Outcome  string
+	SourceIP string
+	From     time.Time
+	To       time.Time
+	BeforeID int64 // пагинация: записи старше этого id
+	Limit    int   // 0 — без ограничения (экспорт)
+}
+
+func (p *Postgres) AddAudit(e *AuditEntry) error {
+	if e.Params == nil {
+		e.Params = map[string]any{}
+	}
+	params, err := json.Marshal(e.Params)
+	if err != nil {
+		return err
+	}
+	return p.db.QueryRow(`
+		INSERT INTO audit_log (actor, actor_type, user_id, token_id, action, params, source_ip, outcome, status, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, ts
+	`, e.Actor, e.ActorType, e.UserID, e.TokenID, e.Action, params, e.SourceIP, e.Outcome, e.Status, e.Error).
+		Scan(&e.ID, &e.TS)
+}
+
+func (p *Postgres) ListAudit(f AuditFilter) ([]AuditEntry, error) {
+	out := make([]AuditEntry, 0)
+	err := p.EachAudit(f, func(e AuditEntry) error {
+		out = append(out, e)
+		return nil
+	})
+	return out, err
+}
+
+// EachAudit — потоковый обход (для экспорта без загрузки всего в память)
+func (p *Postgres) EachAudit(f AuditFilter, fn func(AuditEntry) error) error {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.Actor != "" {
+		where = append(where, "actor = "+arg(f.Actor))
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, "*") {
+			where = append(where, "action LIKE "+arg(strings.TrimSuffix(f.Action, "*")+"%"))
+		} else {
+			where = append(where, "action = "+arg(f.Action))
+		}
+	}
+	if f.Outcome != "" {
+		where = append(where, "outcome = "+arg(f.Outcome))
+	}
+	if f.SourceIP != "" {
+		where = append(where, "source_ip = "+arg(f.SourceIP))
+	}
+	if !f.From.IsZero() {
+		where = append(where, "ts >= "+arg(f.From))
+	}
+	if !f.To.IsZero() {
+		where = append(where, "ts < "+arg(f.To))
+	}
+	if f.BeforeID > 0 {
+		where = append(where, "id < "+arg(f.BeforeID))
+	}
+
+	q := `SELECT id, ts, actor, actor_type, user_id, token_id, action, params, source_ip, outcome, status, error
+		FROM audit_log`
+	if len(where) > 0 {
+		q += " WHERE " + strings.Join(where, " AND ")
+	}
+	q += " ORDER BY id DESC"
+	if f.Limit > 0 {
+		q += " LIMIT " + arg(f.Limit)
+	}
+
+	rows, err := p.db.Query(q, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			e      AuditEntry
+			params []byte
+		)
+		if err := rows.Scan(&e.ID, &e.TS, &e.Actor, &e.ActorType, &e.UserID, &e.TokenID,
+			&e.Action, &params, &e.SourceIP, &e.Outcome, &e.Status, &e.Error); err != nil {
+			return err
+		}
+		if err := json.Unmarshal(params, &e.Params); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
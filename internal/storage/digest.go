@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Digest — сводка активности за период (один скан или день/неделя)
+type Digest struct {
+	Kind    string    `json:"kind"` // scan | daily | weekly
+	ScanID  string    `json:"scan_id,omitempty"`
+	Engine  string    `json:"engine,omitempty"`
+	Targets string    `json:"targets,omitempty"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+
+	Scans        int `json:"scans"`
+	OpenPorts    int `json:"open_ports"` // открытые порты, увиденные за период
+	NewPorts     int `json:"new_ports"`
+	ClosedPorts  int `json:"closed_ports"`
+	ChangedPorts int `json:"changed_ports"`
+	Violations   int `json:"violations"`
+
+	TopServices         []ServiceCount    `json:"top_services"`
+	NewSample           []DigestPort      `json:"new_sample"`
+	ViolationHighlights []DigestViolation `json:"violation_highlights"`
+
+	ReportID int64 `json:"report_id,omitempty"` // HTML-отчёт за тот же период (digest.report)
+}
+
+type ServiceCount struct {
+	Service string `json:"service"`
+	Count   int    `json:"count"`
+}
+
+type DigestPort struct {
+	IP      string `json:"ip"`
+	Port    int    `json:"port"`
+	Proto   string `json:"proto"`
+	Service string `json:"service"`
+}
+
+type DigestViolation struct {
+	IP       string   `json:"ip"`
+	Port     int      `json:"port"`
+	Service  string   `json:"service"`
+	Severity string   `json:"severity"`
+	Policies []string `json:"policies"`
+}
+
+// Payload — сводка в виде events.payload
+func (d *Digest) Payload() (map[string]any, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
+
+// DigestFromPayload — обратное преобразование (для рендера уведомления)
+func DigestFromPayload(payload map[string]any) (*Digest, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var d Digest
+	err = json.Unmarshal(b, &d)
+	return &d, err
+}
+
+// BuildDigest — сводка за [from, to); top — сколько строк в топах/выборках
+func (p *Postgres) BuildDigest(from, to time.Time, top int) (*Digest, error) {
+	from, to = from.UTC(), to.UTC()
+	d := &Digest{
+		From:                from,
+		To:                  to,
+		TopServices:         []ServiceCount{},
+		NewSample:           []DigestPort{},
+		ViolationHighlights: []DigestViolation{},
+	}
+
+	err := p.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM scans WHERE started_at >= $1 AND started_at < $2),
+			(SELECT COUNT(*) FROM ports WHERE state = 'open' AND last_seen >= $1 AND last_seen < $2),
+			(SELECT COUNT(*) FROM ports WHERE first_seen >= $1 AND first_seen < $2),
+			(SELECT COUNT(*) FROM ports WHERE closed_at >= $1 AND closed_at < $2),
+			(SELECT COUNT(*) FROM ports WHERE changed_at >= $1 AND changed_at < $2),
+			(SELECT COUNT(*) FROM events WHERE type = 'policy_violation' AND created_at >= $1 AND created_at < $2)
+	`, from, to).Scan(&d.Scans, &d.OpenPorts, &d.NewPorts, &d.ClosedPorts, &d.ChangedPorts, &d.Violations)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := p.db.Query(`
+		SELECT COALESCE(NULLIF(service, ''), 'unknown') AS svc, COUNT(*)
+		FROM ports
+		WHERE state = 'open' AND last_seen >= $1 AND last_seen < $2
+		GROUP BY svc
+		ORDER BY COUNT(*) DESC, svc
+		LIMIT $3
+	`, from, to, top)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var sc ServiceCount
+		if err := rows.Scan(&sc.Service, &sc.Count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.TopServices = append(d.TopServices, sc)
+	}
+	rows.Close()
+
+	rows, err = p.db.Query(`
+		SELECT host(h.ip), p.port, p.proto, COALESCE(p.service, '')
+		FROM ports p
+		JOIN hosts h ON h.id = p.host_id
+		WHERE p.first_seen >= $1 AND p.first_seen < $2
+		ORDER BY p.first_seen, h.ip, p.port
+		LIMIT $3
+	`, from, to, top)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var dp DigestPort
+		if err := rows.Scan(&dp.IP, &dp.Port, &dp.Proto, &dp.Service); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.NewSample = append(d.NewSample, dp)
+	}
+	rows.Close()
+
+	rows, err = p.db.Query(`
+		SELECT payload
+		FROM events
+		WHERE type = 'policy_violation' AND created_at >= $1 AND created_at < $2
+		ORDER BY CASE payload->>'severity'
+			WHEN 'critical' THEN 4
+			WHEN 'high' THEN 3
+			WHEN 'medium' THEN 2
+			WHEN 'low' THEN 1
+			ELSE 0
+		END DESC, id DESC
+		LIMIT $3
+	`, from, to, top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var ev struct {
+			IP         string `json:"ip"`
+			Port       int    `json:"port"`
+			Service    string `json:"service"`
+			Severity   string `json:"severity"`
+			Violations []struct {
+				Policy string `json:"policy"`
+			} `json:"violations"`
+		}
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return nil, err
+		}
+		dv := DigestViolation{IP: ev.IP, Port: ev.Port, Service: ev.Service, Severity: ev.Severity}
+		for _, v := range ev.Violations {
+			dv.Policies = append(dv.Policies, v.Policy)
+		}
+		d.ViolationHighlights = append(d.ViolationHighlights, dv)
+	}
+	return d, rows.Err()
+}
+
+// ClaimDigestPeriod — true, если дайджест kind за период с концом periodEnd
+// ещё никто не отправлял (и теперь он «наш»)
+func (p *Postgres) ClaimDigestPeriod(kind string, periodEnd time.Time) (bool, error) {
+	res, err := p.db.Exec(`
+		INSERT INTO digest_runs (kind, period_end)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, kind, periodEnd.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
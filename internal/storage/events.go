@@ -1,17 +1,82 @@
 package storage
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"sort"
 	"time"
+
+	"github.com/lib/pq"
 )
 
-// Event — событие для Telegram / audit / future hooks
+// Event — событие для уведомлений / audit / future hooks
 type Event struct {
-	ID        int64                  `json:"id"`
-	Type      string                 `json:"type"`
-	Payload   map[string]any         `json:"payload"`
-	CreatedAt time.Time              `json:"created_at"`
-	Delivered bool                   `json:"delivered"`
+	ID        int64          `json:"id"`
+	Type      string         `json:"type"`
+	Payload   map[string]any `json:"payload"`
+	CreatedAt time.Time      `json:"created_at"`
+	Delivered bool           `json:"delivered"`
+
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	LastError     string    `json:"last_error,omitempty"`
+	Dead          bool      `json:"dead"`
+
+	// Channels == nil — событие ещё не прошло через правила маршрутизации
+	Channels    []string `json:"channels"`
+	DeliveredTo []string `json:"delivered_to"`
+
+	// LeaseToken — аренда, под которой событие взято ClaimEvents
+	LeaseToken string `json:"-"`
+}
+
+// ErrLeaseLost — аренда события истекла и его перехватил другой воркер
+var ErrLeaseLost = errors.New("event lease lost")
+
+const eventColumns = `id, type, payload, created_at, delivered,
+	attempts, next_attempt_at, COALESCE(last_error, ''), dead, channels, delivered_to`
+
+func scanEvent(row interface{ Scan(...any) error }) (*Event, error) {
+	var (
+		e       Event
+		payload []byte
+	)
+	if err := row.Scan(
+		&e.ID,
+		&e.Type,
+		&payload,
+		&e.CreatedAt,
+		&e.Delivered,
+		&e.Attempts,
+		&e.NextAttemptAt,
+		&e.LastError,
+		&e.Dead,
+		pq.Array(&e.Channels),
+		pq.Array(&e.DeliveredTo),
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(payload, &e.Payload); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func scanEvents(rows *sql.Rows) ([]Event, error) {
+	defer rows.Close()
+
+	out := make([]Event, 0)
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *e)
+	}
+	return out, rows.Err()
 }
 
 // AddEvent — сохраняет новое событие
@@ -29,53 +94,156 @@ func (p *Postgres) AddEvent(eventType string, payload map[string]any) error {
 	return err
 }
 
-// ListUndeliveredEvents — события, которые ещё не отправлены
-func (p *Postgres) ListUndeliveredEvents(limit int) ([]Event, error) {
-	rows, err := p.db.Query(`
-		SELECT id, type, payload, created_at, delivered
-		FROM events
-		WHERE delivered = false
-		ORDER BY id
-		LIMIT $1
-	`, limit)
+// ClaimEvents — забирает пачку готовых к отправке событий.
+// FOR UPDATE SKIP LOCKED + сдвиг next_attempt_at на lease: параллельные воркеры
+// не возьмут одно и то же событие, а если воркер умрёт — событие вернётся после lease.
+// Каждое событие получает lease_token: завершить его и продлить аренду может
+// только тот, кто взял его последним.
+func (p *Postgres) ClaimEvents(limit int, lease time.Duration) ([]Event, error) {
+	token, err := newLeaseToken()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var out []Event
+	rows, err := p.db.Query(`
+		UPDATE events e
+		SET attempts = e.attempts + 1,
+		    next_attempt_at = now() + $2 * interval '1 second',
+		    lease_token = $3
+		FROM (
+			SELECT id FROM events
+			WHERE delivered = false AND dead = false AND next_attempt_at <= now()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		) c
+		WHERE e.id = c.id
+		RETURNING e.id, e.type, e.payload, e.created_at, e.delivered,
+			e.attempts, e.next_attempt_at, COALESCE(e.last_error, ''), e.dead, e.channels, e.delivered_to
+	`, limit, int(lease.Seconds()), token)
+	if err != nil {
+		return nil, err
+	}
 
-	for rows.Next() {
-		var e Event
-		var payload []byte
-
-		if err := rows.Scan(
-			&e.ID,
-			&e.Type,
-			&payload,
-			&e.CreatedAt,
-			&e.Delivered,
-		); err != nil {
-			return nil, err
-		}
+	out, err := scanEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].LeaseToken = token
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
 
-		if err := json.Unmarshal(payload, &e.Payload); err != nil {
-			return nil, err
-		}
+func newLeaseToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
 
-		out = append(out, e)
+// leased — ErrLeaseLost, если UPDATE по (id, lease_token) ничего не изменил
+func leased(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLeaseLost
 	}
+	return nil
+}
 
-	return out, nil
+// RenewEventLease — продлевает аренду события ещё на lease от текущего момента.
+// ErrLeaseLost — событие уже у другого воркера, слать его нельзя.
+func (p *Postgres) RenewEventLease(e *Event, lease time.Duration) error {
+	return leased(p.db.Exec(`
+		UPDATE events
+		SET next_attempt_at = now() + $3 * interval '1 second'
+		WHERE id = $1 AND lease_token = $2 AND delivered = false AND dead = false
+	`, e.ID, e.LeaseToken, int(lease.Seconds())))
+}
+
+// SetEventChannels — фиксирует результат маршрутизации (повторы идут в те же каналы)
+func (p *Postgres) SetEventChannels(id int64, channels []string) error {
+	if channels == nil {
+		channels = []string{} // пустой маршрут != «ещё не маршрутизировано»
+	}
+	_, err := p.db.Exec(`UPDATE events SET channels = $2 WHERE id = $1`, id, pq.Array(channels))
+	return err
 }
 
-// MarkEventDelivered — помечает событие как доставленное
-func (p *Postgres) MarkEventDelivered(id int64) error {
+// MarkEventChannelDelivered — канал получил событие, при ретрае его не трогаем
+func (p *Postgres) MarkEventChannelDelivered(id int64, channel string) error {
 	_, err := p.db.Exec(`
 		UPDATE events
-		SET delivered = true
-		WHERE id = $1
+		SET delivered_to = array_append(delivered_to, $2)
+		WHERE id = $1 AND NOT ($2 = ANY(delivered_to))
+	`, id, channel)
+	return err
+}
+
+// MarkEventDelivered — помечает событие как доставленное, если аренда ещё наша
+func (p *Postgres) MarkEventDelivered(e *Event) error {
+	return leased(p.db.Exec(`
+		UPDATE events
+		SET delivered = true, last_error = NULL, lease_token = NULL
+		WHERE id = $1 AND lease_token = $2
+	`, e.ID, e.LeaseToken))
+}
+
+// FailEvent — неудачная попытка: следующая в next, либо dead-letter.
+// ErrLeaseLost — событие уже у другого воркера, его результат главнее.
+func (p *Postgres) FailEvent(e *Event, lastErr string, next time.Time, dead bool) error {
+	return leased(p.db.Exec(`
+		UPDATE events
+		SET last_error = $3, next_attempt_at = $4, dead = $5, lease_token = NULL
+		WHERE id = $1 AND lease_token = $2
+	`, e.ID, e.LeaseToken, lastErr, next.UTC(), dead))
+}
+
+// ListDeadEvents — события, для которых исчерпаны попытки доставки
+func (p *Postgres) ListDeadEvents(limit int) ([]Event, error) {
+	rows, err := p.db.Query(`
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE dead = true
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+// ReplayEvent — возвращает dead-событие в очередь (каналы из delivered_to повторно не получат).
+// ErrNotFound, если такого dead-события нет.
+func (p *Postgres) ReplayEvent(id int64) error {
+	res, err := p.db.Exec(`
+		UPDATE events
+		SET dead = false, attempts = 0, next_attempt_at = now(), last_error = NULL
+		WHERE id = $1 AND dead = true
 	`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
 
-	return err
+// ReplayDeadEvents — возвращает в очередь все dead-события
+func (p *Postgres) ReplayDeadEvents() (int64, error) {
+	res, err := p.db.Exec(`
+		UPDATE events
+		SET dead = false, attempts = 0, next_attempt_at = now(), last_error = NULL
+		WHERE dead = true
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
@@ -1,6 +1,11 @@
 package storage
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/osguess"
+)
 
 func (p *Postgres) UpsertHost(ip string) (int64, error) {
 	var id int64
@@ -16,3 +21,26 @@ func (p *Postgres) UpsertHost(ip string) (int64, error) {
 
 	return id, err
 }
+
+// UpdateHostOS — сохраняет предположение об ОС.
+// Более слабая догадка не перетирает сильную, пока та не устарела (30 дней):
+// частичный скан (например, только 80/tcp) не должен «откатывать» nmap -O.
+func (p *Postgres) UpdateHostOS(hostID int64, g osguess.Guess) error {
+	_, err := p.db.Exec(`
+		UPDATE hosts
+		SET
+			os_name = NULLIF($2, ''),
+			os_family = $3,
+			os_confidence = $4,
+			os_sources = $5,
+			os_updated_at = now()
+		WHERE id = $1
+		  AND (
+			os_confidence IS NULL
+			OR $4 >= os_confidence
+			OR os_updated_at < now() - interval '30 days'
+		  )
+	`, hostID, g.Name, g.Family, g.Confidence, strings.Join(g.Sources, ","))
+
+	return err
+}
@@ -0,0 +1,382 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/netutil"
+	"github.com/L1nMay/portscanner/internal/policy"
+	"github.com/lib/pq"
+)
+
+// HostSummary — строка списка /api/hosts
+type HostSummary struct {
+	IP        string    `json:"ip"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	OSName       string `json:"os_name,omitempty"`
+	OSFamily     string `json:"os_family,omitempty"`
+	OSConfidence int    `json:"os_confidence,omitempty"`
+
+	OpenPorts   int      `json:"open_ports"`
+	ClosedPorts int      `json:"closed_ports"`
+	Services    []string `json:"services"`
+	Tags        []string `json:"tags"`
+	Groups      []string `json:"groups"`
+	Violations  int      `json:"violations"`
+}
+
+type HostPage struct {
+	Items      []HostSummary `json:"items"`
+	Total      int           `json:"total"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// HostFilter — фильтры /api/hosts; курсор — последний ip предыдущей страницы
+type HostFilter struct {
+	CIDR     string
+	Tag      string
+	Group    string
+	OSFamily string
+	Port     int // хост с этим открытым портом
+	Limit    int
+	Cursor   string
+}
+
+// HostGroupInfo — группа активов хоста с владельцем и окружением
+type HostGroupInfo struct {
+	Name        string `json:"name"`
+	Owner       string `json:"owner,omitempty"`
+	Environment string `json:"environment,omitempty"`
+}
+
+// HostPort — порт хоста (в т.ч. закрытый)
+type HostPort struct {
+	Port      int        `json:"port"`
+	Proto     string     `json:"proto"`
+	Service   string     `json:"service"`
+	Banner    string     `json:"banner"`
+	State     string     `json:"state"`
+	FirstSeen time.Time  `json:"first_seen"`
+	LastSeen  time.Time  `json:"last_seen"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+	ChangedAt *time.Time `json:"changed_at,omitempty"`
+
+	Compliance string             `json:"compliance,omitempty"`
+	Violations []policy.Violation `json:"violations,omitempty"`
+}
+
+// HostObservation — что увидел один скан на этом хосте
+type HostObservation struct {
+	ScanID    string    `json:"scan_id"`
+	StartedAt time.Time `json:"started_at"`
+	Engine    string    `json:"engine"`
+	Open      []string  `json:"open"`   // "22/tcp"
+	Closed    []string  `json:"closed"` // закрыты этим сканом
+}
+
+type HostDetail struct {
+	HostSummary
+	OSSources   []string   `json:"os_sources,omitempty"`
+	OSUpdatedAt *time.Time `json:"os_updated_at,omitempty"`
+
+	AssetGroups []HostGroupInfo   `json:"asset_groups"`
+	Ports       []HostPort        `json:"ports"`
+	Timeline    []HostObservation `json:"timeline"`
+	Events      []Event           `json:"events"`
+
+	Compliance string `json:"compliance"` // compliant | violating | unchecked
+}
+
+const hostSummaryColumns = `
+	host(h.ip),
+	h.first_seen,
+	h.last_seen,
+	COALESCE(h.os_name, ''),
+	COALESCE(h.os_family, ''),
+	COALESCE(h.os_confidence, 0),
+	(SELECT COUNT(*) FROM ports p WHERE p.host_id = h.id AND p.state = 'open'),
+	(SELECT COUNT(*) FROM ports p WHERE p.host_id = h.id AND p.state = 'closed'),
+	ARRAY(SELECT DISTINCT COALESCE(p.service, 'unknown') FROM ports p WHERE p.host_id = h.id AND p.state = 'open' ORDER BY 1),
+	ARRAY(SELECT ht.tag FROM host_tags ht WHERE ht.host_id = h.id ORDER BY ht.tag),
+	ARRAY(SELECT hg.group_name FROM host_groups hg WHERE hg.host_id = h.id ORDER BY hg.group_name),
+	(SELECT COUNT(*) FROM ports p WHERE p.host_id = h.id AND p.state = 'open' AND p.compliance = 'violating')`
+
+func scanHostSummary(row interface{ Scan(...any) error }, extra ...any) (*HostSummary, error) {
+	var h HostSummary
+	dest := append([]any{
+		&h.IP, &h.FirstSeen, &h.LastSeen,
+		&h.OSName, &h.OSFamily, &h.OSConfidence,
+		&h.OpenPorts, &h.ClosedPorts,
+		pq.Array(&h.Services), pq.Array(&h.Tags), pq.Array(&h.Groups),
+		&h.Violations,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (p *Postgres) ListHosts(f HostFilter) (*HostPage, error) {
+	where := []string{"TRUE"}
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if v := strings.TrimSpace(f.CIDR); v != "" {
+		cidr, err := netutil.NormalizeCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		where = append(where, "h.ip <<= "+arg(cidr)+"::inet")
+	}
+	if v := strings.TrimSpace(f.Tag); v != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM host_tags ht WHERE ht.host_id = h.id AND ht.tag = "+arg(v)+")")
+	}
+	if v := strings.TrimSpace(f.Group); v != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM host_groups hg WHERE hg.host_id = h.id AND hg.group_name = "+arg(v)+")")
+	}
+	if v := strings.ToLower(strings.TrimSpace(f.OSFamily)); v != "" {
+		if v == "unknown" {
+			where = append(where, "h.os_family IS NULL")
+		} else {
+			where = append(where, "h.os_family = "+arg(v))
+		}
+	}
+	if f.Port > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM ports p WHERE p.host_id = h.id AND p.state = 'open' AND p.port = "+arg(f.Port)+")")
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultResultLimit
+	}
+	if limit > MaxResultLimit {
+		limit = MaxResultLimit
+	}
+
+	from := " FROM hosts h WHERE " + strings.Join(where, " AND ")
+	page := &HostPage{Items: make([]HostSummary, 0, limit)}
+	if err := p.db.QueryRow(`SELECT COUNT(*)`+from, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	if f.Cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+		if _, perr := netip.ParseAddr(string(b)); err != nil || perr != nil {
+			return nil, fmt.Errorf("%w: bad cursor", ErrInvalidFilter)
+		}
+		from += " AND h.ip > " + arg(string(b)) + "::inet"
+	}
+
+	rows, err := p.db.Query(`SELECT `+hostSummaryColumns+from+` ORDER BY h.ip LIMIT `+arg(limit+1), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		h, err := scanHostSummary(rows)
+		if err != nil {
+			return nil, err
+		}
+		page.Items = append(page.Items, *h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(page.Items[limit-1].IP))
+	}
+	return page, nil
+}
+
+// GetHost — карточка хоста: обогащение, все порты, история сканов, события и политика
+func (p *Postgres) GetHost(ip string) (*HostDetail, error) {
+	var (
+		d       HostDetail
+		hostID  int64
+		sources string
+	)
+	h, err := scanHostSummary(p.db.QueryRow(`
+		SELECT `+hostSummaryColumns+`, h.id, COALESCE(h.os_sources, ''), h.os_updated_at
+		FROM hosts h
+		WHERE h.ip = $1::inet
+	`, ip), &hostID, &sources, &d.OSUpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	d.HostSummary = *h
+	if sources != "" {
+		d.OSSources = strings.Split(sources, ",")
+	}
+
+	if d.AssetGroups, err = p.hostGroups(hostID); err != nil {
+		return nil, err
+	}
+	if d.Ports, err = p.hostPorts(hostID); err != nil {
+		return nil, err
+	}
+	if d.Timeline, err = p.hostTimeline(hostID, 50); err != nil {
+		return nil, err
+	}
+	if d.Events, err = p.hostEvents(d.IP, 50); err != nil {
+		return nil, err
+	}
+
+	d.Compliance = "unchecked"
+	checked := 0
+	for _, pt := range d.Ports {
+		if pt.State != "open" || pt.Compliance == "" {
+			continue
+		}
+		checked++
+		if pt.Compliance == "violating" {
+			d.Compliance = "violating"
+		}
+	}
+	if checked > 0 && d.Compliance != "violating" {
+		d.Compliance = "compliant"
+	}
+	return &d, nil
+}
+
+func (p *Postgres) hostGroups(hostID int64) ([]HostGroupInfo, error) {
+	rows, err := p.db.Query(`
+		SELECT g.name, COALESCE(g.owner, ''), COALESCE(g.environment, '')
+		FROM host_groups hg
+		JOIN asset_groups g ON g.id = hg.group_id
+		WHERE hg.host_id = $1
+		ORDER BY g.name
+	`, hostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]HostGroupInfo, 0)
+	for rows.Next() {
+		var g HostGroupInfo
+		if err := rows.Scan(&g.Name, &g.Owner, &g.Environment); err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) hostPorts(hostID int64) ([]HostPort, error) {
+	rows, err := p.db.Query(`
+		SELECT port, proto, COALESCE(service, 'unknown'), COALESCE(banner, ''), state,
+			first_seen, last_seen, closed_at, changed_at, COALESCE(compliance, ''), violations
+		FROM ports
+		WHERE host_id = $1
+		ORDER BY state DESC, port, proto
+	`, hostID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]HostPort, 0)
+	for rows.Next() {
+		var (
+			hp         HostPort
+			violations []byte
+		)
+		if err := rows.Scan(&hp.Port, &hp.Proto, &hp.Service, &hp.Banner, &hp.State,
+			&hp.FirstSeen, &hp.LastSeen, &hp.ClosedAt, &hp.ChangedAt, &hp.Compliance, &violations); err != nil {
+			return nil, err
+		}
+		if len(violations) > 0 {
+			if err := json.Unmarshal(violations, &hp.Violations); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, hp)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) hostTimeline(hostID int64, limit int) ([]HostObservation, error) {
+	rows, err := p.db.Query(`
+		SELECT
+			o.scan_id::text,
+			COALESCE(s.started_at, MIN(o.seen_at)),
+			COALESCE(s.engine, ''),
+			ARRAY_AGG(pt.port || '/' || pt.proto ORDER BY pt.port) FILTER (WHERE o.state = 'open'),
+			ARRAY_AGG(pt.port || '/' || pt.proto ORDER BY pt.port) FILTER (WHERE o.state = 'closed')
+		FROM port_observations o
+		JOIN ports pt ON pt.id = o.port_id
+		LEFT JOIN scans s ON s.id = o.scan_id
+		WHERE pt.host_id = $1
+		GROUP BY o.scan_id, s.started_at, s.engine
+		ORDER BY 2 DESC
+		LIMIT $2
+	`, hostID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]HostObservation, 0)
+	for rows.Next() {
+		var o HostObservation
+		if err := rows.Scan(&o.ScanID, &o.StartedAt, &o.Engine, pq.Array(&o.Open), pq.Array(&o.Closed)); err != nil {
+			return nil, err
+		}
+		if o.Open == nil {
+			o.Open = []string{}
+		}
+		if o.Closed == nil {
+			o.Closed = []string{}
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+// hostEvents — события, в payload которых указан этот ip
+func (p *Postgres) hostEvents(ip string, limit int) ([]Event, error) {
+	rows, err := p.db.Query(`
+		SELECT `+eventColumns+`
+		FROM events
+		WHERE payload->>'ip' = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, ip, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(rows)
+}
+
+// RecordObservations — фиксирует, что увидел скан scanID: открытые порты,
+// обновлённые с since, и закрытые им порты. Сканы идут строго по одному
+// (mutex в Runner), поэтому «обновлён после начала» = «найден этим сканом».
+func (p *Postgres) RecordObservations(scanID string, since time.Time) error {
+	_, err := p.db.Exec(`
+		INSERT INTO port_observations (scan_id, port_id, state, seen_at)
+		SELECT $1::uuid, id, state, CASE WHEN state = 'closed' THEN closed_at ELSE last_seen END
+		FROM ports
+		WHERE (state = 'open' AND last_seen >= $2)
+		   OR (state = 'closed' AND closed_at >= $2)
+		ON CONFLICT (scan_id, port_id) DO NOTHING
+	`, scanID, since.UTC())
+	return err
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+)
+
+// telegramPollLockKey — advisory lock экземпляра, который опрашивает getUpdates
+const telegramPollLockKey = 0x706f7274_74677570 // "porttgup"
+
+// SessionLock — сессионный advisory lock на выделенном соединении
+// (при обрыве соединения Postgres снимает его сам)
+type SessionLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryTelegramPollLock — Bot API отдаёт getUpdates только одному опрашивающему
+// на токен (остальным 409 Conflict); nil без ошибки, если lock держит
+// другой экземпляр
+func (p *Postgres) TryTelegramPollLock(ctx context.Context) (*SessionLock, error) {
+	return p.trySessionLock(ctx, telegramPollLockKey)
+}
+
+func (p *Postgres) trySessionLock(ctx context.Context, key int64) (*SessionLock, error) {
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !ok {
+		_ = conn.Close()
+		return nil, nil
+	}
+	return &SessionLock{conn: conn, key: key}, nil
+}
+
+// Alive — соединение с lock живо
+func (l *SessionLock) Alive(ctx context.Context) bool {
+	return l.conn.PingContext(ctx) == nil
+}
+
+func (l *SessionLock) Release() {
+	_, _ = l.conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, l.key)
+	_ = l.conn.Close()
+}
@@ -0,0 +1,67 @@
+package storage
+
+import "time"
+
+// OpenPortsByService — открытые порты по сервису (пустой сервис — unknown)
+func (p *Postgres) OpenPortsByService() ([]ServiceCount, error) {
+	rows, err := p.db.Query(`
+		SELECT lower(COALESCE(NULLIF(service, ''), 'unknown')) AS svc, COUNT(*)
+		FROM ports
+		WHERE state = 'open'
+		GROUP BY svc
+		ORDER BY svc
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ServiceCount
+	for rows.Next() {
+		var c ServiceCount
+		if err := rows.Scan(&c.Service, &c.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// EventQueueDepth — события, ожидающие доставки, и dead-letter
+func (p *Postgres) EventQueueDepth() (pending, dead int, err error) {
+	err = p.db.QueryRow(`
+		SELECT
+			COUNT(*) FILTER (WHERE delivered = false AND dead = false),
+			COUNT(*) FILTER (WHERE dead = true)
+		FROM events
+	`).Scan(&pending, &dead)
+	return pending, dead, err
+}
+
+// LastSuccessfulScans — время окончания последнего успешного скана по источнику
+// (scan — свои сканы, import — импортированные результаты)
+func (p *Postgres) LastSuccessfulScans() (map[string]time.Time, error) {
+	rows, err := p.db.Query(`
+		SELECT source, MAX(finished_at)
+		FROM scans
+		WHERE status = 'finished' AND finished_at IS NOT NULL
+		GROUP BY source
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]time.Time{}
+	for rows.Next() {
+		var (
+			source string
+			at     time.Time
+		)
+		if err := rows.Scan(&source, &at); err != nil {
+			return nil, err
+		}
+		out[source] = at
+	}
+	return out, rows.Err()
+}
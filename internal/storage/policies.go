@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/L1nMay/portscanner/internal/policy"
+	"github.com/lib/pq"
+)
+
+const policyColumns = `
+	id,
+	name,
+	COALESCE(description, ''),
+	enabled,
+	mode,
+	scope_type,
+	scope,
+	ports,
+	services,
+	severity,
+	created_at,
+	updated_at
+`
+
+func scanPolicy(row interface{ Scan(...any) error }) (*policy.Policy, error) {
+	var p policy.Policy
+	if err := row.Scan(
+		&p.ID,
+		&p.Name,
+		&p.Description,
+		&p.Enabled,
+		&p.Mode,
+		&p.ScopeType,
+		&p.Scope,
+		&p.Ports,
+		pq.Array(&p.Services),
+		&p.Severity,
+		&p.CreatedAt,
+		&p.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	if p.Services == nil {
+		p.Services = []string{}
+	}
+	return &p, nil
+}
+
+// ListPolicies — все политики (onlyEnabled — только включённые, для проверки находок)
+func (p *Postgres) ListPolicies(onlyEnabled bool) ([]policy.Policy, error) {
+	rows, err := p.db.Query(`
+		SELECT `+policyColumns+`
+		FROM policies
+		WHERE enabled OR NOT $1
+		ORDER BY name
+	`, onlyEnabled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]policy.Policy, 0)
+	for rows.Next() {
+		pol, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *pol)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) GetPolicy(id int64) (*policy.Policy, error) {
+	pol, err := scanPolicy(p.db.QueryRow(`SELECT `+policyColumns+` FROM policies WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return pol, err
+}
+
+// CreatePolicy — политика должна пройти policy.Normalize
+func (p *Postgres) CreatePolicy(pol *policy.Policy) error {
+	return p.db.QueryRow(`
+		INSERT INTO policies (name, description, enabled, mode, scope_type, scope, ports, services, severity)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`,
+		pol.Name,
+		pol.Description,
+		pol.Enabled,
+		pol.Mode,
+		pol.ScopeType,
+		pol.Scope,
+		pol.Ports,
+		pq.Array(pol.Services),
+		pol.Severity,
+	).Scan(&pol.ID, &pol.CreatedAt, &pol.UpdatedAt)
+}
+
+func (p *Postgres) UpdatePolicy(pol *policy.Policy) error {
+	err := p.db.QueryRow(`
+		UPDATE policies
+		SET
+			name = $2,
+			description = NULLIF($3, ''),
+			enabled = $4,
+			mode = $5,
+			scope_type = $6,
+			scope = $7,
+			ports = $8,
+			services = $9,
+			severity = $10,
+			updated_at = now()
+		WHERE id = $1
+		RETURNING created_at, updated_at
+	`,
+		pol.ID,
+		pol.Name,
+		pol.Description,
+		pol.Enabled,
+		pol.Mode,
+		pol.ScopeType,
+		pol.Scope,
+		pol.Ports,
+		pq.Array(pol.Services),
+		pol.Severity,
+	).Scan(&pol.CreatedAt, &pol.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (p *Postgres) DeletePolicy(id int64) error {
+	res, err := p.db.Exec(`DELETE FROM policies WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SetPortCompliance — пишет результат проверки находки и возвращает предыдущие нарушения
+// (чтобы событие policy_violation поднималось только на новые нарушения)
+func (p *Postgres) SetPortCompliance(
+	hostID int64,
+	port int,
+	proto string,
+	violations []policy.Violation,
+) ([]policy.Violation, error) {
+	data, err := json.Marshal(violations)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev []byte
+	err = p.db.QueryRow(`
+		WITH old AS (
+			SELECT id, violations
+			FROM ports
+			WHERE host_id = $1 AND port = $2 AND proto = $3
+			FOR UPDATE
+		)
+		UPDATE ports p
+		SET compliance = $4, violations = $5
+		FROM old
+		WHERE p.id = old.id
+		RETURNING old.violations
+	`, hostID, port, proto, policy.Status(violations), data).Scan(&prev)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []policy.Violation
+	if len(prev) > 0 {
+		if err := json.Unmarshal(prev, &out); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// PortFinding — открытый порт с контекстом хоста (для массовой перепроверки политик)
+type PortFinding struct {
+	HostID  int64
+	IP      string
+	Port    int
+	Proto   string
+	Service string
+	Tags    []string
+	Groups  []string
+}
+
+func (p *Postgres) ListPortFindings() ([]PortFinding, error) {
+	rows, err := p.db.Query(`
+		SELECT
+			h.id,
+			host(h.ip),
+			p.port,
+			p.proto,
+			COALESCE(p.service, 'unknown'),
+			ARRAY(SELECT ht.tag FROM host_tags ht WHERE ht.host_id = h.id ORDER BY ht.tag),
+			ARRAY(SELECT hg.group_name FROM host_groups hg WHERE hg.host_id = h.id ORDER BY hg.group_name)
+		FROM ports p
+		JOIN hosts h ON h.id = p.host_id
+		WHERE p.state = 'open'
+		ORDER BY h.ip, p.port
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]PortFinding, 0, 256)
+	for rows.Next() {
+		var f PortFinding
+		if err := rows.Scan(
+			&f.HostID,
+			&f.IP,
+			&f.Port,
+			&f.Proto,
+			&f.Service,
+			pq.Array(&f.Tags),
+			pq.Array(&f.Groups),
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
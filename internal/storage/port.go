@@ -1,5 +1,16 @@
 package storage
 
+import (
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/model"
+	"github.com/L1nMay/portscanner/internal/netutil"
+	"github.com/lib/pq"
+)
+
+// UpsertPort — сохраняет открытый порт; true, если порт видим впервые.
+// changed_at ставится при смене сервиса (кроме ухода в unknown — это просто
+// неудачный banner grab) и при повторном открытии закрытого порта.
 func (p *Postgres) UpsertPort(
 	hostID int64,
 	port int,
@@ -18,6 +29,14 @@ func (p *Postgres) UpsertPort(
 		ON CONFLICT (host_id, port, proto)
 		DO UPDATE SET
 			last_seen = now(),
+			changed_at = CASE
+				WHEN ports.state = 'closed' THEN now()
+				WHEN EXCLUDED.service <> 'unknown'
+					AND ports.service IS DISTINCT FROM EXCLUDED.service THEN now()
+				ELSE ports.changed_at
+			END,
+			state = 'open',
+			closed_at = NULL,
 			service = COALESCE(EXCLUDED.service, ports.service),
 			banner  = COALESCE(EXCLUDED.banner, ports.banner)
 		RETURNING (xmax = 0)
@@ -25,3 +44,49 @@ func (p *Postgres) UpsertPort(
 
 	return isNew, err
 }
+
+// SetPortCert — сертификат, снятый сканом с порта ip:port/proto
+func (p *Postgres) SetPortCert(ip string, port int, proto string, c *model.Cert) error {
+	_, err := p.db.Exec(`
+		UPDATE ports p
+		SET tls_subject = $4, tls_issuer = $5, tls_dns_names = $6, tls_not_after = $7
+		FROM hosts h
+		WHERE p.host_id = h.id AND h.ip = $1::inet AND p.port = $2 AND p.proto = $3
+	`, ip, port, proto, c.Subject, c.Issuer, pq.Array(c.DNSNames), c.NotAfter.UTC())
+	return err
+}
+
+// MarkClosedPorts — порты, которые попадают в цели и диапазон портов скана,
+// но не были видны с момента since, помечаются закрытыми.
+// Возвращает количество закрытых портов.
+func (p *Postgres) MarkClosedPorts(cidrs []string, ports netutil.PortSet, proto string, since time.Time) (int64, error) {
+	if len(cidrs) == 0 || len(ports) == 0 {
+		return 0, nil
+	}
+
+	from := make([]int64, 0, len(ports))
+	to := make([]int64, 0, len(ports))
+	for _, r := range ports {
+		from = append(from, int64(r.From))
+		to = append(to, int64(r.To))
+	}
+
+	res, err := p.db.Exec(`
+		UPDATE ports p
+		SET state = 'closed', closed_at = now()
+		FROM hosts h
+		WHERE p.host_id = h.id
+		  AND p.state = 'open'
+		  AND p.proto = $4
+		  AND p.last_seen < $5
+		  AND h.ip <<= ANY($1::inet[])
+		  AND EXISTS (
+			SELECT 1 FROM unnest($2::int[], $3::int[]) AS r(lo, hi)
+			WHERE p.port BETWEEN r.lo AND r.hi
+		  )
+	`, pq.Array(cidrs), pq.Array(from), pq.Array(to), proto, since.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -2,10 +2,14 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 
 	_ "github.com/lib/pq"
 )
 
+// ErrNotFound — запись не найдена (для 404 в API)
+var ErrNotFound = errors.New("not found")
+
 type Postgres struct {
 	db *sql.DB
 }
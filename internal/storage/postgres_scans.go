@@ -1,16 +1,41 @@
 package storage
 
-import "github.com/L1nMay/portscanner/internal/model"
+import (
+	"strconv"
 
-func (p *Postgres) AddScanRun(run *model.ScanRun, targets []string) error {
-	targetsStr := ""
-	if len(targets) > 0 {
-		targetsStr = targets[0]
-		if len(targets) > 1 {
-			targetsStr = targetsStr + " (+" + string(len(targets)-1) + ")"
-		}
-	}
+	"github.com/L1nMay/portscanner/internal/model"
+)
 
+// StartScanRun — строка scans со статусом running при старте скана: упавший
+// или прерванный скан остаётся в истории вместе с журналом
+func (p *Postgres) StartScanRun(run *model.ScanRun, targets []string) error {
+	_, err := p.db.Exec(`
+		INSERT INTO scans (
+			id,
+			started_at,
+			engine,
+			targets,
+			ports,
+			found,
+			new_found,
+			status,
+			source
+		) VALUES ($1,$2,$3,$4,$5,0,0,$6,$7)
+		ON CONFLICT (id) DO NOTHING
+	`,
+		run.ID,
+		run.StartedAt,
+		run.Engine,
+		targetsString(targets),
+		run.PortsSpec,
+		model.ScanRunning,
+		runSource(run),
+	)
+	return err
+}
+
+// AddScanRun — итог успешного прогона (строка от StartScanRun обновляется)
+func (p *Postgres) AddScanRun(run *model.ScanRun, targets []string) error {
 	_, err := p.db.Exec(`
 		INSERT INTO scans (
 			id,
@@ -21,19 +46,73 @@ func (p *Postgres) AddScanRun(run *model.ScanRun, targets []string) error {
 			ports,
 			found,
 			new_found,
-			status
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+			status,
+			source
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+		ON CONFLICT (id) DO UPDATE SET
+			finished_at = EXCLUDED.finished_at,
+			engine = EXCLUDED.engine,
+			targets = EXCLUDED.targets,
+			ports = EXCLUDED.ports,
+			found = EXCLUDED.found,
+			new_found = EXCLUDED.new_found,
+			status = EXCLUDED.status,
+			error = NULL
 	`,
 		run.ID,
 		run.StartedAt,
 		run.FinishedAt,
 		run.Engine,
-		targetsStr,
+		targetsString(targets),
 		run.PortsSpec,
 		run.Found,
 		run.NewFound,
-		"finished",
+		model.ScanFinished,
+		runSource(run),
 	)
 
 	return err
 }
+
+// FailScanRun — скан упал (failed) или отменён (cancelled)
+func (p *Postgres) FailScanRun(id, status, msg string) error {
+	_, err := p.db.Exec(`
+		UPDATE scans
+		SET status = $2, error = NULLIF($3, ''), finished_at = now()
+		WHERE id = $1
+	`, id, status, msg)
+	return err
+}
+
+// InterruptScanRuns — прогоны, оставшиеся running после падения или рестарта,
+// помечаются failed. Если такой скан на самом деле ещё идёт (CLI), его
+// итог всё равно перепишет строку через AddScanRun/FailScanRun.
+func (p *Postgres) InterruptScanRuns() (int64, error) {
+	res, err := p.db.Exec(`
+		UPDATE scans
+		SET status = $1, error = 'interrupted', finished_at = now()
+		WHERE status = $2
+	`, model.ScanFailed, model.ScanRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func targetsString(targets []string) string {
+	if len(targets) == 0 {
+		return ""
+	}
+	s := targets[0]
+	if len(targets) > 1 {
+		s = s + " (+" + strconv.Itoa(len(targets)-1) + ")"
+	}
+	return s
+}
+
+func runSource(run *model.ScanRun) string {
+	if run.Source == "" {
+		return model.SourceScan
+	}
+	return run.Source
+}
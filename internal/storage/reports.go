@@ -0,0 +1,394 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/policy"
+)
+
+// reportListLimit — строк в каждом списке отчёта (полные числа — в Summary)
+const reportListLimit = 500
+
+// reportCertWindow — сертификаты, истекающие в этот срок после конца
+// периода, попадают в отчёт
+const reportCertWindow = 30 * 24 * time.Hour
+
+// ReportData — всё, что попадает в HTML-отчёт за период [From, To)
+type ReportData struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+
+	Summary ReportSummary `json:"summary"`
+
+	NewPorts     []ReportPort      `json:"new_ports"`
+	ClosedPorts  []ReportPort      `json:"closed_ports"`
+	ChangedPorts []ReportPort      `json:"changed_ports"`
+	Violations   []ReportViolation `json:"violations"`
+	TopServices  []ServiceCount    `json:"top_services"`
+	Certs        []ReportCert      `json:"expiring_certs"`
+	Groups       []ReportGroup     `json:"groups"`
+}
+
+// ReportSummary — итоговые цифры (хранится в reports.summary)
+type ReportSummary struct {
+	Hosts          int `json:"hosts"`      // хосты с открытыми портами
+	OpenPorts      int `json:"open_ports"` // сейчас
+	Services       int `json:"services"`
+	Scans          int `json:"scans"` // за период
+	NewPorts       int `json:"new_ports"`
+	ClosedPorts    int `json:"closed_ports"`
+	ChangedPorts   int `json:"changed_ports"`
+	ViolatingPorts int `json:"violating_ports"` // сейчас
+	ExpiringCerts  int `json:"expiring_certs"`  // до To + reportCertWindow, включая просроченные
+}
+
+type ReportPort struct {
+	IP      string    `json:"ip"`
+	Port    int       `json:"port"`
+	Proto   string    `json:"proto"`
+	Service string    `json:"service"`
+	Banner  string    `json:"banner,omitempty"`
+	At      time.Time `json:"at"` // first_seen / closed_at / changed_at
+}
+
+type ReportViolation struct {
+	IP         string             `json:"ip"`
+	Port       int                `json:"port"`
+	Proto      string             `json:"proto"`
+	Service    string             `json:"service"`
+	Severity   string             `json:"severity"` // самая серьёзная
+	Violations []policy.Violation `json:"violations"`
+}
+
+// ReportCert — сертификат открытого порта (поля из последнего скана)
+type ReportCert struct {
+	IP       string    `json:"ip"`
+	Port     int       `json:"port"`
+	Proto    string    `json:"proto"`
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+type ReportGroup struct {
+	Name        string `json:"name"`
+	Owner       string `json:"owner,omitempty"`
+	Environment string `json:"environment,omitempty"`
+	Hosts       int    `json:"hosts"`
+	OpenPorts   int    `json:"open_ports"`
+	NewPorts    int    `json:"new_ports"`
+	Violations  int    `json:"violations"`
+}
+
+// Report — сохранённый отчёт (без html)
+type Report struct {
+	ID         int64         `json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Kind       string        `json:"kind"` // manual | daily | weekly
+	CreatedBy  string        `json:"created_by"`
+	PeriodFrom time.Time     `json:"period_from"`
+	PeriodTo   time.Time     `json:"period_to"`
+	Summary    ReportSummary `json:"summary"`
+}
+
+// BuildReportData — данные отчёта за [from, to)
+func (p *Postgres) BuildReportData(from, to time.Time) (*ReportData, error) {
+	from, to = from.UTC(), to.UTC()
+	d := &ReportData{GeneratedAt: time.Now().UTC(), From: from, To: to}
+	s := &d.Summary
+
+	err := p.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(DISTINCT host_id) FROM ports WHERE state = 'open'),
+			(SELECT COUNT(*) FROM ports WHERE state = 'open'),
+			(SELECT COUNT(DISTINCT COALESCE(NULLIF(service, ''), 'unknown')) FROM ports WHERE state = 'open'),
+			(SELECT COUNT(*) FROM scans WHERE started_at >= $1 AND started_at < $2),
+			(SELECT COUNT(*) FROM ports WHERE first_seen >= $1 AND first_seen < $2),
+			(SELECT COUNT(*) FROM ports WHERE state = 'closed' AND closed_at >= $1 AND closed_at < $2),
+			(SELECT COUNT(*) FROM ports WHERE changed_at >= $1 AND changed_at < $2 AND first_seen < $1),
+			(SELECT COUNT(*) FROM ports WHERE state = 'open' AND compliance = 'violating'),
+			(SELECT COUNT(*) FROM ports WHERE state = 'open' AND tls_not_after < $3)
+	`, from, to, to.Add(reportCertWindow)).Scan(&s.Hosts, &s.OpenPorts, &s.Services, &s.Scans,
+		&s.NewPorts, &s.ClosedPorts, &s.ChangedPorts, &s.ViolatingPorts, &s.ExpiringCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.NewPorts, err = p.reportPorts("p.first_seen", "p.first_seen >= $1 AND p.first_seen < $2", from, to); err != nil {
+		return nil, err
+	}
+	if d.ClosedPorts, err = p.reportPorts("p.closed_at", "p.state = 'closed' AND p.closed_at >= $1 AND p.closed_at < $2", from, to); err != nil {
+		return nil, err
+	}
+	// новые порты уже в своём списке
+	if d.ChangedPorts, err = p.reportPorts("p.changed_at", "p.changed_at >= $1 AND p.changed_at < $2 AND p.first_seen < $1", from, to); err != nil {
+		return nil, err
+	}
+	if d.Violations, err = p.reportViolations(); err != nil {
+		return nil, err
+	}
+	if d.TopServices, err = p.reportTopServices(20); err != nil {
+		return nil, err
+	}
+	if d.Certs, err = p.reportCerts(to.Add(reportCertWindow)); err != nil {
+		return nil, err
+	}
+	if d.Groups, err = p.reportGroups(from, to); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (p *Postgres) reportPorts(at, where string, from, to time.Time) ([]ReportPort, error) {
+	rows, err := p.db.Query(`
+		SELECT host(h.ip), p.port, p.proto, COALESCE(p.service, 'unknown'), COALESCE(p.banner, ''), `+at+`
+		FROM ports p
+		JOIN hosts h ON h.id = p.host_id
+		WHERE `+where+`
+		ORDER BY `+at+` DESC, h.ip, p.port
+		LIMIT $3
+	`, from, to, reportListLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportPort, 0)
+	for rows.Next() {
+		var rp ReportPort
+		if err := rows.Scan(&rp.IP, &rp.Port, &rp.Proto, &rp.Service, &rp.Banner, &rp.At); err != nil {
+			return nil, err
+		}
+		out = append(out, rp)
+	}
+	return out, rows.Err()
+}
+
+// reportViolations — текущие нарушения, самые серьёзные первыми (порядок
+// задаёт запрос: иначе LIMIT отрезал бы критичные, далёкие по ip)
+func (p *Postgres) reportViolations() ([]ReportViolation, error) {
+	rows, err := p.db.Query(`
+		SELECT host(h.ip), p.port, p.proto, COALESCE(p.service, 'unknown'), p.violations
+		FROM ports p
+		JOIN hosts h ON h.id = p.host_id
+		WHERE p.state = 'open' AND p.compliance = 'violating'
+		ORDER BY (
+			SELECT MAX(CASE v->>'severity'
+				WHEN 'critical' THEN 4
+				WHEN 'high' THEN 3
+				WHEN 'medium' THEN 2
+				WHEN 'low' THEN 1
+				ELSE 0
+			END)
+			FROM jsonb_array_elements(p.violations) v
+		) DESC NULLS LAST, h.ip, p.port
+		LIMIT $1
+	`, reportListLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportViolation, 0)
+	for rows.Next() {
+		var (
+			rv  ReportViolation
+			raw []byte
+		)
+		if err := rows.Scan(&rv.IP, &rv.Port, &rv.Proto, &rv.Service, &raw); err != nil {
+			return nil, err
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &rv.Violations); err != nil {
+				return nil, err
+			}
+		}
+		for _, v := range rv.Violations {
+			if severityRank(v.Severity) > severityRank(rv.Severity) {
+				rv.Severity = v.Severity
+			}
+		}
+		out = append(out, rv)
+	}
+	return out, rows.Err()
+}
+
+func severityRank(s string) int {
+	switch s {
+	case "critical":
+		return 4
+	case "high":
+		return 3
+	case "medium":
+		return 2
+	case "low":
+		return 1
+	}
+	return 0
+}
+
+// reportCerts — сертификаты открытых портов, истекающие до before
+// (уже просроченные тоже), ближайшие первыми
+func (p *Postgres) reportCerts(before time.Time) ([]ReportCert, error) {
+	rows, err := p.db.Query(`
+		SELECT host(h.ip), p.port, p.proto, COALESCE(p.tls_subject, ''), COALESCE(p.tls_issuer, ''), p.tls_not_after
+		FROM ports p
+		JOIN hosts h ON h.id = p.host_id
+		WHERE p.state = 'open' AND p.tls_not_after < $1
+		ORDER BY p.tls_not_after, h.ip, p.port
+		LIMIT $2
+	`, before, reportListLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportCert, 0)
+	for rows.Next() {
+		var rc ReportCert
+		if err := rows.Scan(&rc.IP, &rc.Port, &rc.Proto, &rc.Subject, &rc.Issuer, &rc.NotAfter); err != nil {
+			return nil, err
+		}
+		out = append(out, rc)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) reportTopServices(top int) ([]ServiceCount, error) {
+	rows, err := p.db.Query(`
+		SELECT COALESCE(NULLIF(service, ''), 'unknown') AS svc, COUNT(*)
+		FROM ports
+		WHERE state = 'open'
+		GROUP BY svc
+		ORDER BY COUNT(*) DESC, svc
+		LIMIT $1
+	`, top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ServiceCount, 0)
+	for rows.Next() {
+		var sc ServiceCount
+		if err := rows.Scan(&sc.Service, &sc.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, sc)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) reportGroups(from, to time.Time) ([]ReportGroup, error) {
+	rows, err := p.db.Query(`
+		SELECT
+			g.name,
+			COALESCE(g.owner, ''),
+			COALESCE(g.environment, ''),
+			COUNT(DISTINCT p.host_id) FILTER (WHERE p.state = 'open'),
+			COUNT(p.id) FILTER (WHERE p.state = 'open'),
+			COUNT(p.id) FILTER (WHERE p.first_seen >= $1 AND p.first_seen < $2),
+			COUNT(p.id) FILTER (WHERE p.state = 'open' AND p.compliance = 'violating')
+		FROM asset_groups g
+		LEFT JOIN host_groups hg ON hg.group_id = g.id
+		LEFT JOIN ports p ON p.host_id = hg.host_id
+		GROUP BY g.id, g.name, g.owner, g.environment
+		ORDER BY g.name
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ReportGroup, 0)
+	for rows.Next() {
+		var g ReportGroup
+		if err := rows.Scan(&g.Name, &g.Owner, &g.Environment, &g.Hosts, &g.OpenPorts, &g.NewPorts, &g.Violations); err != nil {
+			return nil, err
+		}
+		out = append(out, g)
+	}
+	return out, rows.Err()
+}
+
+/* ========================= SAVED REPORTS ========================= */
+
+const reportColumns = `id, created_at, kind, created_by, period_from, period_to, summary`
+
+func scanReport(row interface{ Scan(...any) error }, extra ...any) (*Report, error) {
+	var (
+		r   Report
+		raw []byte
+	)
+	dest := append([]any{&r.ID, &r.CreatedAt, &r.Kind, &r.CreatedBy, &r.PeriodFrom, &r.PeriodTo, &raw}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &r.Summary); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// SaveReport — сохраняет отчёт; r.ID и r.CreatedAt заполняются
+func (p *Postgres) SaveReport(r *Report, html string) error {
+	summary, err := json.Marshal(r.Summary)
+	if err != nil {
+		return err
+	}
+	return p.db.QueryRow(`
+		INSERT INTO reports (kind, created_by, period_from, period_to, summary, html)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, r.Kind, r.CreatedBy, r.PeriodFrom.UTC(), r.PeriodTo.UTC(), summary, html).Scan(&r.ID, &r.CreatedAt)
+}
+
+func (p *Postgres) ListReports(limit int) ([]Report, error) {
+	rows, err := p.db.Query(`
+		SELECT `+reportColumns+`
+		FROM reports
+		ORDER BY id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Report, 0)
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *r)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) GetReport(id int64) (*Report, string, error) {
+	var html string
+	r, err := scanReport(p.db.QueryRow(`
+		SELECT `+reportColumns+`, html
+		FROM reports
+		WHERE id = $1
+	`, id), &html)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return r, html, nil
+}
+
+// LastReportEnd — конец периода последнего отчёта (zero, если отчётов нет)
+func (p *Postgres) LastReportEnd() (time.Time, error) {
+	var t sql.NullTime
+	err := p.db.QueryRow(`SELECT MAX(period_to) FROM reports`).Scan(&t)
+	return t.Time, err
+}
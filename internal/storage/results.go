@@ -1,11 +1,24 @@
 package storage
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/L1nMay/portscanner/internal/netutil"
+	"github.com/L1nMay/portscanner/internal/policy"
+	"github.com/L1nMay/portscanner/internal/query"
+	"github.com/lib/pq"
 )
 
 // DTO для WebUI (ТОЛЬКО то, что ждёт frontend)
 type ResultRow struct {
+	ID        int64     `json:"id"` // ports.id
 	IP        string    `json:"ip"`
 	Port      int       `json:"port"`
 	Proto     string    `json:"proto"`
@@ -13,45 +26,449 @@ type ResultRow struct {
 	Banner    string    `json:"banner"`
 	FirstSeen time.Time `json:"first_seen"`
 	LastSeen  time.Time `json:"last_seen"`
+	State     string    `json:"state"` // open | closed
+
+	OSName       string `json:"os_name,omitempty"`
+	OSFamily     string `json:"os_family,omitempty"`
+	OSConfidence int    `json:"os_confidence,omitempty"`
+
+	Tags   []string `json:"tags"`
+	Groups []string `json:"groups"`
+
+	Compliance string             `json:"compliance,omitempty"` // compliant | violating
+	Violations []policy.Violation `json:"violations,omitempty"`
+}
+
+// ResultPage — страница результатов; NextCursor пустой на последней странице
+type ResultPage struct {
+	Items      []ResultRow `json:"items"`
+	Total      int         `json:"total"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+
+	// SinceCursor — при запросе с Since: с него продолжать дочитывание
+	SinceCursor string `json:"since_cursor,omitempty"`
+}
+
+// ResultFilter — фильтры для ListResults (пустое поле = без фильтра)
+type ResultFilter struct {
+	Query    string // язык запросов (internal/query): "port:3389 AND NOT tag:vpn"
+	IP       string // точный адрес хоста
+	CIDR     string // сеть, в которую входит хост
+	Ports    string // "22,80,8000-8100"
+	Proto    string // tcp | udp
+	Service  string // имя сервиса (без учёта регистра)
+	Banner   string // подстрока баннера (без учёта регистра)
+	OSFamily string // windows | linux | bsd | macos | network | unknown
+	Tag      string // эффективный тег хоста (свой, диапазона или группы)
+	Group    string // имя asset-группы
+
+	Compliance string // compliant | violating | unchecked
+	State      string // open | closed
+
+	FirstSeenFrom time.Time
+	FirstSeenTo   time.Time
+	LastSeenFrom  time.Time
+	LastSeenTo    time.Time
+
+	Sort   string // last_seen | first_seen | ip | port | service; "-" в начале — по убыванию
+	Limit  int    // 0 — DefaultResultLimit
+	Cursor string // NextCursor предыдущей страницы
+
+	// Since — SinceCursor прошлого ответа или время RFC3339: только строки,
+	// подтверждённые (last_seen) позже, по возрастанию last_seen
+	Since string
+}
+
+// ErrInvalidFilter — ошибка в параметрах фильтра/сортировки/курсора (для 400)
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// filterError — регулярное выражение из q проверяется по синтаксису RE2,
+// а исполняет его Postgres (ARE): если он отверг шаблон (SQLSTATE 2201B),
+// это ошибка фильтра, а не хранилища
+func filterError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "2201B" {
+		return fmt.Errorf("%w: %s", ErrInvalidFilter, pqErr.Message)
+	}
+	return err
+}
+
+const (
+	DefaultResultLimit = 100
+	MaxResultLimit     = 1000
+)
+
+// resultSorts — выражение сортировки и тип для приведения значения из курсора
+var resultSorts = map[string]struct{ expr, cast string }{
+	"last_seen":  {"p.last_seen", "timestamptz"},
+	"first_seen": {"p.first_seen", "timestamptz"},
+	"ip":         {"h.ip", "inet"},
+	"port":       {"p.port", "int"},
+	"service":    {"COALESCE(p.service, 'unknown')", "text"},
+}
+
+// resultCursor — keyset-курсор: значение ключа сортировки и id последней строки
+type resultCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func (c resultCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeResultCursor(s string) (resultCursor, error) {
+	var c resultCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("%w: bad cursor", ErrInvalidFilter)
+	}
+	return c, nil
+}
+
+// sortValue — значение ключа сортировки строки (для курсора)
+func (r *ResultRow) sortValue(key string) string {
+	switch key {
+	case "first_seen":
+		return r.FirstSeen.Format(time.RFC3339Nano)
+	case "ip":
+		return r.IP
+	case "port":
+		return strconv.Itoa(r.Port)
+	case "service":
+		return r.Service
+	default:
+		return r.LastSeen.Format(time.RFC3339Nano)
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// resultWhere — условия WHERE над ports p JOIN hosts h
+func resultWhere(f ResultFilter, arg func(any) string) ([]string, error) {
+	where := []string{"TRUE"}
+
+	if v := strings.TrimSpace(f.Query); v != "" {
+		cond, err := query.CompileString(v, time.Now(), arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		where = append(where, cond)
+	}
+	if v := strings.TrimSpace(f.IP); v != "" {
+		if net.ParseIP(v) == nil {
+			return nil, fmt.Errorf("%w: invalid ip %q", ErrInvalidFilter, v)
+		}
+		where = append(where, "h.ip = "+arg(v)+"::inet")
+	}
+	if v := strings.TrimSpace(f.CIDR); v != "" {
+		cidr, err := netutil.NormalizeCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		where = append(where, "h.ip <<= "+arg(cidr)+"::inet")
+	}
+	if v := strings.TrimSpace(f.Ports); v != "" {
+		ps, err := netutil.ParsePorts(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+		}
+		conds := make([]string, 0, len(ps))
+		for _, r := range ps {
+			if r.From == r.To {
+				conds = append(conds, "p.port = "+arg(r.From))
+			} else {
+				conds = append(conds, "p.port BETWEEN "+arg(r.From)+" AND "+arg(r.To))
+			}
+		}
+		if len(conds) > 0 {
+			where = append(where, "("+strings.Join(conds, " OR ")+")")
+		}
+	}
+	if v := strings.ToLower(strings.TrimSpace(f.Proto)); v != "" {
+		where = append(where, "p.proto = "+arg(v))
+	}
+	if v := strings.ToLower(strings.TrimSpace(f.Service)); v != "" {
+		where = append(where, "lower(COALESCE(p.service, 'unknown')) = "+arg(v))
+	}
+	if v := strings.TrimSpace(f.Banner); v != "" {
+		where = append(where, "p.banner ILIKE "+arg("%"+likeEscaper.Replace(v)+"%"))
+	}
+
+	if v := strings.ToLower(strings.TrimSpace(f.OSFamily)); v != "" {
+		if v == "unknown" {
+			where = append(where, "h.os_family IS NULL")
+		} else {
+			where = append(where, "h.os_family = "+arg(v))
+		}
+	}
+
+	if v := strings.TrimSpace(f.Tag); v != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM host_tags ht WHERE ht.host_id = h.id AND ht.tag = "+arg(v)+")")
+	}
+	if v := strings.TrimSpace(f.Group); v != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM host_groups hg WHERE hg.host_id = h.id AND hg.group_name = "+arg(v)+")")
+	}
+
+	switch v := strings.ToLower(strings.TrimSpace(f.Compliance)); v {
+	case "":
+	case "unchecked":
+		where = append(where, "p.compliance IS NULL")
+	default:
+		where = append(where, "p.compliance = "+arg(v))
+	}
+
+	if v := strings.ToLower(strings.TrimSpace(f.State)); v != "" {
+		where = append(where, "p.state = "+arg(v))
+	}
+
+	if !f.FirstSeenFrom.IsZero() {
+		where = append(where, "p.first_seen >= "+arg(f.FirstSeenFrom))
+	}
+	if !f.FirstSeenTo.IsZero() {
+		where = append(where, "p.first_seen < "+arg(f.FirstSeenTo))
+	}
+	if !f.LastSeenFrom.IsZero() {
+		where = append(where, "p.last_seen >= "+arg(f.LastSeenFrom))
+	}
+	if !f.LastSeenTo.IsZero() {
+		where = append(where, "p.last_seen < "+arg(f.LastSeenTo))
+	}
+
+	if v := strings.TrimSpace(f.Since); v != "" {
+		c, err := decodeSince(v)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, fmt.Sprintf("(p.last_seen, p.id) > (%s::timestamptz, %s)", arg(c.Value), arg(c.ID)))
+	}
+	return where, nil
 }
 
-func (p *Postgres) ListResults() ([]ResultRow, error) {
-	rows, err := p.db.Query(`
-		SELECT
-			h.ip::text,
-			p.port,
-			p.proto,
-			COALESCE(p.service, 'unknown'),
-			COALESCE(p.banner, ''),
-			p.first_seen,
-			p.last_seen
+const sinceSort = "since"
+
+// decodeSince — since_cursor или время RFC3339 (с него, id 0)
+func decodeSince(s string) (resultCursor, error) {
+	if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return resultCursor{Sort: sinceSort, Value: ts.Format(time.RFC3339Nano)}, nil
+	}
+	c, err := decodeResultCursor(s)
+	if err == nil && c.Sort != sinceSort {
+		err = fmt.Errorf("%w: bad since cursor", ErrInvalidFilter)
+	}
+	return c, err
+}
+
+// resultOrder — ключ сортировки, выражение и направление
+func resultOrder(f ResultFilter) (key string, sort struct{ expr, cast string }, desc bool, err error) {
+	key, desc = strings.TrimPrefix(f.Sort, "-"), strings.HasPrefix(f.Sort, "-")
+	if f.Sort == "" {
+		key, desc = "last_seen", true
+	}
+	// дочитывание изменений — только по возрастанию last_seen
+	if strings.TrimSpace(f.Since) != "" {
+		if f.Sort != "" && f.Sort != "last_seen" {
+			return "", sort, false, fmt.Errorf("%w: since requires sort last_seen", ErrInvalidFilter)
+		}
+		key, desc = "last_seen", false
+	}
+	sort, ok := resultSorts[key]
+	if !ok {
+		return "", sort, false, fmt.Errorf("%w: sort %q (last_seen|first_seen|ip|port|service)", ErrInvalidFilter, f.Sort)
+	}
+	return key, sort, desc, nil
+}
+
+const resultColumns = `
+	p.id,
+	host(h.ip),
+	p.port,
+	p.proto,
+	COALESCE(p.service, 'unknown'),
+	COALESCE(p.banner, ''),
+	p.first_seen,
+	p.last_seen,
+	p.state,
+	COALESCE(h.os_name, ''),
+	COALESCE(h.os_family, ''),
+	COALESCE(h.os_confidence, 0),
+	ARRAY(SELECT ht.tag FROM host_tags ht WHERE ht.host_id = h.id ORDER BY ht.tag),
+	ARRAY(SELECT hg.group_name FROM host_groups hg WHERE hg.host_id = h.id ORDER BY hg.group_name),
+	COALESCE(p.compliance, ''),
+	p.violations`
+
+func scanResultRow(row interface{ Scan(...any) error }) (*ResultRow, error) {
+	var r ResultRow
+	var violations []byte
+	if err := row.Scan(
+		&r.ID,
+		&r.IP,
+		&r.Port,
+		&r.Proto,
+		&r.Service,
+		&r.Banner,
+		&r.FirstSeen,
+		&r.LastSeen,
+		&r.State,
+		&r.OSName,
+		&r.OSFamily,
+		&r.OSConfidence,
+		pq.Array(&r.Tags),
+		pq.Array(&r.Groups),
+		&r.Compliance,
+		&violations,
+	); err != nil {
+		return nil, err
+	}
+	if len(violations) > 0 {
+		if err := json.Unmarshal(violations, &r.Violations); err != nil {
+			return nil, err
+		}
+	}
+	return &r, nil
+}
+
+func (p *Postgres) ListResults(f ResultFilter) (*ResultPage, error) {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	where, err := resultWhere(f, arg)
+	if err != nil {
+		return nil, err
+	}
+	sortKey, sort, desc, err := resultOrder(f)
+	if err != nil {
+		return nil, err
+	}
+	dir, cmp := "ASC", ">"
+	if desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultResultLimit
+	}
+	if limit > MaxResultLimit {
+		limit = MaxResultLimit
+	}
+
+	from := `
 		FROM ports p
 		JOIN hosts h ON h.id = p.host_id
-		ORDER BY p.last_seen DESC
-		LIMIT 1000
-	`)
+		WHERE ` + strings.Join(where, " AND ")
+
+	// total — без учёта курсора
+	page := &ResultPage{Items: make([]ResultRow, 0, limit)}
+	if err := p.db.QueryRow(`SELECT COUNT(*) `+from, args...).Scan(&page.Total); err != nil {
+		return nil, filterError(err)
+	}
+
+	if f.Cursor != "" {
+		c, err := decodeResultCursor(f.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.Sort != f.Sort {
+			return nil, fmt.Errorf("%w: cursor does not match sort %q", ErrInvalidFilter, f.Sort)
+		}
+		from += fmt.Sprintf(" AND (%s, p.id) %s (%s::%s, %s)", sort.expr, cmp, arg(c.Value), sort.cast, arg(c.ID))
+	}
+
+	rows, err := p.db.Query(`SELECT `+resultColumns+from+`
+		ORDER BY `+sort.expr+` `+dir+`, p.id `+dir+`
+		LIMIT `+arg(limit+1), args...)
 	if err != nil {
-		return nil, err
+		return nil, filterError(err)
 	}
 	defer rows.Close()
 
-	out := make([]ResultRow, 0, 128)
-
 	for rows.Next() {
-		var r ResultRow
-		if err := rows.Scan(
-			&r.IP,
-			&r.Port,
-			&r.Proto,
-			&r.Service,
-			&r.Banner,
-			&r.FirstSeen,
-			&r.LastSeen,
-		); err != nil {
+		r, err := scanResultRow(rows)
+		if err != nil {
 			return nil, err
 		}
-		out = append(out, r)
+		page.Items = append(page.Items, *r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, filterError(err)
+	}
+
+	// лишняя строка — признак следующей страницы
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+		last := &page.Items[limit-1]
+		page.NextCursor = resultCursor{Sort: f.Sort, Value: last.sortValue(sortKey), ID: last.ID}.encode()
+	}
+
+	if strings.TrimSpace(f.Since) != "" {
+		page.SinceCursor = f.Since
+		if n := len(page.Items); n > 0 {
+			last := &page.Items[n-1]
+			page.SinceCursor = resultCursor{Sort: sinceSort, Value: last.sortValue("last_seen"), ID: last.ID}.encode()
+		}
+	}
+	return page, nil
+}
+
+// EachResult — все строки фильтра по одной, без лимита и курсора
+// (выгрузка: набор не держится в памяти целиком)
+func (p *Postgres) EachResult(f ResultFilter, fn func(ResultRow) error) error {
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	where, err := resultWhere(f, arg)
+	if err != nil {
+		return err
+	}
+	_, sort, desc, err := resultOrder(f)
+	if err != nil {
+		return err
+	}
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+
+	rows, err := p.db.Query(`SELECT `+resultColumns+`
+		FROM ports p
+		JOIN hosts h ON h.id = p.host_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY `+sort.expr+` `+dir+`, p.id `+dir, args...)
+	if err != nil {
+		return filterError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanResultRow(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(*r); err != nil {
+			return err
+		}
 	}
+	return filterError(rows.Err())
+}
 
-	return out, rows.Err()
+// CheckResultFilter — проверка фильтра без запроса к БД (до начала выгрузки,
+// пока ещё можно ответить 400)
+func CheckResultFilter(f ResultFilter) error {
+	if _, err := resultWhere(f, func(any) string { return "NULL" }); err != nil {
+		return err
+	}
+	_, _, _, err := resultOrder(f)
+	return err
 }
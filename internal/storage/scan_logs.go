@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ScanLogLine — строка журнала скана
+type ScanLogLine struct {
+	ID      int64     `json:"id"`
+	Time    time.Time `json:"ts"`
+	Level   string    `json:"level"`  // INFO | WARN | ERROR
+	Source  string    `json:"source"` // progress | log | stderr
+	Message string    `json:"message"`
+}
+
+// AppendScanLog — пачка строк журнала одним INSERT
+func (p *Postgres) AppendScanLog(scanID string, lines []ScanLogLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	ts := make([]string, len(lines))
+	levels := make([]string, len(lines))
+	sources := make([]string, len(lines))
+	messages := make([]string, len(lines))
+	for i, l := range lines {
+		ts[i] = l.Time.UTC().Format(time.RFC3339Nano)
+		levels[i] = l.Level
+		sources[i] = l.Source
+		messages[i] = l.Message
+	}
+
+	_, err := p.db.Exec(`
+		INSERT INTO scan_logs (scan_id, ts, level, source, message)
+		SELECT $1, t.ts, t.level, t.source, t.message
+		FROM unnest($2::timestamptz[], $3::text[], $4::text[], $5::text[])
+			AS t(ts, level, source, message)
+	`, scanID, pq.Array(ts), pq.Array(levels), pq.Array(sources), pq.Array(messages))
+	return err
+}
+
+// ScanLog — строки журнала после afterID (0 — с начала), по порядку записи
+func (p *Postgres) ScanLog(scanID string, afterID int64, limit int) ([]ScanLogLine, error) {
+	rows, err := p.db.Query(`
+		SELECT id, ts, level, source, message
+		FROM scan_logs
+		WHERE scan_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3
+	`, scanID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ScanLogLine, 0)
+	for rows.Next() {
+		var l ScanLogLine
+		if err := rows.Scan(&l.ID, &l.Time, &l.Level, &l.Source, &l.Message); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
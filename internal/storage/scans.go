@@ -1,35 +1,69 @@
 package storage
 
-import "time"
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
 
 type ScanRun struct {
-	ID         string    `json:"id"`
-	StartedAt  time.Time `json:"started_at"`
-	FinishedAt time.Time `json:"finished_at"`
-	Engine     string    `json:"engine"`
-	Targets    string    `json:"targets"`
-	Ports      string    `json:"ports"`
-	Found      int       `json:"found"`
-	NewFound   int       `json:"new_found"`
-	Status     string    `json:"status"`
+	ID         string     `json:"id"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"` // nil — ещё идёт
+	Engine     string     `json:"engine"`
+	Targets    string     `json:"targets"`
+	Ports      string     `json:"ports"`
+	Found      int        `json:"found"`
+	NewFound   int        `json:"new_found"`
+	Status     string     `json:"status"` // running | finished | failed | cancelled
+	Source     string     `json:"source"` // scan | import
+	Error      string     `json:"error,omitempty"`
+}
+
+const scanRunColumns = `
+	id,
+	started_at,
+	finished_at,
+	COALESCE(engine, ''),
+	COALESCE(targets, ''),
+	COALESCE(ports, ''),
+	COALESCE(found, 0),
+	COALESCE(new_found, 0),
+	COALESCE(status, ''),
+	source,
+	COALESCE(error, '')`
+
+func scanScanRun(row interface{ Scan(...any) error }) (ScanRun, error) {
+	var (
+		r        ScanRun
+		finished sql.NullTime
+	)
+	err := row.Scan(
+		&r.ID,
+		&r.StartedAt,
+		&finished,
+		&r.Engine,
+		&r.Targets,
+		&r.Ports,
+		&r.Found,
+		&r.NewFound,
+		&r.Status,
+		&r.Source,
+		&r.Error,
+	)
+	if finished.Valid {
+		r.FinishedAt = &finished.Time
+	}
+	return r, err
 }
 
 func (p *Postgres) ListScanRuns(limit int) ([]ScanRun, error) {
 	rows, err := p.db.Query(`
-        SELECT
-            id,
-            started_at,
-            finished_at,
-            engine,
-            targets,
-            ports,
-            found,
-            new_found,
-            status
-        FROM scans
-        ORDER BY started_at DESC
-        LIMIT $1
-    `, limit)
+		SELECT `+scanRunColumns+`
+		FROM scans
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -37,21 +71,27 @@ func (p *Postgres) ListScanRuns(limit int) ([]ScanRun, error) {
 
 	out := make([]ScanRun, 0)
 	for rows.Next() {
-		var r ScanRun
-		if err := rows.Scan(
-			&r.ID,
-			&r.StartedAt,
-			&r.FinishedAt,
-			&r.Engine,
-			&r.Targets,
-			&r.Ports,
-			&r.Found,
-			&r.NewFound,
-			&r.Status,
-		); err != nil {
+		r, err := scanScanRun(rows)
+		if err != nil {
 			return nil, err
 		}
 		out = append(out, r)
 	}
 	return out, rows.Err()
 }
+
+// GetScanRun — один прогон по id (ErrNotFound — нет такого)
+func (p *Postgres) GetScanRun(id string) (*ScanRun, error) {
+	r, err := scanScanRun(p.db.QueryRow(`
+		SELECT `+scanRunColumns+`
+		FROM scans
+		WHERE id = $1
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -1,13 +1,16 @@
 package storage
 
+import "github.com/lib/pq"
+
 func (p *Postgres) GetStats() (*Stats, error) {
 	var s Stats
 
 	err := p.db.QueryRow(`
 		SELECT
 			(SELECT COUNT(*) FROM ports),
-			(SELECT COUNT(*) FROM hosts)
-	`).Scan(&s.TotalFindings, &s.UniqueHosts)
+			(SELECT COUNT(*) FROM hosts),
+			ARRAY(SELECT DISTINCT lower(COALESCE(service, 'unknown')) FROM ports ORDER BY 1)
+	`).Scan(&s.TotalFindings, &s.UniqueHosts, pq.Array(&s.Services))
 
 	if err != nil {
 		return nil, err
@@ -161,8 +161,9 @@ func (s *Storage) ListResults() ([]model.ScanResult, error) {
 }
 
 type Stats struct {
-	TotalFindings int `json:"total_findings"`
-	UniqueHosts   int `json:"unique_hosts"`
+	TotalFindings int      `json:"total_findings"`
+	UniqueHosts   int      `json:"unique_hosts"`
+	Services      []string `json:"services,omitempty"` // известные сервисы (для фильтра в UI)
 }
 
 func (s *Storage) GetStats() (Stats, error) {
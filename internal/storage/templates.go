@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// NotificationTemplate — пользовательский шаблон уведомления.
+// Channel — имя канала, тип канала (telegram, slack, ...) или "*" для всех.
+type NotificationTemplate struct {
+	ID        int64     `json:"id"`
+	EventType string    `json:"event_type"`
+	Channel   string    `json:"channel"`
+	Subject   string    `json:"subject"` // пусто — тема по умолчанию
+	Body      string    `json:"body"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const templateColumns = `id, event_type, channel, subject, body, updated_at`
+
+func scanTemplate(row interface{ Scan(...any) error }) (*NotificationTemplate, error) {
+	var t NotificationTemplate
+	if err := row.Scan(&t.ID, &t.EventType, &t.Channel, &t.Subject, &t.Body, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (p *Postgres) ListNotificationTemplates() ([]NotificationTemplate, error) {
+	rows, err := p.db.Query(`SELECT ` + templateColumns + ` FROM notification_templates ORDER BY event_type, channel`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]NotificationTemplate, 0)
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *t)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) GetNotificationTemplate(id int64) (*NotificationTemplate, error) {
+	t, err := scanTemplate(p.db.QueryRow(`SELECT `+templateColumns+` FROM notification_templates WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// SaveNotificationTemplate — создаёт или заменяет шаблон для пары (event_type, channel)
+func (p *Postgres) SaveNotificationTemplate(t *NotificationTemplate) error {
+	return p.db.QueryRow(`
+		INSERT INTO notification_templates (event_type, channel, subject, body, updated_at)
+		VALUES ($1, $2, $3, $4, now())
+		ON CONFLICT (event_type, channel) DO UPDATE SET
+			subject = EXCLUDED.subject,
+			body = EXCLUDED.body,
+			updated_at = now()
+		RETURNING id, updated_at
+	`, t.EventType, t.Channel, t.Subject, t.Body).Scan(&t.ID, &t.UpdatedAt)
+}
+
+func (p *Postgres) UpdateNotificationTemplate(t *NotificationTemplate) error {
+	err := p.db.QueryRow(`
+		UPDATE notification_templates
+		SET event_type = $2, channel = $3, subject = $4, body = $5, updated_at = now()
+		WHERE id = $1
+		RETURNING updated_at
+	`, t.ID, t.EventType, t.Channel, t.Subject, t.Body).Scan(&t.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (p *Postgres) DeleteNotificationTemplate(id int64) error {
+	res, err := p.db.Exec(`DELETE FROM notification_templates WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
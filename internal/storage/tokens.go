@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIToken — токен автоматизации; сам секрет не хранится и не отдаётся
+type APIToken struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	Username     string     `json:"username"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP   string     `json:"last_used_ip,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+}
+
+// TokenFilter — фильтр списка токенов
+type TokenFilter struct {
+	UserID      int64         // 0 — все пользователи
+	UnusedFor   time.Duration // >0 — только не использованные за этот период
+	WithRevoked bool
+}
+
+const tokenColumns = `t.id, t.user_id, u.username, t.name, t.prefix, t.scopes, t.allowed_cidrs,
+	t.expires_at, t.created_at, t.last_used_at, COALESCE(t.last_used_ip, ''), t.revoked_at`
+
+func scanToken(row interface{ Scan(...any) error }) (*APIToken, error) {
+	var t APIToken
+	if err := row.Scan(&t.ID, &t.UserID, &t.Username, &t.Name, &t.Prefix,
+		pq.Array(&t.Scopes), pq.Array(&t.AllowedCIDRs),
+		&t.ExpiresAt, &t.CreatedAt, &t.LastUsedAt, &t.LastUsedIP, &t.RevokedAt); err != nil {
+		return nil, err
+	}
+	if t.Scopes == nil {
+		t.Scopes = []string{}
+	}
+	if t.AllowedCIDRs == nil {
+		t.AllowedCIDRs = []string{}
+	}
+	return &t, nil
+}
+
+func (p *Postgres) ListTokens(f TokenFilter) ([]APIToken, error) {
+	rows, err := p.db.Query(`
+		SELECT `+tokenColumns+`
+		FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE ($1 = 0 OR t.user_id = $1)
+		  AND ($2 OR t.revoked_at IS NULL)
+		  AND ($3 = 0 OR COALESCE(t.last_used_at, t.created_at) < now() - $3 * interval '1 second')
+		ORDER BY t.created_at DESC
+	`, f.UserID, f.WithRevoked, int64(f.UnusedFor.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]APIToken, 0)
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *t)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) GetToken(id int64) (*APIToken, error) {
+	t, err := scanToken(p.db.QueryRow(`
+		SELECT `+tokenColumns+`
+		FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.id = $1
+	`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return t, err
+}
+
+// CreateToken — hash считается вызывающим (auth.HashToken)
+func (p *Postgres) CreateToken(t *APIToken, hash string) error {
+	return p.db.QueryRow(`
+		INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, allowed_cidrs, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, (SELECT username FROM users WHERE id = $1)
+	`, t.UserID, t.Name, hash, t.Prefix, pq.Array(t.Scopes), pq.Array(t.AllowedCIDRs), t.ExpiresAt).
+		Scan(&t.ID, &t.CreatedAt, &t.Username)
+}
+
+// RevokeToken — запись остаётся для истории, токен перестаёт работать
+func (p *Postgres) RevokeToken(id int64) error {
+	res, err := p.db.Exec(`UPDATE api_tokens SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UseToken — действующий токен по hash вместе с владельцем;
+// отмечает last_used. Отозванные, просроченные и токены
+// заблокированных пользователей не находятся.
+func (p *Postgres) UseToken(hash, ip string) (*APIToken, *User, error) {
+	var (
+		t APIToken
+		u User
+	)
+	err := p.db.QueryRow(`
+		UPDATE api_tokens t
+		SET last_used_at = now(), last_used_ip = $2
+		FROM users u
+		WHERE t.token_hash = $1
+		  AND u.id = t.user_id
+		  AND t.revoked_at IS NULL
+		  AND (t.expires_at IS NULL OR t.expires_at > now())
+		  AND NOT u.disabled
+		RETURNING t.id, t.user_id, u.username, t.name, t.prefix, t.scopes, t.allowed_cidrs,
+			t.expires_at, t.created_at, t.last_used_at, COALESCE(t.last_used_ip, ''), t.revoked_at,
+			u.role
+	`, hash, ip).Scan(&t.ID, &t.UserID, &t.Username, &t.Name, &t.Prefix,
+		pq.Array(&t.Scopes), pq.Array(&t.AllowedCIDRs),
+		&t.ExpiresAt, &t.CreatedAt, &t.LastUsedAt, &t.LastUsedIP, &t.RevokedAt, &u.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	u.ID = t.UserID
+	u.Username = t.Username
+	return &t, &u, nil
+}
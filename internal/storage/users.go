@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type User struct {
+	ID           int64      `json:"id"`
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"-"`
+	Role         string     `json:"role"`
+	Disabled     bool       `json:"disabled"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+}
+
+// Session — сессия web UI вместе с владельцем
+type Session struct {
+	ID        string
+	CSRFToken string
+	ExpiresAt time.Time
+	User      User
+}
+
+const userColumns = `id, username, password_hash, role, disabled, created_at, updated_at, last_login_at`
+
+func scanUser(row interface{ Scan(...any) error }) (*User, error) {
+	var u User
+	if err := row.Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Role, &u.Disabled,
+		&u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (p *Postgres) CountUsers() (int, error) {
+	var n int
+	err := p.db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&n)
+	return n, err
+}
+
+func (p *Postgres) ListUsers() ([]User, error) {
+	rows, err := p.db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *u)
+	}
+	return out, rows.Err()
+}
+
+func (p *Postgres) GetUser(id int64) (*User, error) {
+	u, err := scanUser(p.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return u, err
+}
+
+func (p *Postgres) GetUserByName(username string) (*User, error) {
+	u, err := scanUser(p.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = $1`, username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return u, err
+}
+
+func (p *Postgres) CreateUser(u *User) error {
+	return p.db.QueryRow(`
+		INSERT INTO users (username, password_hash, role, disabled)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`, u.Username, u.PasswordHash, u.Role, u.Disabled).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
+}
+
+// UpdateUser — роль/disabled; пароль меняется, только если PasswordHash не пустой.
+// Смена пароля, роли или блокировка завершают все сессии пользователя.
+func (p *Postgres) UpdateUser(u *User) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		UPDATE users
+		SET role = $2,
+		    disabled = $3,
+		    password_hash = COALESCE(NULLIF($4, ''), password_hash),
+		    updated_at = now()
+		WHERE id = $1
+		RETURNING username, created_at, updated_at, last_login_at
+	`, u.ID, u.Role, u.Disabled, u.PasswordHash).Scan(&u.Username, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = $1`, u.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) DeleteUser(id int64) error {
+	res, err := p.db.Exec(`DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CountActiveAdmins — чтобы нельзя было удалить/понизить последнего админа
+func (p *Postgres) CountActiveAdmins() (int, error) {
+	var n int
+	err := p.db.QueryRow(`SELECT COUNT(*) FROM users WHERE role = 'admin' AND NOT disabled`).Scan(&n)
+	return n, err
+}
+
+// CreateSession — id и csrf уже сгенерированы (id = hash секрета из cookie)
+func (p *Postgres) CreateSession(id string, userID int64, csrf string, ttl time.Duration, ip, userAgent string) error {
+	_, err := p.db.Exec(`
+		INSERT INTO sessions (id, user_id, csrf_token, expires_at, ip, user_agent)
+		VALUES ($1, $2, $3, now() + $4 * interval '1 second', $5, $6)
+	`, id, userID, csrf, int(ttl.Seconds()), ip, userAgent)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.Exec(`UPDATE users SET last_login_at = now() WHERE id = $1`, userID)
+	return err
+}
+
+// GetSession — живая сессия активного пользователя; заодно обновляет last_seen_at
+func (p *Postgres) GetSession(id string) (*Session, error) {
+	var s Session
+	err := p.db.QueryRow(`
+		UPDATE sessions s
+		SET last_seen_at = now()
+		FROM users u
+		WHERE s.id = $1
+		  AND u.id = s.user_id
+		  AND s.expires_at > now()
+		  AND NOT u.disabled
+		RETURNING s.id, s.csrf_token, s.expires_at,
+			u.id, u.username, u.password_hash, u.role, u.disabled, u.created_at, u.updated_at, u.last_login_at
+	`, id).Scan(&s.ID, &s.CSRFToken, &s.ExpiresAt,
+		&s.User.ID, &s.User.Username, &s.User.PasswordHash, &s.User.Role, &s.User.Disabled,
+		&s.User.CreatedAt, &s.User.UpdatedAt, &s.User.LastLoginAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (p *Postgres) DeleteSession(id string) error {
+	_, err := p.db.Exec(`DELETE FROM sessions WHERE id = $1`, id)
+	return err
+}
+
+// DeleteExpiredSessions — чистка, вызывается при логине
+func (p *Postgres) DeleteExpiredSessions() error {
+	_, err := p.db.Exec(`DELETE FROM sessions WHERE expires_at <= now()`)
+	return err
+}
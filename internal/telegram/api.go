@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// API — минимальный клиент Bot API (getUpdates / sendMessage).
+// Базовый URL настраивается, чтобы бота можно было гонять против локальной заглушки.
+type API struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+func NewAPI(baseURL, token string, pollTimeout time.Duration) *API {
+	base := strings.TrimRight(baseURL, "/")
+	if base == "" {
+		base = "https://api.telegram.org"
+	}
+	return &API{
+		base:  base,
+		token: token,
+		// long polling: запрос висит до pollTimeout, даём запас
+		client: &http.Client{Timeout: pollTimeout + 10*time.Second},
+	}
+}
+
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	Text      string `json:"text"`
+	Chat      Chat   `json:"chat"`
+	From      *User  `json:"from"`
+}
+
+type Chat struct {
+	ID int64 `json:"id"`
+}
+
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+}
+
+func (a *API) call(ctx context.Context, method string, req any, out any) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	hr, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/bot%s/%s", a.base, a.token, method), bytes.NewReader(b))
+	if err != nil {
+		return redact(method, err)
+	}
+	hr.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(hr)
+	if err != nil {
+		return redact(method, err)
+	}
+	defer resp.Body.Close()
+
+	var ar apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&ar); err != nil {
+		return fmt.Errorf("telegram %s: http %d: %w", method, resp.StatusCode, err)
+	}
+	if !ar.OK {
+		return fmt.Errorf("telegram %s: %s", method, ar.Description)
+	}
+	if out != nil {
+		return json.Unmarshal(ar.Result, out)
+	}
+	return nil
+}
+
+// redact — сетевая ошибка без URL запроса: в нём токен бота, а ошибка
+// уходит в лог
+func redact(method string, err error) error {
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err
+	}
+	return fmt.Errorf("telegram %s: %w", method, err)
+}
+
+// GetUpdates — long polling; offset = последний update_id + 1
+func (a *API) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	var out []Update
+	err := a.call(ctx, "getUpdates", map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message"},
+	}, &out)
+	return out, err
+}
+
+// SendMessage — ответ в чат (MarkdownV2, текст должен быть экранирован)
+func (a *API) SendMessage(ctx context.Context, chatID int64, text string) error {
+	return a.call(ctx, "sendMessage", map[string]any{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"parse_mode":               "MarkdownV2",
+		"disable_web_page_preview": true,
+	}, nil)
+}
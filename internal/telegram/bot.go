@@ -0,0 +1,481 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/alerting"
+	"github.com/L1nMay/portscanner/internal/config"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/model"
+	"github.com/L1nMay/portscanner/internal/netutil"
+	"github.com/L1nMay/portscanner/internal/notifier"
+	"github.com/L1nMay/portscanner/internal/scan"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+var esc = notifier.EscapeMarkdownV2
+
+const helpText = `/scan <target> [ports] — start a scan
+/status — current scan progress
+/cancel — stop the running scan
+/last — latest scan summary
+/host <ip> — known ports of a host
+/mute <rule> <duration> — silence an alert rule (30m, 2h, 1d; 0 — unmute)`
+
+// store — то, что боту нужно от хранилища (*storage.Postgres)
+type store interface {
+	AddAudit(e *storage.AuditEntry) error
+	SetAlertMute(rule string, until time.Time) error
+	ListResults(f storage.ResultFilter) (*storage.ResultPage, error)
+	ListScanRuns(limit int) ([]storage.ScanRun, error)
+	BuildDigest(from, to time.Time, top int) (*storage.Digest, error)
+}
+
+// pollLock — getUpdates опрашивает только держатель (storage.SessionLock)
+type pollLock interface {
+	Alive(ctx context.Context) bool
+	Release()
+}
+
+// Bot — интерактивные команды из авторизованных чатов
+type Bot struct {
+	api     *API
+	cfg     *config.Config
+	pg      store
+	tryLock func(ctx context.Context) (pollLock, error)
+	runner  *scan.Runner
+	engine  *alerting.Engine
+
+	allowed map[int64]struct{}
+	timeout time.Duration
+	retry   time.Duration // пауза после ошибки опроса и между попытками взять lock
+}
+
+func NewBot(cfg *config.Config, pg *storage.Postgres, runner *scan.Runner, engine *alerting.Engine) (*Bot, error) {
+	b, err := newBot(cfg, pg, runner, engine)
+	if err != nil {
+		return nil, err
+	}
+	b.tryLock = func(ctx context.Context) (pollLock, error) {
+		lock, err := pg.TryTelegramPollLock(ctx)
+		if lock == nil {
+			return nil, err
+		}
+		return lock, nil
+	}
+	return b, nil
+}
+
+func newBot(cfg *config.Config, pg store, runner *scan.Runner, engine *alerting.Engine) (*Bot, error) {
+	tc := cfg.Telegram
+	if tc.BotToken == "" {
+		return nil, fmt.Errorf("telegram.bot_token is required for commands")
+	}
+
+	allowed := map[int64]struct{}{}
+	for _, id := range tc.AuthorizedChats {
+		allowed[id] = struct{}{}
+	}
+	if len(allowed) == 0 {
+		id, err := strconv.ParseInt(strings.TrimSpace(tc.ChatID), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("telegram: set authorized_chats or a numeric chat_id")
+		}
+		allowed[id] = struct{}{}
+	}
+
+	timeout := time.Duration(tc.PollTimeoutSec) * time.Second
+	return &Bot{
+		api:     NewAPI(tc.APIURL, tc.BotToken, timeout),
+		cfg:     cfg,
+		pg:      pg,
+		runner:  runner,
+		engine:  engine,
+		allowed: allowed,
+		timeout: timeout,
+		retry:   5 * time.Second,
+	}, nil
+}
+
+// Run — long polling getUpdates. Экземпляров webui может быть несколько,
+// а Bot API отдаёт обновления только одному опрашивающему: опрашивает
+// держатель lock в Postgres, остальные стоят в резерве.
+func (b *Bot) Run(ctx context.Context) {
+	logger.Infof("telegram bot started (%d authorized chats)", len(b.allowed))
+
+	lock := b.waitLock(ctx)
+	if lock == nil {
+		return
+	}
+	defer func() { lock.Release() }()
+
+	var offset int64
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		if !lock.Alive(ctx) {
+			logger.Errorf("telegram: lost polling lock, standing by")
+			lock.Release()
+			if lock = b.waitLock(ctx); lock == nil {
+				return
+			}
+		}
+
+		updates, err := b.api.GetUpdates(ctx, offset, b.timeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Errorf("%v", err)
+			if !sleep(ctx, b.retry) {
+				return
+			}
+			continue
+		}
+
+		for _, u := range updates {
+			offset = u.UpdateID + 1
+			if u.Message != nil {
+				b.handle(ctx, u.Message)
+			}
+		}
+	}
+}
+
+// waitLock — ждёт lock опроса; nil — ctx отменён
+func (b *Bot) waitLock(ctx context.Context) pollLock {
+	standby := false
+	for {
+		lock, err := b.tryLock(ctx)
+		switch {
+		case err != nil:
+			if ctx.Err() == nil {
+				logger.Errorf("telegram: polling lock: %v", err)
+			}
+		case lock != nil:
+			if standby {
+				logger.Infof("telegram: polling lock acquired")
+			}
+			return lock
+		case !standby:
+			logger.Infof("telegram: another instance polls updates, standing by")
+			standby = true
+		}
+		if !sleep(ctx, b.retry) {
+			return nil
+		}
+	}
+}
+
+// sleep — пауза d; false, если ctx отменён раньше
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func (b *Bot) handle(ctx context.Context, m *Message) {
+	if _, ok := b.allowed[m.Chat.ID]; !ok {
+		logger.Infof("telegram: ignored message from unauthorized chat %d", m.Chat.ID)
+		return
+	}
+
+	cmd, args := parseCommand(m.Text)
+	if cmd == "" {
+		return
+	}
+
+	var reply string
+	switch cmd {
+	case "start", "help":
+		reply = esc(helpText)
+	case "scan":
+		var err error
+		reply, err = b.cmdScan(m.Chat.ID, args)
+		b.audit(m, "scan.start", args, err)
+	case "status":
+		reply = b.cmdStatus()
+	case "cancel":
+		var err error
+		reply, err = b.cmdCancel()
+		if !errors.Is(err, errNotRunning) {
+			b.audit(m, "scan.cancel", args, err)
+		}
+	case "last":
+		reply = b.cmdLast()
+	case "host":
+		reply = b.cmdHost(args)
+	case "mute":
+		var err error
+		reply, err = b.cmdMute(args)
+		b.audit(m, "alert_rule.mute", args, err)
+	default:
+		reply = esc("Unknown command. /help")
+	}
+
+	b.reply(ctx, m.Chat.ID, reply)
+}
+
+// audit — команды, меняющие состояние, пишутся в audit_log как и действия из web UI
+func (b *Bot) audit(m *Message, action string, args []string, err error) {
+	actor := fmt.Sprintf("chat:%d", m.Chat.ID)
+	if m.From != nil && m.From.Username != "" {
+		actor = m.From.Username
+	}
+	e := &storage.AuditEntry{
+		Actor:     actor,
+		ActorType: "telegram",
+		Action:    action,
+		Params:    map[string]any{"args": args, "chat_id": m.Chat.ID},
+		Outcome:   "success",
+	}
+	if err != nil {
+		e.Outcome = "failure"
+		e.Error = err.Error()
+	}
+	if err := b.pg.AddAudit(e); err != nil {
+		logger.Errorf("telegram audit: %v", err)
+	}
+}
+
+func (b *Bot) reply(ctx context.Context, chatID int64, text string) {
+	if err := b.api.SendMessage(ctx, chatID, text); err != nil {
+		logger.Errorf("telegram reply: %v", err)
+	}
+}
+
+// parseCommand — "/scan@my_bot 10.0.0.0/24 22,80" -> "scan", ["10.0.0.0/24", "22,80"]
+func parseCommand(text string) (string, []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", nil
+	}
+	cmd := strings.TrimPrefix(fields[0], "/")
+	if i := strings.Index(cmd, "@"); i >= 0 {
+		cmd = cmd[:i]
+	}
+	return strings.ToLower(cmd), fields[1:]
+}
+
+// cmdScan — ошибка означает, что скан не запущен (для audit)
+func (b *Bot) cmdScan(chatID int64, args []string) (string, error) {
+	if len(args) == 0 {
+		return esc("Usage: /scan <target> [ports]"), errors.New("no target")
+	}
+	if b.runner.IsRunning() {
+		return esc("A scan is already running. /status"), errors.New("scan already running")
+	}
+
+	targets, err := b.runner.ExpandTargets(strings.Split(args[0], ","))
+	if err != nil {
+		return esc("Error: " + err.Error()), err
+	}
+	if err := scan.ValidateTargets(targets); err != nil {
+		return esc("Rejected: " + err.Error()), err
+	}
+
+	cfg := *b.cfg
+	cfg.Targets = targets
+	cfg.UserDefined = true
+	if len(args) > 1 {
+		ports := args[1]
+		if ports != "auto" && ports != "top" {
+			if _, err := netutil.ParsePorts(ports); err != nil {
+				return esc("Error: " + err.Error()), err
+			}
+		}
+		cfg.Ports = ports
+	}
+
+	// итог скана отправим в тот же чат по событию done
+	id := b.runner.RunAsync(&cfg)
+	go b.waitDone(chatID, id)
+	return esc(fmt.Sprintf("Scan started: %s (ports: %s)", strings.Join(targets, ", "), cfg.Ports)), nil
+}
+
+var errNotRunning = errors.New("no scan is running")
+
+// cmdCancel — отмена только скана, который чат мог бы запустить сам
+// (/scan проверяет цели ValidateTargets); автоопределённые цели — это
+// локальные сети, их ValidateTargets пропустил бы всегда
+func (b *Bot) cmdCancel() (string, error) {
+	id, targets, running := b.runner.Running()
+	if !running {
+		return esc("No scan is running."), errNotRunning
+	}
+	if len(targets) > 0 {
+		expanded, err := b.runner.ExpandTargets(targets)
+		if err != nil {
+			return esc("Error: " + err.Error()), err
+		}
+		if err := scan.ValidateTargets(expanded); err != nil {
+			return esc("Rejected: the running scan targets networks this chat cannot scan."), err
+		}
+	}
+	if !b.runner.CancelScan(id) {
+		return esc("No scan is running."), errNotRunning
+	}
+	return esc("Scan cancelled."), nil
+}
+
+func (b *Bot) waitDone(chatID int64, scanID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 12*time.Hour)
+	d, err := b.runner.WaitDone(ctx, scanID)
+	cancel()
+	if err != nil {
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	b.reply(ctx, chatID, esc(d.Message)+"\n"+esc("/last for the summary"))
+}
+
+func (b *Bot) cmdStatus() string {
+	p := b.runner.LastProgress()
+	if !b.runner.IsRunning() {
+		if p.Message == "" {
+			return esc("Idle.")
+		}
+		return esc("Idle. Last: " + p.Message)
+	}
+	return esc(fmt.Sprintf("Running: %d%% — %s", p.Percent, p.Message))
+}
+
+func (b *Bot) cmdLast() string {
+	runs, err := b.pg.ListScanRuns(20)
+	if err != nil {
+		return esc("Error: " + err.Error())
+	}
+	// последний завершённый: идущий или упавший скан сводки не даёт
+	var run *storage.ScanRun
+	for i := range runs {
+		if runs[i].Status == model.ScanFinished && runs[i].FinishedAt != nil {
+			run = &runs[i]
+			break
+		}
+	}
+	if run == nil {
+		return esc("No scans yet.")
+	}
+	finished := *run.FinishedAt
+
+	d, err := b.pg.BuildDigest(run.StartedAt, finished.Add(time.Second), 5)
+	if err != nil {
+		return esc("Error: " + err.Error())
+	}
+	d.Kind = "scan"
+	d.ScanID = run.ID
+	d.Engine = run.Engine
+	d.Targets = run.Targets
+
+	payload, err := d.Payload()
+	if err != nil {
+		return esc("Error: " + err.Error())
+	}
+	ev := storage.Event{Type: "scan_digest", Payload: payload, CreatedAt: finished}
+
+	tpl, _ := notifier.NewTemplates().Lookup("scan_digest", "", "telegram")
+	msg, err := notifier.RenderTemplate(tpl, ev, notifier.FormatMarkdownV2)
+	if err != nil {
+		return esc("Error: " + err.Error())
+	}
+	return msg.Text
+}
+
+func (b *Bot) cmdHost(args []string) string {
+	if len(args) != 1 {
+		return esc("Usage: /host <ip>")
+	}
+	ip := args[0]
+	if _, err := netutil.NormalizeCIDR(ip); err != nil || strings.Contains(ip, "/") {
+		return esc("Invalid IP: " + ip)
+	}
+
+	page, err := b.pg.ListResults(storage.ResultFilter{IP: ip, State: "open", Sort: "port", Limit: storage.MaxResultLimit})
+	if err != nil {
+		return esc("Error: " + err.Error())
+	}
+	rows := page.Items
+	if len(rows) == 0 {
+		return esc("No open ports known for " + ip)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("*" + esc(ip) + "*")
+	if os := rows[0].OSName; os != "" {
+		sb.WriteString(" " + esc("("+os+")"))
+	}
+	if tags := rows[0].Tags; len(tags) > 0 {
+		sb.WriteString("\n" + esc("Tags: "+strings.Join(tags, ", ")))
+	}
+	for _, r := range rows {
+		line := fmt.Sprintf("• %d/%s %s", r.Port, r.Proto, r.Service)
+		if r.Compliance == "violating" {
+			line += " ⚠️"
+		}
+		sb.WriteString("\n" + esc(line))
+	}
+	return sb.String()
+}
+
+func (b *Bot) cmdMute(args []string) (string, error) {
+	if len(args) != 2 {
+		return esc("Usage: /mute <rule> <duration>"), errors.New("usage")
+	}
+	rule := args[0]
+
+	known := false
+	for _, r := range b.engine.Rules() {
+		if r.Name == rule {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return esc("Unknown rule: " + rule), errors.New("unknown rule " + rule)
+	}
+
+	d, err := parseDuration(args[1])
+	if err != nil {
+		return esc("Invalid duration: " + args[1]), err
+	}
+
+	until := time.Now().Add(d)
+	if err := b.pg.SetAlertMute(rule, until); err != nil {
+		return esc("Failed to save mute."), err
+	}
+	b.engine.Mute(rule, until)
+	if d == 0 {
+		return esc("Rule " + rule + " unmuted."), nil
+	}
+	return esc(fmt.Sprintf("Rule %s muted until %s.", rule, until.Format("2006-01-02 15:04"))), nil
+}
+
+// parseDuration — time.ParseDuration + дни ("1d", "7d")
+func parseDuration(s string) (time.Duration, error) {
+	if s == "0" {
+		return 0, nil
+	}
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid duration")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration")
+	}
+	return d, nil
+}
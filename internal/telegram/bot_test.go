@@ -0,0 +1,261 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/alerting"
+	"github.com/L1nMay/portscanner/internal/config"
+	"github.com/L1nMay/portscanner/internal/scan"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+const testToken = "123456:secret"
+
+// fakeAPI — заглушка Bot API: отдаёт очередь updates и запоминает ответы
+type fakeAPI struct {
+	mu      sync.Mutex
+	updates []Update
+	sent    []sentMessage
+	polls   atomic.Int32
+	replied chan struct{}
+}
+
+type sentMessage struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+func newFakeAPI(t *testing.T, updates []Update) (*fakeAPI, *httptest.Server) {
+	f := &fakeAPI{updates: updates, replied: make(chan struct{}, 100)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, ok := strings.CutPrefix(r.URL.Path, "/bot"+testToken+"/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		switch method {
+		case "getUpdates":
+			f.polls.Add(1)
+			var req struct {
+				Offset int64 `json:"offset"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			f.mu.Lock()
+			out := []Update{}
+			for _, u := range f.updates {
+				if u.UpdateID >= req.Offset {
+					out = append(out, u)
+				}
+			}
+			f.mu.Unlock()
+			if len(out) == 0 {
+				time.Sleep(20 * time.Millisecond)
+			}
+			writeOK(w, out)
+		case "sendMessage":
+			var m sentMessage
+			_ = json.NewDecoder(r.Body).Decode(&m)
+			f.mu.Lock()
+			f.sent = append(f.sent, m)
+			f.mu.Unlock()
+			writeOK(w, map[string]any{"message_id": 1})
+			f.replied <- struct{}{}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func writeOK(w http.ResponseWriter, result any) {
+	raw, _ := json.Marshal(result)
+	_ = json.NewEncoder(w).Encode(apiResponse{OK: true, Result: raw})
+}
+
+func (f *fakeAPI) waitReplies(t *testing.T, n int) []sentMessage {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-f.replied:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d replies, want %d", i, n)
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]sentMessage(nil), f.sent...)
+}
+
+// fakeStore — хранилище в памяти вместо Postgres
+type fakeStore struct {
+	mu      sync.Mutex
+	audit   []storage.AuditEntry
+	mutes   map[string]time.Time
+	results map[string][]storage.ResultRow
+}
+
+func (s *fakeStore) AddAudit(e *storage.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audit = append(s.audit, *e)
+	return nil
+}
+
+func (s *fakeStore) SetAlertMute(rule string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mutes[rule] = until
+	return nil
+}
+
+func (s *fakeStore) ListResults(f storage.ResultFilter) (*storage.ResultPage, error) {
+	rows := s.results[f.IP]
+	return &storage.ResultPage{Items: rows, Total: len(rows)}, nil
+}
+
+func (s *fakeStore) ListScanRuns(int) ([]storage.ScanRun, error) { return nil, nil }
+
+func (s *fakeStore) BuildDigest(time.Time, time.Time, int) (*storage.Digest, error) {
+	return &storage.Digest{}, nil
+}
+
+type fakeLock struct{ released atomic.Bool }
+
+func (l *fakeLock) Alive(context.Context) bool { return !l.released.Load() }
+func (l *fakeLock) Release()                   { l.released.Store(true) }
+
+func newTestBot(t *testing.T, apiURL string, st *fakeStore) *Bot {
+	t.Helper()
+	cfg := &config.Config{}
+	cfg.Telegram = config.TelegramConfig{
+		BotToken:        testToken,
+		APIURL:          apiURL,
+		Commands:        true,
+		AuthorizedChats: []int64{100},
+		PollTimeoutSec:  1,
+	}
+	engine := alerting.NewEngine(nil)
+	engine.SetRules([]alerting.Rule{{Name: "noisy", Enabled: true}})
+
+	b, err := newBot(cfg, st, scan.NewRunner(cfg, nil), engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.retry = 10 * time.Millisecond
+	b.tryLock = func(context.Context) (pollLock, error) { return &fakeLock{}, nil }
+	return b
+}
+
+func message(id, chat int64, text string) Update {
+	return Update{UpdateID: id, Message: &Message{
+		MessageID: id,
+		Text:      text,
+		Chat:      Chat{ID: chat},
+		From:      &User{ID: chat, Username: "analyst"},
+	}}
+}
+
+func TestBotCommands(t *testing.T) {
+	api, srv := newFakeAPI(t, []Update{
+		message(1, 999, "/help"), // чужой чат — без ответа
+		message(2, 100, "/help"),
+		message(3, 100, "/scan 8.8.8.8"),
+		message(4, 100, "/mute noisy 2h"),
+		message(5, 100, "/host 10.0.0.5"),
+	})
+	st := &fakeStore{
+		mutes: map[string]time.Time{},
+		results: map[string][]storage.ResultRow{
+			"10.0.0.5": {
+				{IP: "10.0.0.5", Port: 22, Proto: "tcp", Service: "ssh", OSName: "Linux"},
+				{IP: "10.0.0.5", Port: 23, Proto: "tcp", Service: "telnet", Compliance: "violating"},
+			},
+		},
+	}
+	b := newTestBot(t, srv.URL, st)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { b.Run(ctx); close(done) }()
+	sent := api.waitReplies(t, 4)
+	cancel()
+	<-done
+
+	for _, m := range sent {
+		if m.ChatID != 100 {
+			t.Fatalf("reply to unauthorized chat %d: %q", m.ChatID, m.Text)
+		}
+	}
+	if !strings.Contains(sent[0].Text, "/scan") {
+		t.Errorf("/help reply = %q", sent[0].Text)
+	}
+	if !strings.HasPrefix(sent[1].Text, "Rejected") {
+		t.Errorf("/scan of a public address: reply = %q, want rejection", sent[1].Text)
+	}
+	if !strings.Contains(sent[2].Text, "muted until") {
+		t.Errorf("/mute reply = %q", sent[2].Text)
+	}
+	if !strings.Contains(sent[3].Text, "22/tcp ssh") || !strings.Contains(sent[3].Text, "23/tcp telnet ⚠️") {
+		t.Errorf("/host reply = %q", sent[3].Text)
+	}
+
+	until, ok := st.mutes["noisy"]
+	if !ok || time.Until(until) < 119*time.Minute || time.Until(until) > 2*time.Hour {
+		t.Errorf("mute not persisted: %v %v", until, ok)
+	}
+
+	// /scan и /mute меняют состояние — в audit_log, /help и /host — нет
+	if len(st.audit) != 2 {
+		t.Fatalf("audit entries = %+v", st.audit)
+	}
+	if a := st.audit[0]; a.Action != "scan.start" || a.Outcome != "failure" || a.Actor != "analyst" {
+		t.Errorf("scan audit = %+v", a)
+	}
+	if a := st.audit[1]; a.Action != "alert_rule.mute" || a.Outcome != "success" {
+		t.Errorf("mute audit = %+v", a)
+	}
+}
+
+func TestBotPollsOnlyWithLock(t *testing.T) {
+	api, srv := newFakeAPI(t, []Update{message(1, 100, "/help")})
+	b := newTestBot(t, srv.URL, &fakeStore{mutes: map[string]time.Time{}})
+
+	var free atomic.Bool
+	tries := make(chan struct{}, 100)
+	lock := &fakeLock{}
+	b.tryLock = func(context.Context) (pollLock, error) {
+		tries <- struct{}{}
+		if !free.Load() {
+			return nil, nil // держит другой экземпляр
+		}
+		return lock, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { b.Run(ctx); close(done) }()
+
+	for i := 0; i < 3; i++ {
+		<-tries
+	}
+	if n := api.polls.Load(); n != 0 {
+		t.Fatalf("getUpdates called %d times without the lock", n)
+	}
+
+	free.Store(true)
+	api.waitReplies(t, 1)
+	cancel()
+	<-done
+	if !lock.released.Load() {
+		t.Error("lock not released on shutdown")
+	}
+}
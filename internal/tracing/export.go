@@ -0,0 +1,440 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+)
+
+// Config — секция tracing в config.yaml
+type Config struct {
+	Enabled bool `yaml:"enabled"`
+	// Exporter — otlp (OTLP/HTTP JSON, по умолчанию) или stdout
+	Exporter string `yaml:"exporter"`
+	// Endpoint — коллектор OTLP/HTTP; спаны уходят POST на <endpoint>/v1/traces
+	Endpoint    string            `yaml:"endpoint"`
+	Headers     map[string]string `yaml:"headers"`
+	ServiceName string            `yaml:"service_name"`
+	// SampleRatio — доля корневых трасс (0 или не задано — все)
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+const (
+	defaultEndpoint = "http://localhost:4318"
+	defaultService  = "portscanner"
+
+	queueSize     = 4096
+	batchSize     = 512
+	flushInterval = 2 * time.Second
+	exportTimeout = 10 * time.Second
+)
+
+// Exporter — получатель пачек завершённых спанов
+type Exporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+}
+
+// Setup — включает трассировку по конфигу; shutdown дописывает очередь
+// (при выключенной трассировке — no-op)
+func Setup(cfg Config) (shutdown func(context.Context) error, err error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	service := cfg.ServiceName
+	if service == "" {
+		service = defaultService
+	}
+
+	var exp Exporter
+	switch strings.ToLower(cfg.Exporter) {
+	case "", "otlp":
+		endpoint := cfg.Endpoint
+		if endpoint == "" {
+			endpoint = defaultEndpoint
+		}
+		exp = NewOTLPExporter(endpoint, service, cfg.Headers)
+	case "stdout":
+		exp = NewWriterExporter(os.Stdout, service)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q (want otlp or stdout)", cfg.Exporter)
+	}
+
+	return Use(exp, cfg.SampleRatio), nil
+}
+
+// Use — включает трассировку с данным экспортером (в т.ч. MemoryExporter в
+// тестах); ratio <= 0 — все трассы
+func Use(exp Exporter, ratio float64) (shutdown func(context.Context) error) {
+	if ratio <= 0 {
+		ratio = 1
+	}
+	p := &provider{
+		exp:   exp,
+		ratio: ratio,
+		queue: make(chan SpanData, queueSize),
+		flush: make(chan chan struct{}),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go p.loop()
+
+	if old := current.Swap(p); old != nil {
+		old.shutdown(context.Background())
+	}
+	return func(ctx context.Context) error {
+		current.CompareAndSwap(p, nil)
+		return p.shutdown(ctx)
+	}
+}
+
+// ForceFlush — экспортирует всё, что уже в очереди (для тестов и перед выходом)
+func ForceFlush(ctx context.Context) {
+	if p := current.Load(); p != nil {
+		p.forceFlush(ctx)
+	}
+}
+
+/* ========================= PROVIDER ========================= */
+
+// provider — очередь завершённых спанов и фоновый пакетный экспорт;
+// при переполнении очереди спаны отбрасываются, а не тормозят скан
+type provider struct {
+	exp   Exporter
+	ratio float64
+
+	queue chan SpanData
+	flush chan chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+
+	mu      sync.Mutex
+	dropped int
+	lastErr time.Time
+}
+
+func (p *provider) sample(t TraceID) bool { return sampleBelow(t, p.ratio) }
+
+func (p *provider) enqueue(d SpanData) {
+	select {
+	case p.queue <- d:
+	default:
+		p.mu.Lock()
+		p.dropped++
+		p.mu.Unlock()
+	}
+}
+
+func (p *provider) loop() {
+	defer close(p.done)
+	t := time.NewTicker(flushInterval)
+	defer t.Stop()
+
+	batch := make([]SpanData, 0, batchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		err := p.exp.Export(ctx, batch)
+		cancel()
+		if err != nil {
+			p.reportError(err, len(batch))
+		}
+		batch = make([]SpanData, 0, batchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case d := <-p.queue:
+				batch = append(batch, d)
+				if len(batch) >= batchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case d := <-p.queue:
+			batch = append(batch, d)
+			if len(batch) >= batchSize {
+				export()
+			}
+		case <-t.C:
+			export()
+		case ack := <-p.flush:
+			drain()
+			close(ack)
+		case <-p.stop:
+			drain()
+			return
+		}
+	}
+}
+
+// reportError — ошибки экспорта не чаще раза в минуту (коллектор может
+// лежать часами, а пачки уходят каждые пару секунд)
+func (p *provider) reportError(err error, n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.lastErr) < time.Minute {
+		return
+	}
+	p.lastErr = time.Now()
+	logger.Errorf("tracing: export %d spans: %v (dropped on full queue: %d)", n, err, p.dropped)
+}
+
+func (p *provider) forceFlush(ctx context.Context) {
+	ack := make(chan struct{})
+	select {
+	case p.flush <- ack:
+	case <-p.done:
+		return
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-ack:
+	case <-ctx.Done():
+	}
+}
+
+func (p *provider) shutdown(ctx context.Context) error {
+	p.once.Do(func() { close(p.stop) })
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/* ========================= EXPORTERS ========================= */
+
+// MemoryExporter — копит спаны в памяти (тесты)
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewMemoryExporter() *MemoryExporter { return &MemoryExporter{} }
+
+func (m *MemoryExporter) Export(_ context.Context, spans []SpanData) error {
+	m.mu.Lock()
+	m.spans = append(m.spans, spans...)
+	m.mu.Unlock()
+	return nil
+}
+
+// Spans — копия экспортированных спанов (сначала ForceFlush)
+func (m *MemoryExporter) Spans() []SpanData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SpanData(nil), m.spans...)
+}
+
+func (m *MemoryExporter) Reset() {
+	m.mu.Lock()
+	m.spans = nil
+	m.mu.Unlock()
+}
+
+// OTLPExporter — OTLP/HTTP с JSON-кодированием (POST /v1/traces)
+type OTLPExporter struct {
+	url     string
+	service string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewOTLPExporter(endpoint, service string, headers map[string]string) *OTLPExporter {
+	return &OTLPExporter{
+		url:     strings.TrimRight(endpoint, "/") + "/v1/traces",
+		service: service,
+		headers: headers,
+		client:  &http.Client{Timeout: exportTimeout},
+	}
+}
+
+func (o *OTLPExporter) Export(ctx context.Context, spans []SpanData) error {
+	body, err := json.Marshal(encodeOTLP(o.service, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// WriterExporter — тот же OTLP JSON, одна строка на пачку (отладка без коллектора)
+type WriterExporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	service string
+}
+
+func NewWriterExporter(w io.Writer, service string) *WriterExporter {
+	return &WriterExporter{w: w, service: service}
+}
+
+func (e *WriterExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return json.NewEncoder(e.w).Encode(encodeOTLP(e.service, spans))
+}
+
+/* ========================= OTLP JSON ========================= */
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+// encodeOTLP — ExportTraceServiceRequest в JSON-отображении OTLP
+// (id — hex, int64 и время — строками)
+func encodeOTLP(service string, spans []SpanData) otlpRequest {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, d := range spans {
+		s := otlpSpan{
+			TraceID:           d.TraceID.String(),
+			SpanID:            d.SpanID.String(),
+			Name:              d.Name,
+			Kind:              d.Kind,
+			StartTimeUnixNano: strconv.FormatInt(d.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(d.End.UnixNano(), 10),
+			Attributes:        encodeAttrs(d.Attrs),
+			Status:            otlpStatus{Code: d.Status, Message: d.Message},
+		}
+		if d.ParentID.IsValid() {
+			s.ParentSpanID = d.ParentID.String()
+		}
+		for _, e := range d.Events {
+			s.Events = append(s.Events, otlpEvent{
+				TimeUnixNano: strconv.FormatInt(e.Time.UnixNano(), 10),
+				Name:         e.Name,
+				Attributes:   encodeAttrs(e.Attrs),
+			})
+		}
+		out = append(out, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttrs([]Attr{String("service.name", service)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/L1nMay/portscanner"}, Spans: out}},
+	}}}
+}
+
+func encodeAttrs(attrs []Attr) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	// последний SetAttrs с тем же ключом побеждает
+	idx := make(map[string]int, len(attrs))
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kv := otlpKeyValue{Key: a.Key, Value: encodeValue(a.Value)}
+		if i, ok := idx[a.Key]; ok {
+			out[i] = kv
+			continue
+		}
+		idx[a.Key] = len(out)
+		out = append(out, kv)
+	}
+	return out
+}
+
+func encodeValue(v any) map[string]any {
+	switch x := v.(type) {
+	case string:
+		return map[string]any{"stringValue": x}
+	case bool:
+		return map[string]any{"boolValue": x}
+	case int:
+		return map[string]any{"intValue": strconv.Itoa(x)}
+	case int64:
+		return map[string]any{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		return map[string]any{"doubleValue": x}
+	default:
+		return map[string]any{"stringValue": fmt.Sprint(x)}
+	}
+}
@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestOTLPExport(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		reqs []otlpRequest
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("content-type = %q", ct)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer otlp-key" {
+			t.Errorf("authorization = %q", got)
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		mu.Lock()
+		reqs = append(reqs, req)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	shutdown := Use(NewOTLPExporter(srv.URL+"/", "svc", map[string]string{"Authorization": "Bearer otlp-key"}), 1)
+
+	ctx, parent := Start(context.Background(), "scan")
+	_, child := Start(ctx, "scan.host", WithAttrs(String("host.ip", "10.0.0.1"), Int("ports", 3)))
+	child.End()
+	parent.End()
+
+	// shutdown дописывает очередь
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 1 || len(reqs[0].ResourceSpans) != 1 {
+		t.Fatalf("exports = %+v", reqs)
+	}
+	rs := reqs[0].ResourceSpans[0]
+	if attrs := rs.Resource.Attributes; len(attrs) != 1 || attrs[0].Key != "service.name" || attrs[0].Value["stringValue"] != "svc" {
+		t.Errorf("resource = %+v", attrs)
+	}
+	if len(rs.ScopeSpans) != 1 || len(rs.ScopeSpans[0].Spans) != 2 {
+		t.Fatalf("scope spans = %+v", rs.ScopeSpans)
+	}
+
+	byName := map[string]otlpSpan{}
+	for _, s := range rs.ScopeSpans[0].Spans {
+		byName[s.Name] = s
+	}
+	p, c := byName["scan"], byName["scan.host"]
+	if len(p.TraceID) != 32 || len(p.SpanID) != 16 || p.ParentSpanID != "" {
+		t.Errorf("root span = %+v", p)
+	}
+	if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID {
+		t.Errorf("child %s/%s, want parent %s/%s", c.TraceID, c.ParentSpanID, p.TraceID, p.SpanID)
+	}
+
+	// int64 в OTLP JSON — строкой
+	vals := map[string]map[string]any{}
+	for _, a := range c.Attributes {
+		vals[a.Key] = a.Value
+	}
+	if vals["ports"]["intValue"] != "3" || vals["host.ip"]["stringValue"] != "10.0.0.1" {
+		t.Errorf("attributes = %+v", c.Attributes)
+	}
+}
@@ -0,0 +1,273 @@
+// Package tracing — минимальная трассировка в модели OpenTelemetry: спаны
+// с родителем через context, W3C traceparent, пакетный экспорт в OTLP/HTTP
+// (JSON), stdout или память. Без Setup трассировка выключена и Start почти
+// ничего не стоит.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID/SpanID — идентификаторы W3C Trace Context
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+func (s SpanID) IsValid() bool  { return s != SpanID{} }
+
+// SpanKind — как в OTLP
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindServer   SpanKind = 2
+	KindClient   SpanKind = 3
+)
+
+// StatusCode — как в OTLP: Unset, Ok, Error
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = 0
+	StatusOK    StatusCode = 1
+	StatusError StatusCode = 2
+)
+
+// Attr — атрибут спана; Value — string, bool, int/int64, float64
+type Attr struct {
+	Key   string
+	Value any
+}
+
+func String(k, v string) Attr          { return Attr{k, v} }
+func Int(k string, v int) Attr         { return Attr{k, int64(v)} }
+func Int64(k string, v int64) Attr     { return Attr{k, v} }
+func Bool(k string, v bool) Attr       { return Attr{k, v} }
+func Float64(k string, v float64) Attr { return Attr{k, v} }
+
+// SpanData — завершённый спан в том виде, в каком он уходит экспортеру
+type SpanData struct {
+	TraceID  TraceID
+	SpanID   SpanID
+	ParentID SpanID
+	Name     string
+	Kind     SpanKind
+	Start    time.Time
+	End      time.Time
+	Attrs    []Attr
+	Events   []Event
+	Status   StatusCode
+	Message  string // описание статуса (текст ошибки)
+}
+
+// Event — отметка внутри спана (например, ошибка)
+type Event struct {
+	Name  string
+	Time  time.Time
+	Attrs []Attr
+}
+
+/* ========================= SPAN ========================= */
+
+// Span — открытый спан; методы безопасны для nil (трассировка выключена
+// или спан не попал в выборку)
+type Span struct {
+	mu    sync.Mutex
+	data  SpanData
+	ended bool
+	p     *provider
+}
+
+// remote — родитель из входящего traceparent
+type remote struct {
+	trace   TraceID
+	span    SpanID
+	sampled bool
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// FromContext — текущий спан (nil, если его нет)
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// StartOption — настройка нового спана
+type StartOption func(*SpanData)
+
+func WithKind(k SpanKind) StartOption { return func(d *SpanData) { d.Kind = k } }
+
+func WithAttrs(attrs ...Attr) StartOption {
+	return func(d *SpanData) { d.Attrs = append(d.Attrs, attrs...) }
+}
+
+// Start — дочерний спан текущего (или корневой); End обязателен
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	p := current.Load()
+	if p == nil {
+		return ctx, nil
+	}
+
+	d := SpanData{Name: name, Kind: KindInternal, Start: time.Now()}
+	sampled := false
+	switch parent := FromContext(ctx); {
+	case parent != nil:
+		d.TraceID, d.ParentID = parent.data.TraceID, parent.data.SpanID
+		sampled = true // несэмплированные спаны в context не попадают
+	default:
+		if rp, ok := ctx.Value(remoteKey{}).(remote); ok {
+			d.TraceID, d.ParentID, sampled = rp.trace, rp.span, rp.sampled
+		} else {
+			d.TraceID = newTraceID()
+			sampled = p.sample(d.TraceID)
+		}
+	}
+	if !sampled {
+		// решение наследуют дочерние спаны: не начинать для них новую трассу
+		return context.WithValue(ctx, remoteKey{}, remote{trace: d.TraceID}), nil
+	}
+	d.SpanID = newSpanID()
+	for _, o := range opts {
+		o(&d)
+	}
+
+	s := &Span{data: d, p: p}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// SetAttrs — добавляет атрибуты (тот же ключ — последнее значение)
+func (s *Span) SetAttrs(attrs ...Attr) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Attrs = append(s.data.Attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError — событие exception и статус Error; nil игнорируется
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Events = append(s.data.Events, Event{
+		Name:  "exception",
+		Time:  time.Now(),
+		Attrs: []Attr{String("exception.message", err.Error()), String("exception.type", fmt.Sprintf("%T", err))},
+	})
+	s.data.Status, s.data.Message = StatusError, err.Error()
+	s.mu.Unlock()
+}
+
+// SetStatus — явный статус (Ok/Error)
+func (s *Span) SetStatus(code StatusCode, msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.data.Status, s.data.Message = code, msg
+	s.mu.Unlock()
+}
+
+// End — закрывает спан и ставит его в очередь экспорта (повторный End — no-op)
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	d := s.data
+	s.mu.Unlock()
+
+	s.p.enqueue(d)
+}
+
+// TraceID — для логов и заголовков ("" для nil)
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.data.TraceID.String()
+}
+
+/* ========================= W3C TRACE CONTEXT ========================= */
+
+// Extract — родитель из заголовка traceparent (00-<trace>-<span>-<flags>);
+// невалидный заголовок игнорируется
+func Extract(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return ctx
+	}
+	var rp remote
+	if _, err := hex.Decode(rp.trace[:], []byte(parts[1])); err != nil {
+		return ctx
+	}
+	if _, err := hex.Decode(rp.span[:], []byte(parts[2])); err != nil {
+		return ctx
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || !rp.trace.IsValid() || !rp.span.IsValid() {
+		return ctx
+	}
+	rp.sampled = flags[0]&1 == 1
+	return context.WithValue(ctx, remoteKey{}, rp)
+}
+
+// Traceparent — заголовок для исходящих запросов ("" без спана)
+func Traceparent(ctx context.Context) string {
+	s := FromContext(ctx)
+	if s == nil {
+		return ""
+	}
+	return "00-" + s.data.TraceID.String() + "-" + s.data.SpanID.String() + "-01"
+}
+
+/* ========================= IDS ========================= */
+
+func newTraceID() TraceID {
+	var t TraceID
+	_, _ = rand.Read(t[:])
+	return t
+}
+
+func newSpanID() SpanID {
+	var s SpanID
+	_, _ = rand.Read(s[:])
+	return s
+}
+
+// sampleBelow — решение по младшим 8 байтам trace id (как TraceIDRatioBased)
+func sampleBelow(t TraceID, ratio float64) bool {
+	if ratio >= 1 {
+		return true
+	}
+	if ratio <= 0 {
+		return false
+	}
+	v := binary.BigEndian.Uint64(t[8:]) >> 1
+	return v < uint64(ratio*(1<<63))
+}
+
+var current atomic.Pointer[provider]
@@ -0,0 +1,97 @@
+package webui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/L1nMay/portscanner/internal/alerting"
+	"github.com/L1nMay/portscanner/internal/config"
+)
+
+// handleAlertRules — правила из config.yaml (только чтение) + правила из БД
+func (s *Server) handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		dbRules, err := s.pg.ListAlertRules()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		out := append([]alerting.Rule(nil), s.cfg.Alerts.Rules...)
+		out = append(out, dbRules...)
+		writeJSON(w, 200, out)
+
+	case http.MethodPost:
+		rule, err := decodeAlertRule(s.cfg, r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err := s.pg.CreateAlertRule(rule); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 201, rule)
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func (s *Server) handleAlertRule(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := s.pg.GetAlertRule(id)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, rule)
+
+	case http.MethodPut:
+		rule, err := decodeAlertRule(s.cfg, r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		rule.ID = id
+		if err := s.pg.UpdateAlertRule(rule); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, rule)
+
+	case http.MethodDelete:
+		if err := s.pg.DeleteAlertRule(id); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"deleted": id})
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func decodeAlertRule(cfg *config.Config, r *http.Request) (*alerting.Rule, error) {
+	rule := alerting.Rule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		return nil, err
+	}
+	if err := rule.Compile(); err != nil {
+		return nil, err
+	}
+	for _, ch := range rule.Channels {
+		if !cfg.HasChannel(ch) {
+			return nil, fmt.Errorf("unknown channel %q", ch)
+		}
+	}
+	return &rule, nil
+}
@@ -1,11 +1,15 @@
 (() => {
   const $ = (id) => document.getElementById(id);
 
-  const STATE = { results: [], runs: [], page: 1, pageSize: 20 };
+  // results — текущая страница с сервера; cursors[i] — курсор страницы i+1
+  const STATE = { results: [], total: 0, nextCursor: "", cursors: [""], page: 1, pageSize: 20, services: [], runs: [] };
 
   // scan ui state
   let scanRunning = false;
   let scanES = null;
+  // live: since_cursor для /api/results?since= и отложенное дочитывание
+  let liveSince = "";
+  let liveTimer = null;
 
   // plan ui state
   let lastPlan = null;
@@ -31,22 +35,35 @@
     localStorage.setItem(TOKEN_KEY, v || "");
   }
 
-  function authHeaders(extra = {}) {
+  // ---------- session ----------
+  // cookie-сессия ставится сервером (HttpOnly); CSRF-токен держим в памяти
+  // и получаем заново через /api/auth/me после перезагрузки страницы
+  let csrfToken = "";
+  let currentUser = null;
+
+  function authHeaders(extra = {}, method = "GET") {
+    const out = { ...extra };
+    if (method !== "GET" && csrfToken) out["X-CSRF-Token"] = csrfToken;
+
     const token = getToken().trim();
-    if (!token) return extra;
+    if (!token) return out;
 
     const value = token.toLowerCase().startsWith("bearer ")
       ? token
       : `Bearer ${token}`;
 
-    return { ...extra, Authorization: value };
+    return { ...out, Authorization: value };
   }
 
   async function api(path, opts = {}) {
     const o = { ...opts };
-    o.headers = authHeaders(o.headers || {});
+    o.headers = authHeaders(o.headers || {}, (o.method || "GET").toUpperCase());
     const res = await fetch(path, o);
 
+    if (res.status === 401) {
+      showLogin();
+      throw new Error("Login required");
+    }
     if (!res.ok) {
       const txt = await res.text().catch(() => "");
       throw new Error(txt || res.statusText);
@@ -57,6 +74,70 @@
     return res.json();
   }
 
+  function setUser(me) {
+    currentUser = me?.user || me?.principal || null;
+    if (me?.csrf_token) csrfToken = me.csrf_token;
+
+    const el = $("userName");
+    if (el) el.textContent = currentUser ? `${currentUser.username} (${currentUser.role})` : "—";
+    $("btnLogout")?.classList.toggle("hidden", !currentUser);
+  }
+
+  function showLogin() {
+    setUser(null);
+    csrfToken = "";
+    const m = $("loginModal");
+    if (!m) return;
+    m.classList.remove("hidden");
+    m.style.display = "flex";
+    $("loginUser")?.focus();
+  }
+
+  function hideLogin() {
+    const m = $("loginModal");
+    if (!m) return;
+    m.style.display = "none";
+    m.classList.add("hidden");
+  }
+
+  async function login() {
+    const username = ($("loginUser")?.value || "").trim();
+    const password = $("loginPass")?.value || "";
+    $("loginError").textContent = "";
+
+    try {
+      const res = await fetch("/api/auth/login", {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ username, password }),
+      });
+      if (!res.ok) {
+        const txt = await res.text().catch(() => "");
+        throw new Error(txt.trim() || `login failed (${res.status})`);
+      }
+      setUser(await res.json());
+      $("loginPass").value = "";
+      hideLogin();
+      await refreshAll();
+    } catch (e) {
+      $("loginError").textContent = e.message;
+    }
+  }
+
+  async function logout() {
+    try {
+      await api("/api/auth/logout", { method: "POST" });
+    } catch (e) {
+      // сессия уже могла истечь — всё равно показываем форму входа
+    }
+    showLogin();
+  }
+
+  async function loadMe() {
+    const me = await api("/api/auth/me");
+    setUser(me);
+  }
+
   function fmt(ts) {
     if (!ts) return "—";
     try { return new Date(ts).toLocaleString(); } catch { return String(ts); }
@@ -68,40 +149,94 @@
     }[s]));
   }
 
-  function ipToNum(ip) {
-    const p = String(ip || "").split(".").map((x) => parseInt(x, 10));
-    if (p.length !== 4 || p.some((x) => Number.isNaN(x))) return 0;
-    return ((p[0] << 24) >>> 0) + (p[1] << 16) + (p[2] << 8) + p[3];
+  function osLabel(r) {
+    if (!r.os_family) return "—";
+    return `${r.os_family} (${r.os_confidence ?? 0}%)`;
   }
 
-  // ---------- filtering/rendering ----------
-  function getFilteredSortedResults() {
-    const q = ($("q")?.value || "").trim().toLowerCase();
-    const svc = ($("svc")?.value || "").trim().toLowerCase();
+  // ---------- filtering (на сервере) ----------
+  // строка поиска — язык запросов: "port:3389 AND NOT tag:vpn", "net:10.0.0.0/8 first_seen:<7d";
+  // слово без поля ищется по ip, порту, сервису и баннеру
+  function resultsQuery() {
+    const q = ($("q")?.value || "").trim();
+    const svc = ($("svc")?.value || "").trim();
     const sort = ($("sort")?.value || "lastSeenDesc").trim();
 
-    let items = STATE.results.slice();
+    const params = new URLSearchParams();
+    if (q) params.set("q", q);
+    if (svc) params.set("service", svc);
+    params.set("sort", { lastSeenAsc: "last_seen", ipAsc: "ip", portAsc: "port" }[sort] || "-last_seen");
+    params.set("limit", String(STATE.pageSize));
+    return params;
+  }
 
-    if (svc) items = items.filter((r) => String(r.service || "").toLowerCase() === svc);
+  async function loadResults() {
+    const params = resultsQuery();
+    const cursor = STATE.cursors[STATE.page - 1] || "";
+    if (cursor) params.set("cursor", cursor);
 
-    if (q) {
-      items = items.filter((r) => {
-        const hay = `${r.ip}:${r.port} ${(r.service || "")} ${(r.banner || "")}`.toLowerCase();
-        return hay.includes(q);
-      });
+    let res;
+    try {
+      res = await api(`/api/results?${params}`);
+      $("q")?.classList.remove("invalid");
+    } catch (e) {
+      // синтаксическая ошибка запроса — показываем под таблицей, без тоста
+      if (String(e.message).includes("query:")) {
+        $("q")?.classList.add("invalid");
+        if ($("count")) $("count").textContent = e.message.replace(/^invalid filter: /, "").trim();
+        return;
+      }
+      throw e;
     }
+    STATE.results = Array.isArray(res?.items) ? res.items : [];
+    STATE.total = res?.total ?? 0;
+    STATE.nextCursor = res?.next_cursor || "";
+    STATE.cursors[STATE.page] = STATE.nextCursor;
+    renderResults();
+  }
 
-    items.sort((a, b) => {
-      const aLS = Date.parse(a.last_seen || a.lastSeen || 0) || 0;
-      const bLS = Date.parse(b.last_seen || b.lastSeen || 0) || 0;
+  // catchUpResults — находки, подтверждённые с liveSince, сливаются в первую
+  // страницу (обновлённые — на месте, новые — сверху при сортировке по last_seen)
+  async function catchUpResults() {
+    if (!liveSince) return;
+    const params = resultsQuery();
+    params.delete("sort");
+    params.set("since", liveSince);
+    params.set("limit", "200");
+
+    const res = await api(`/api/results?${params}`);
+    const items = Array.isArray(res?.items) ? res.items : [];
+    liveSince = res?.since_cursor || liveSince;
+    if (STATE.page !== 1 || items.length === 0) return;
+
+    const newestFirst = (($("sort")?.value || "lastSeenDesc").trim() === "lastSeenDesc");
+    const byId = new Map(STATE.results.map((x, i) => [x.id, i]));
+    const fresh = [];
+    for (const it of items) {
+      if (byId.has(it.id)) {
+        STATE.results[byId.get(it.id)] = it;
+      } else if (newestFirst) {
+        fresh.unshift(it);
+        STATE.total += 1;
+      }
+    }
+    STATE.results = fresh.concat(STATE.results).slice(0, STATE.pageSize);
+    renderResults();
+  }
 
-      if (sort === "lastSeenAsc") return aLS - bLS;
-      if (sort === "ipAsc") return ipToNum(a.ip) - ipToNum(b.ip);
-      if (sort === "portAsc") return (a.port || 0) - (b.port || 0);
-      return bLS - aLS;
-    });
+  function scheduleCatchUp() {
+    if (liveTimer) return;
+    liveTimer = setTimeout(() => {
+      liveTimer = null;
+      catchUpResults().catch(() => {});
+    }, 1000);
+  }
 
-    return items;
+  // смена фильтра/сортировки — с первой страницы
+  function reloadResults() {
+    STATE.page = 1;
+    STATE.cursors = [""];
+    loadResults().catch((e) => toast(e.message, "error"));
   }
 
   function rebuildServiceOptions() {
@@ -109,9 +244,7 @@
     if (!svcSel) return;
 
     const current = (svcSel.value || "").toLowerCase();
-    const services = Array.from(
-      new Set(STATE.results.map((r) => String((r.service || "unknown")).trim().toLowerCase()))
-    ).sort();
+    const services = STATE.services;
 
     svcSel.innerHTML =
       `<option value="">All services</option>` +
@@ -128,25 +261,20 @@
     const nextBtn = $("next");
     if (!tbody) return;
 
-    const items = getFilteredSortedResults();
-    const total = items.length;
+    const pageItems = STATE.results;
+    const total = STATE.total;
     const totalPages = Math.max(1, Math.ceil(total / STATE.pageSize));
 
-    if (STATE.page > totalPages) STATE.page = totalPages;
-    if (STATE.page < 1) STATE.page = 1;
-
-    const start = (STATE.page - 1) * STATE.pageSize;
-    const pageItems = items.slice(start, start + STATE.pageSize);
-
     tbody.innerHTML = "";
     if (pageItems.length === 0) {
-      tbody.innerHTML = `<tr><td colspan="6" style="padding:12px;color:#94a3b8">No findings</td></tr>`;
+      tbody.innerHTML = `<tr><td colspan="7" style="padding:12px;color:#94a3b8">No findings</td></tr>`;
     } else {
       tbody.innerHTML = pageItems.map((x) => `
-        <tr>
-          <td>${escapeHtml(x.ip)}</td>
+        <tr${x.state === "closed" ? ` class="closed" title="closed"` : ""}>
+          <td>${escapeHtml(x.ip)}${(x.tags || []).length ? `<div class="hint">${escapeHtml(x.tags.join(", "))}</div>` : ""}</td>
+          <td title="${escapeHtml(x.os_name || "")}">${escapeHtml(osLabel(x))}</td>
           <td>${escapeHtml(String(x.port))}/${escapeHtml(String(x.proto || "tcp"))}</td>
-          <td>${escapeHtml(x.service || "unknown")}</td>
+          <td>${escapeHtml(x.service || "unknown")}${x.compliance === "violating" ? ` <span title="${escapeHtml((x.violations || []).map((v) => `${v.policy}: ${v.reason}`).join("\n"))}">⚠️</span>` : ""}</td>
           <td>${escapeHtml(fmt(x.first_seen))}</td>
           <td>${escapeHtml(fmt(x.last_seen))}</td>
           <td title="${escapeHtml(String(x.banner || ""))}">${escapeHtml(x.banner || "—")}</td>
@@ -157,7 +285,7 @@
     if (count) count.textContent = `Showing ${pageItems.length} of ${total}`;
     if (pageEl) pageEl.textContent = `Page ${STATE.page} / ${totalPages}`;
     if (prevBtn) prevBtn.disabled = STATE.page <= 1;
-    if (nextBtn) nextBtn.disabled = STATE.page >= totalPages;
+    if (nextBtn) nextBtn.disabled = !STATE.nextCursor;
   }
 
   function renderRuns() {
@@ -167,18 +295,19 @@
     tbody.innerHTML = STATE.runs.map((r) => `
       <tr>
         <td>${escapeHtml(fmt(r.started_at))}</td>
-        <td>${escapeHtml(r.engine)}</td>
+        <td>${escapeHtml(r.engine)}${r.source === "import" ? " (import)" : ""}</td>
         <td>${escapeHtml(String(r.found))}</td>
-        <td>${escapeHtml(String(r.new_found))}</td>
-        <td>${escapeHtml(String(r.ports_spec || ""))}</td>
-        <td>${escapeHtml(r.notes || "—")}</td>
+        <td>${escapeHtml(String(r.new))}</td>
+        <td>${escapeHtml(String(r.ports || ""))}</td>
+        <td title="${escapeHtml(r.error || "")}">${escapeHtml(r.status || "—")}${r.error ? " ⚠️" : ""}
+          • <a href="/api/scans/${encodeURIComponent(r.id)}/log?format=text" target="_blank" rel="noopener">log</a></td>
       </tr>
     `).join("");
 
     const last = STATE.runs[0];
     if (last) {
       $("statLastScan").textContent = fmt(last.started_at);
-      $("statLastScanMeta").textContent = `${last.engine} • found ${last.found} • new ${last.new_found}`;
+      $("statLastScanMeta").textContent = `${last.engine} • found ${last.found} • new ${last.new}`;
     } else {
       $("statLastScan").textContent = "—";
       $("statLastScanMeta").textContent = "—";
@@ -190,18 +319,17 @@
     if (st) {
       $("statFindings").textContent = st.total_findings ?? "—";
       $("statHosts").textContent = st.unique_hosts ?? "—";
+      STATE.services = Array.isArray(st.services) ? st.services : [];
     }
-
-    const res = await api("/api/results");
-    STATE.results = Array.isArray(res) ? res : [];
+    rebuildServiceOptions();
 
     const runs = await api("/api/scans");
     STATE.runs = Array.isArray(runs) ? runs : [];
+    renderRuns();
 
-    rebuildServiceOptions();
     STATE.page = 1;
-    renderResults();
-    renderRuns();
+    STATE.cursors = [""];
+    await loadResults();
   }
 
   // ---------- modal / progress ----------
@@ -243,53 +371,96 @@
     }
   }
 
-  // ✅ SSE всегда без токена (сервер stream без auth)
-  function startProgressStream() {
+  // поток событий скана: progress/phase/log/finding/done; при обрыве
+  // EventSource переподключается сам и дочитывает пропущенное по Last-Event-ID
+  function startProgressStream(scanId) {
     if (scanES) {
       scanES.close();
       scanES = null;
     }
+    if (!scanId) return;
 
-    scanES = new EventSource("/api/scan/stream");
+    // с запасом на расхождение часов: дубли сливаются по id
+    liveSince = new Date(Date.now() - 60000).toISOString();
 
-    scanES.onmessage = (e) => {
-      let p;
-      try { p = JSON.parse(e.data); } catch { return; }
+    const ts = () => new Date().toLocaleTimeString();
+    const parse = (e) => {
+      try { return JSON.parse(e.data); } catch { return null; }
+    };
 
-      if (!scanRunning) return;
+    scanES = new EventSource(`/api/scans/${encodeURIComponent(scanId)}/stream`);
 
+    scanES.addEventListener("progress", (e) => {
+      const p = parse(e);
+      if (!p || !scanRunning) return;
       const percent = Number(p.percent ?? 0);
       const message = String(p.message ?? "");
-
       setProgress(percent, message);
-      appendLog(`[${new Date().toLocaleTimeString()}] ${percent}% ${message}`);
-
-      const msgLower = message.toLowerCase();
-      const done =
-        percent >= 100 ||
-        msgLower.includes("finished") ||
-        msgLower.includes("done") ||
-        msgLower.includes("cancel") ||
-        msgLower.includes("failed") ||
-        msgLower.includes("error");
-
-      if (done) {
-        scanRunning = false;
+      appendLog(`[${ts()}] ${percent}% ${message}`);
+    });
 
-        if (scanES) {
-          scanES.close();
-          scanES = null;
-        }
+    scanES.addEventListener("phase", (e) => {
+      const p = parse(e);
+      if (!p || !scanRunning) return;
+      appendLog(`[${ts()}] phase: ${p.name}${p.detail ? ` (${p.detail})` : ""}`);
+    });
 
-        setTimeout(async () => {
-          closeScanModal();
-          await refreshAll().catch(() => {});
-        }, 400);
+    scanES.addEventListener("log", (e) => {
+      const l = parse(e);
+      if (!l || !scanRunning) return;
+      appendLog(`[${ts()}] ${l.level} ${l.source === "stderr" ? "[stderr] " : ""}${l.message}`);
+    });
+
+    scanES.addEventListener("finding", (e) => {
+      const f = parse(e);
+      if (!f || !scanRunning) return;
+      appendLog(`[${ts()}] ${f.new ? "NEW " : ""}${f.ip}:${f.port}/${f.proto} ${f.service}${f.banner ? ` — ${f.banner.slice(0, 80)}` : ""}`);
+      scheduleCatchUp();
+    });
+
+    // буфер на сервере уже вытеснил часть событий — находки дочитываем из /api/results
+    scanES.addEventListener("reset", (e) => {
+      const r = parse(e) || {};
+      if (!scanRunning) return;
+      appendLog(`[${ts()}] ${r.message || "some events were skipped"}`);
+      scheduleCatchUp();
+    });
+
+    // скан идёт не в этом экземпляре: живых событий не будет
+    scanES.addEventListener("status", (e) => {
+      const d = parse(e) || {};
+      scanRunning = false;
+      if (scanES) {
+        scanES.close();
+        scanES = null;
       }
-    };
+      toast(d.message || "Live progress unavailable", "info");
+      closeScanModal();
+      refreshAll().catch(() => {});
+    });
+
+    scanES.addEventListener("done", (e) => {
+      const d = parse(e) || {};
+      scanRunning = false;
+      if (scanES) {
+        scanES.close();
+        scanES = null;
+      }
+      if (d.status === "failed") {
+        toast(`Scan failed: ${d.error || d.message || ""}`, "error");
+      } else if (d.status === "cancelled") {
+        toast("Scan cancelled", "info");
+      }
+
+      setTimeout(async () => {
+        closeScanModal();
+        await refreshAll().catch(() => {});
+      }, 400);
+    });
 
     scanES.onerror = () => {
-      if (scanRunning) {
+      // CONNECTING — браузер переподключается сам
+      if (scanES && scanES.readyState === EventSource.CLOSED && scanRunning) {
         scanRunning = false;
         closeScanModal();
         toast("Progress stream disconnected", "error");
@@ -299,7 +470,7 @@
 
   async function cancelScan() {
     try {
-      await fetch("/api/scan/cancel", { method: "POST", headers: authHeaders({}) });
+      await api("/api/scan/cancel", { method: "POST" });
       appendLog("[action] cancel requested");
       toast("Cancel requested", "info");
       closeScanModal();
@@ -331,6 +502,13 @@
     $("planIface").textContent = plan.interface || "—";
     $("planWait").textContent = String(plan.wait_seconds ?? "—");
     $("planReason").textContent = plan.reason || "—";
+    $("planProbes").textContent =
+      `${plan.addresses ?? "—"} × ${plan.port_count ?? "—"} = ${plan.probes ?? "—"} probes at ${plan.rate ?? "—"}/s`;
+    $("planEstimate").textContent = plan.estimated
+      ? `${plan.estimated} (incl. banners ${plan.banner_seconds ?? 0}s: ~${plan.expected_open_ports ?? 0} open ports × up to ${plan.banner_seconds_per_port}s ÷ ${plan.banner_concurrency ?? 1})`
+      : "—";
+    const warnings = Array.isArray(plan.warnings) ? plan.warnings : [];
+    $("planWarnings").textContent = warnings.length ? warnings.map((w) => `⚠️ ${w}`).join("\n") : "—";
   }
 
   async function loadPlan() {
@@ -346,7 +524,6 @@
     openScanModal();
     setProgress(3, startMsg || "Starting...");
     appendLog(`[${new Date().toLocaleTimeString()}] 3% ${startMsg || "Starting..."}`);
-    startProgressStream();
     return true;
   }
 
@@ -354,15 +531,8 @@
     if (!startScanUI("Starting full scan...")) return;
 
     try {
-      const res = await fetch("/api/scan", {
-        method: "POST",
-        headers: authHeaders({}),
-      });
-
-      if (!res.ok) {
-        const txt = await res.text().catch(() => "");
-        throw new Error(txt || `scan failed (${res.status})`);
-      }
+      const res = await api("/api/scan", { method: "POST" });
+      startProgressStream(res?.scan_id);
 
       toast("Full scan started", "ok");
       // дальше UI ждёт прогресс по SSE
@@ -385,16 +555,12 @@
     if (!startScanUI("Starting fast scan...")) return;
 
     try {
-      const res = await fetch("/api/scan/custom", {
+      const res = await api("/api/scan/custom", {
         method: "POST",
-        headers: authHeaders({ "Content-Type": "application/json" }),
+        headers: { "Content-Type": "application/json" },
         body: JSON.stringify({ targets: [target], ports }),
       });
-
-      if (!res.ok) {
-        const txt = await res.text().catch(() => "");
-        throw new Error(txt || "scan start failed");
-      }
+      startProgressStream(res?.scan_id);
 
       toast("Fast scan started", "ok");
     } catch (e) {
@@ -411,19 +577,15 @@
       if (!startScanUI("Starting from plan...")) return;
 
       // по плану используем custom
-      const res = await fetch("/api/scan/custom", {
+      const res = await api("/api/scan/custom", {
         method: "POST",
-        headers: authHeaders({ "Content-Type": "application/json" }),
+        headers: { "Content-Type": "application/json" },
         body: JSON.stringify({
           targets: plan.targets || [],
           ports: plan.ports || "auto",
         }),
       });
-
-      if (!res.ok) {
-        const txt = await res.text().catch(() => "");
-        throw new Error(txt || `scan/custom failed (${res.status})`);
-      }
+      startProgressStream(res?.scan_id);
 
       toast("Scan started (from plan)", "ok");
       closePlanModal();
@@ -440,18 +602,26 @@
     $("btnScan")?.addEventListener("click", () => runFullScan());
     $("btnFastScan")?.addEventListener("click", () => runFastScan());
 
-    ["q", "svc", "sort"].forEach((id) => {
-      $(id)?.addEventListener("input", () => { STATE.page = 1; renderResults(); });
-      $(id)?.addEventListener("change", () => { STATE.page = 1; renderResults(); });
+    let searchTimer = null;
+    $("q")?.addEventListener("input", () => {
+      clearTimeout(searchTimer);
+      searchTimer = setTimeout(reloadResults, 300);
+    });
+    ["svc", "sort"].forEach((id) => {
+      $(id)?.addEventListener("change", reloadResults);
     });
 
     $("prev")?.addEventListener("click", () => {
-      if (STATE.page > 1) { STATE.page--; renderResults(); }
+      if (STATE.page > 1) {
+        STATE.page--;
+        loadResults().catch((e) => toast(e.message, "error"));
+      }
     });
 
     $("next")?.addEventListener("click", () => {
+      if (!STATE.nextCursor) return;
       STATE.page++;
-      renderResults();
+      loadResults().catch((e) => toast(e.message, "error"));
     });
 
     // plan buttons
@@ -484,6 +654,13 @@
     $("authToken")?.addEventListener("input", (e) => {
       setToken(e.target.value);
     });
+
+    // auth
+    $("btnLogin")?.addEventListener("click", () => login());
+    $("loginPass")?.addEventListener("keydown", (e) => {
+      if (e.key === "Enter") login();
+    });
+    $("btnLogout")?.addEventListener("click", () => logout());
   }
 
   // expose inline handlers
@@ -495,10 +672,12 @@
   document.addEventListener("DOMContentLoaded", async () => {
     closeScanModal();
     closePlanModal();
+    hideLogin();
 
     bindUI();
 
     try {
+      await loadMe();
       await refreshAll();
     } catch (e) {
       toast(e.message, "error");
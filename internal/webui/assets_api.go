@@ -0,0 +1,215 @@
+package webui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/netutil"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+type tagRequest struct {
+	CIDR        string   `json:"cidr"`
+	Tags        []string `json:"tags"`
+	Owner       string   `json:"owner"`
+	Environment string   `json:"environment"`
+}
+
+/* ========================= ASSET GROUPS ========================= */
+
+func (s *Server) handleAssetGroups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		groups, err := s.pg.ListAssetGroups()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 200, groups)
+
+	case http.MethodPost:
+		g, err := decodeAssetGroup(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err := s.pg.CreateAssetGroup(g); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 201, g)
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func (s *Server) handleAssetGroup(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		g, err := s.pg.GetAssetGroup(id)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, g)
+
+	case http.MethodPut:
+		g, err := decodeAssetGroup(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		g.ID = id
+		if err := s.pg.UpdateAssetGroup(g); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, g)
+
+	case http.MethodDelete:
+		if err := s.pg.DeleteAssetGroup(id); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"deleted": id})
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func decodeAssetGroup(r *http.Request) (*storage.AssetGroup, error) {
+	var g storage.AssetGroup
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		return nil, err
+	}
+
+	g.Name = strings.TrimSpace(g.Name)
+	if g.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	cidrs, err := netutil.NormalizeCIDRs(g.CIDRs)
+	if err != nil {
+		return nil, err
+	}
+	g.CIDRs = cidrs
+	g.Tags = cleanTags(g.Tags)
+
+	return &g, nil
+}
+
+/* ========================= TAGS ========================= */
+
+func (s *Server) handleAssetTags(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		tags, err := s.pg.ListTags()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 200, tags)
+
+	case http.MethodPost:
+		var req tagRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		cidr, err := netutil.NormalizeCIDR(req.CIDR)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		names := cleanTags(req.Tags)
+		if v := strings.TrimSpace(req.Owner); v != "" {
+			names = append(names, "owner:"+v)
+		}
+		if v := strings.TrimSpace(req.Environment); v != "" {
+			names = append(names, "env:"+v)
+		}
+		if len(names) == 0 {
+			http.Error(w, "at least one of tags/owner/environment is required", 400)
+			return
+		}
+
+		out := make([]*storage.Tag, 0, len(names))
+		for _, n := range names {
+			t, err := s.pg.AddTag(cidr, n)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			out = append(out, t)
+		}
+		writeJSON(w, 201, out)
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func (s *Server) handleAssetTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := s.pg.DeleteTag(id); err != nil {
+		storageError(w, err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"deleted": id})
+}
+
+/* ========================= HELPERS ========================= */
+
+func cleanTags(in []string) []string {
+	out := make([]string, 0, len(in))
+	seen := map[string]struct{}{}
+	for _, t := range in {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if _, ok := seen[t]; ok {
+			continue
+		}
+		seen[t] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+func pathID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid id")
+	}
+	return id, nil
+}
+
+func storageError(w http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	http.Error(w, err.Error(), 500)
+}
@@ -0,0 +1,258 @@
+package webui
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/auth"
+	"github.com/L1nMay/portscanner/internal/export"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// maxAuditBody — сколько тела запроса сохраняем в params
+const maxAuditBody = 64 << 10
+
+// auditRedact — поля, значения которых в журнал не попадают
+var auditRedact = map[string]struct{}{
+	"password": {}, "current": {}, "new": {}, "token": {}, "secret": {},
+	"bot_token": {}, "access_token": {},
+}
+
+type auditCtxKey struct{}
+
+// auditRecorder — перехватывает код ответа и текст ошибки
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+	errBuf bytes.Buffer
+}
+
+func (a *auditRecorder) WriteHeader(code int) {
+	if a.status == 0 {
+		a.status = code
+	}
+	a.ResponseWriter.WriteHeader(code)
+}
+
+func (a *auditRecorder) Write(b []byte) (int, error) {
+	if a.status == 0 {
+		a.status = 200
+	}
+	if a.status >= 400 && a.errBuf.Len() < 512 {
+		a.errBuf.Write(b)
+	}
+	return a.ResponseWriter.Write(b)
+}
+
+// audit — пишет изменяющие запросы (не GET/HEAD) в audit_log, включая
+// отказы в доступе; action фиксированный ("scan.start")
+func (s *Server) audit(action string, h http.HandlerFunc) http.HandlerFunc {
+	return s.auditWith(func(*http.Request) string { return action }, h)
+}
+
+// auditCRUD — action = resource + create/update/delete по методу
+func (s *Server) auditCRUD(resource string, h http.HandlerFunc) http.HandlerFunc {
+	return s.auditWith(func(r *http.Request) string {
+		switch r.Method {
+		case http.MethodPost:
+			return resource + ".create"
+		case http.MethodPut:
+			return resource + ".update"
+		case http.MethodDelete:
+			return resource + ".delete"
+		default:
+			return resource + "." + strings.ToLower(r.Method)
+		}
+	}, h)
+}
+
+func (s *Server) auditWith(action func(*http.Request) string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if safeMethod(r.Method) {
+			h(w, r)
+			return
+		}
+
+		params := map[string]any{}
+		if r.Body != nil {
+			body, _ := io.ReadAll(io.LimitReader(r.Body, maxAuditBody))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+			var obj map[string]any
+			if json.Unmarshal(body, &obj) == nil {
+				for k, v := range obj {
+					if _, secret := auditRedact[k]; secret {
+						v = "***"
+					}
+					params[k] = v
+				}
+			}
+		}
+		if id := r.PathValue("id"); id != "" {
+			params["id"] = id
+		}
+
+		rec := &auditRecorder{ResponseWriter: w}
+		r = r.WithContext(context.WithValue(r.Context(), auditCtxKey{}, params))
+		h(rec, r)
+
+		e := &storage.AuditEntry{
+			Action:   action(r),
+			Params:   params,
+			SourceIP: clientIP(r),
+			Status:   rec.status,
+		}
+		switch {
+		case rec.status == 401 || rec.status == 403:
+			e.Outcome = "denied"
+		case rec.status >= 400:
+			e.Outcome = "failure"
+		default:
+			e.Outcome = "success"
+		}
+		if rec.status >= 400 {
+			e.Error = strings.TrimSpace(rec.errBuf.String())
+		}
+
+		if p := auth.FromContext(r.Context()); p != nil {
+			e.Actor, e.ActorType = p.Username, p.Via
+			if p.UserID != 0 {
+				e.UserID = &p.UserID
+			}
+			if p.TokenID != 0 {
+				e.TokenID = &p.TokenID
+			}
+		} else {
+			e.Actor, e.ActorType = "anonymous", "anonymous"
+			if u, ok := params["username"].(string); ok && u != "" {
+				e.Actor = u // неудачный логин
+			}
+		}
+
+		if err := s.pg.AddAudit(e); err != nil {
+			logger.Errorf("audit %s: %v", e.Action, err)
+		}
+	}
+}
+
+// auditSet — handler добавляет в запись детали, которых нет в теле
+// запроса (раскрытые цели скана, id созданного объекта)
+func auditSet(r *http.Request, key string, v any) {
+	if params, ok := r.Context().Value(auditCtxKey{}).(map[string]any); ok {
+		params[key] = v
+	}
+}
+
+/* ========================= API ========================= */
+
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	f, err := auditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if f.Limit <= 0 || f.Limit > 1000 {
+		f.Limit = 100
+	}
+
+	entries, err := s.pg.ListAudit(f)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	var next int64
+	if len(entries) == f.Limit {
+		next = entries[len(entries)-1].ID
+	}
+	writeJSON(w, 200, map[string]any{"items": entries, "next_before_id": next})
+}
+
+// handleAuditExport — выгрузка для ревью: ?format=csv|ndjson, те же фильтры
+func (s *Server) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	f, err := auditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	name := "audit-" + time.Now().UTC().Format("20060102-150405")
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"id", "ts", "actor", "actor_type", "action", "params", "source_ip", "outcome", "status", "error"})
+		err = s.pg.EachAudit(f, func(e storage.AuditEntry) error {
+			params, _ := json.Marshal(e.Params)
+			return cw.Write(export.CSVRow(
+				strconv.FormatInt(e.ID, 10), e.TS.UTC().Format(time.RFC3339), e.Actor, e.ActorType,
+				e.Action, string(params), e.SourceIP, e.Outcome, strconv.Itoa(e.Status), e.Error,
+			))
+		})
+		cw.Flush()
+
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.ndjson"`)
+		enc := json.NewEncoder(w)
+		err = s.pg.EachAudit(f, func(e storage.AuditEntry) error {
+			return enc.Encode(e)
+		})
+
+	default:
+		http.Error(w, "unknown format "+strconv.Quote(format)+" (csv|ndjson)", 400)
+		return
+	}
+	if err != nil {
+		// заголовки уже отправлены — остаётся только оборвать выгрузку
+		logger.Errorf("audit export: %v", err)
+	}
+}
+
+func auditFilter(r *http.Request) (storage.AuditFilter, error) {
+	q := r.URL.Query()
+	f := storage.AuditFilter{
+		Actor:    q.Get("actor"),
+		Action:   q.Get("action"),
+		Outcome:  q.Get("outcome"),
+		SourceIP: q.Get("ip"),
+	}
+	var err error
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, err
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, err
+		}
+	}
+	if v := q.Get("before_id"); v != "" {
+		if f.BeforeID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, err
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,460 @@
+package webui
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/auth"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+const (
+	sessionCookie = "ps_session"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type passwordRequest struct {
+	Current string `json:"current"`
+	New     string `json:"new"`
+}
+
+type userRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+	Disabled *bool  `json:"disabled"`
+}
+
+/* ========================= MIDDLEWARE ========================= */
+
+// authenticate — определяет principal запроса (cookie-сессия или bearer),
+// но сам ничего не запрещает: это делают require/requireRW на маршрутах
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	legacy := strings.TrimSpace(s.cfg.WebUI.AuthToken)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h := strings.TrimSpace(r.Header.Get("Authorization")); h != "" {
+			if strings.HasPrefix(strings.ToLower(h), "bearer ") {
+				h = strings.TrimSpace(h[7:])
+			}
+			if strings.HasPrefix(h, auth.TokenPrefix) {
+				s.authenticateToken(w, r, h, next)
+				return
+			}
+			if legacy == "" || subtle.ConstantTimeCompare([]byte(h), []byte(legacy)) != 1 {
+				http.Error(w, "invalid token", 401)
+				return
+			}
+			p := &auth.Principal{Username: "legacy-token", Role: auth.RoleAdmin, Via: "legacy"}
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+			return
+		}
+
+		c, err := r.Cookie(sessionCookie)
+		if err != nil || c.Value == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		sess, err := s.pg.GetSession(auth.HashToken(c.Value))
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
+				logger.Errorf("session lookup: %v", err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// cookie уходит браузером автоматически, поэтому изменяющие запросы
+		// должны нести CSRF-токен, который знает только наш JS
+		if !safeMethod(r.Method) && r.URL.Path != "/api/auth/login" {
+			got := r.Header.Get(csrfHeader)
+			if subtle.ConstantTimeCompare([]byte(got), []byte(sess.CSRFToken)) != 1 {
+				http.Error(w, "invalid CSRF token", 403)
+				return
+			}
+		}
+
+		p := &auth.Principal{
+			UserID:   sess.User.ID,
+			Username: sess.User.Username,
+			Role:     auth.Role(sess.User.Role),
+			Via:      "session",
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
+
+// authenticateToken — API-токен: роль по scopes, но не выше роли владельца
+func (s *Server) authenticateToken(w http.ResponseWriter, r *http.Request, secret string, next http.Handler) {
+	t, u, err := s.pg.UseToken(auth.HashToken(secret), clientIP(r))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "invalid, expired or revoked token", 401)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	p := &auth.Principal{
+		UserID:       u.ID,
+		Username:     u.Username,
+		Role:         auth.TokenRole(auth.Role(u.Role), t.Scopes),
+		Via:          "token",
+		TokenID:      t.ID,
+		Scopes:       t.Scopes,
+		AllowedCIDRs: t.AllowedCIDRs,
+	}
+	next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+}
+
+// require — маршрут доступен роли role и выше
+func (s *Server) require(role auth.Role, h http.HandlerFunc) http.HandlerFunc {
+	return s.requireRW(role, role, h)
+}
+
+// requireRW — чтение (GET/HEAD) доступно read, остальные методы — write
+func (s *Server) requireRW(read, write auth.Role, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := auth.FromContext(r.Context())
+		if p == nil {
+			http.Error(w, "authentication required", 401)
+			return
+		}
+		need := write
+		if safeMethod(r.Method) {
+			need = read
+		}
+		if !p.Role.Allows(need) {
+			http.Error(w, "forbidden: "+string(need)+" role required", 403)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func safeMethod(m string) bool {
+	return m == http.MethodGet || m == http.MethodHead || m == http.MethodOptions
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+/* ========================= AUTH ========================= */
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+
+	now := time.Now()
+	ip := clientIP(r)
+	if s.limiter.Blocked("ip:"+ip, now) || s.limiter.Blocked("user:"+req.Username, now) {
+		http.Error(w, "too many failed attempts, try later", 429)
+		return
+	}
+
+	u, err := s.pg.GetUserByName(req.Username)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	if u == nil {
+		auth.CheckDummy(req.Password)
+	}
+	if u == nil || u.Disabled || !auth.CheckPassword(u.PasswordHash, req.Password) {
+		s.limiter.Fail("ip:"+ip, now)
+		s.limiter.Fail("user:"+req.Username, now)
+		logger.Infof("webui login failed for %q from %s", req.Username, ip)
+		http.Error(w, "invalid username or password", 401)
+		return
+	}
+	s.limiter.Reset("ip:" + ip)
+	s.limiter.Reset("user:" + req.Username)
+
+	csrf, err := s.startSession(w, r, u)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"user": u, "csrf_token": csrf})
+}
+
+// startSession — новая сессия и cookie; возвращает CSRF-токен для JS
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, u *storage.User) (string, error) {
+	if err := s.pg.DeleteExpiredSessions(); err != nil {
+		logger.Errorf("purge sessions: %v", err)
+	}
+
+	secret := auth.NewToken()
+	csrf := auth.NewToken()
+	ttl := s.cfg.WebUI.SessionTTL()
+	if err := s.pg.CreateSession(auth.HashToken(secret), u.ID, csrf, ttl, clientIP(r), r.UserAgent()); err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    secret,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   s.cfg.WebUI.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return csrf, nil
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		if err := s.pg.DeleteSession(auth.HashToken(c.Value)); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   s.cfg.WebUI.SecureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	writeJSON(w, 200, map[string]any{"ok": true})
+}
+
+// handleMe — текущий principal; для сессии отдаёт и CSRF-токен
+// (JS держит его в памяти и получает заново после перезагрузки страницы)
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	p := auth.FromContext(r.Context())
+	out := map[string]any{"principal": p}
+
+	if p.Via == "session" {
+		c, err := r.Cookie(sessionCookie)
+		if err != nil {
+			http.Error(w, "authentication required", 401)
+			return
+		}
+		sess, err := s.pg.GetSession(auth.HashToken(c.Value))
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		out["user"] = sess.User
+		out["csrf_token"] = sess.CSRFToken
+	}
+	writeJSON(w, 200, out)
+}
+
+// handleChangePassword — смена своего пароля; остальные сессии завершаются
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	p := auth.FromContext(r.Context())
+	if p.Via != "session" {
+		http.Error(w, "password change requires a user session", 400)
+		return
+	}
+
+	var req passwordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	u, err := s.pg.GetUser(p.UserID)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	if !auth.CheckPassword(u.PasswordHash, req.Current) {
+		http.Error(w, "current password is wrong", 403)
+		return
+	}
+	hash, err := auth.HashPassword(req.New)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	u.PasswordHash = hash
+	if err := s.pg.UpdateUser(u); err != nil {
+		storageError(w, err)
+		return
+	}
+
+	csrf, err := s.startSession(w, r, u)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"user": u, "csrf_token": csrf})
+}
+
+/* ========================= USERS ========================= */
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		users, err := s.pg.ListUsers()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 200, users)
+
+	case http.MethodPost:
+		var req userRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		u := &storage.User{Username: strings.TrimSpace(req.Username)}
+		if u.Username == "" {
+			http.Error(w, "username is required", 400)
+			return
+		}
+		role, err := auth.ParseRole(req.Role)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		u.Role = string(role)
+		if req.Disabled != nil {
+			u.Disabled = *req.Disabled
+		}
+		if u.PasswordHash, err = auth.HashPassword(req.Password); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		if _, err := s.pg.GetUserByName(u.Username); err == nil {
+			http.Error(w, "user already exists", 409)
+			return
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if err := s.pg.CreateUser(u); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 201, u)
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	u, err := s.pg.GetUser(id)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, 200, u)
+
+	case http.MethodPut:
+		var req userRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		wasAdmin := u.Role == string(auth.RoleAdmin) && !u.Disabled
+
+		if req.Role != "" {
+			role, err := auth.ParseRole(req.Role)
+			if err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+			u.Role = string(role)
+		}
+		if req.Disabled != nil {
+			u.Disabled = *req.Disabled
+		}
+		u.PasswordHash = ""
+		if req.Password != "" {
+			if u.PasswordHash, err = auth.HashPassword(req.Password); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+
+		stillAdmin := u.Role == string(auth.RoleAdmin) && !u.Disabled
+		if wasAdmin && !stillAdmin && !s.hasOtherAdmin(w) {
+			return
+		}
+		if err := s.pg.UpdateUser(u); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, u)
+
+	case http.MethodDelete:
+		if u.Role == string(auth.RoleAdmin) && !u.Disabled && !s.hasOtherAdmin(w) {
+			return
+		}
+		if err := s.pg.DeleteUser(id); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"deleted": id})
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+// hasOtherAdmin — последнего активного админа нельзя удалить, понизить или заблокировать
+func (s *Server) hasOtherAdmin(w http.ResponseWriter) bool {
+	n, err := s.pg.CountActiveAdmins()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return false
+	}
+	if n <= 1 {
+		http.Error(w, "cannot remove the last active admin", 409)
+		return false
+	}
+	return true
+}
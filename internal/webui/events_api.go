@@ -0,0 +1,62 @@
+package webui
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// handleDeadEvents — события, которые не удалось доставить за max_attempts
+func (s *Server) handleDeadEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", 400)
+			return
+		}
+		limit = n
+	}
+
+	events, err := s.pg.ListDeadEvents(limit)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, 200, events)
+}
+
+// handleReplayDeadEvents — вернуть в очередь все dead-события
+func (s *Server) handleReplayDeadEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	n, err := s.pg.ReplayDeadEvents()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"replayed": n})
+}
+
+func (s *Server) handleReplayEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err := s.pg.ReplayEvent(id); err != nil {
+		storageError(w, err)
+		return
+	}
+	writeJSON(w, 200, map[string]any{"replayed": id})
+}
@@ -0,0 +1,50 @@
+package webui
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/export"
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// handleExport — выгрузка отфильтрованных находок потоком:
+// ?format=csv|ndjson|xml и те же фильтры, что у /api/results
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	f, err := resultFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	ctype, ext, err := export.ContentType(format)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	f.Sort = export.SortFor(format, f.Sort)
+	if err := storage.CheckResultFilter(f); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("Content-Disposition", `attachment; filename="findings-`+time.Now().UTC().Format("20060102-150405")+`.`+ext+`"`)
+
+	ew, err := export.NewWriter(w, format, "portscanner export "+r.URL.RawQuery)
+	if err == nil {
+		err = s.pg.EachResult(f, ew.Write)
+	}
+	if err == nil {
+		err = ew.Close()
+	}
+	if err != nil {
+		// заголовки уже отправлены — остаётся только оборвать выгрузку
+		logger.Errorf("export: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package webui
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strconv"
+
+	"github.com/L1nMay/portscanner/internal/banner"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// hostPort — порт карточки хоста с разобранным HTTP-баннером
+type hostPort struct {
+	storage.HostPort
+	HTTP *banner.HTTPInfo `json:"http,omitempty"`
+}
+
+// handleHosts — список хостов со счётчиками портов и атрибутами;
+// ответ {items, total, next_cursor}
+func (s *Server) handleHosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	q := r.URL.Query()
+	f := storage.HostFilter{
+		CIDR:     q.Get("cidr"),
+		Tag:      q.Get("tag"),
+		Group:    q.Get("group"),
+		OSFamily: q.Get("os"),
+		Cursor:   q.Get("cursor"),
+	}
+	var err error
+	if v := q.Get("port"); v != "" {
+		if f.Port, err = strconv.Atoi(v); err != nil || f.Port <= 0 || f.Port > 65535 {
+			http.Error(w, "invalid port", 400)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid limit", 400)
+			return
+		}
+	}
+
+	page, err := s.pg.ListHosts(f)
+	if errors.Is(err, storage.ErrInvalidFilter) {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, 200, page)
+}
+
+// handleHost — карточка хоста: обогащение, текущие и закрытые порты,
+// история наблюдений по сканам, события и статус политик
+func (s *Server) handleHost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	addr, err := netip.ParseAddr(r.PathValue("ip"))
+	if err != nil {
+		http.Error(w, "invalid ip", 400)
+		return
+	}
+
+	d, err := s.pg.GetHost(addr.String())
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+
+	ports := make([]hostPort, 0, len(d.Ports))
+	for _, p := range d.Ports {
+		ports = append(ports, hostPort{HostPort: p, HTTP: banner.ParseHTTP(p.Banner)})
+	}
+	writeJSON(w, 200, struct {
+		*storage.HostDetail
+		Ports []hostPort `json:"ports"`
+	}{d, ports})
+}
@@ -0,0 +1,69 @@
+package webui
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/auth"
+	"github.com/L1nMay/portscanner/internal/scan"
+)
+
+// maxImportSize — предел загружаемого файла
+const maxImportSize = 64 << 20
+
+// handleImport — загрузка nmap XML / masscan JSON / masscan -oL:
+// multipart (поле file) или сырое тело; ?format= — иначе по содержимому
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	var (
+		src  io.Reader = r.Body
+		name           = r.URL.Query().Get("name")
+	)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, hdr, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		defer file.Close()
+		src = file
+		if name == "" {
+			name = hdr.Filename
+		}
+	}
+
+	opts := scan.ImportOptions{Format: r.URL.Query().Get("format"), Name: name}
+	if p := auth.FromContext(r.Context()); p != nil {
+		opts.Scope = p.AllowedCIDRs
+	}
+	auditSet(r, "format", opts.Format)
+	auditSet(r, "name", name)
+
+	run, err := s.runner.ImportCtx(r.Context(), src, opts)
+	var tooBig *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooBig):
+		http.Error(w, "file too large", 413)
+		return
+	case errors.Is(err, scan.ErrBadImport):
+		http.Error(w, err.Error(), 400)
+		return
+	case errors.Is(err, scan.ErrImportScope):
+		http.Error(w, err.Error(), 403)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	auditSet(r, "scan_id", run.ID)
+	auditSet(r, "found", run.Found)
+	writeJSON(w, 200, run)
+}
@@ -0,0 +1,178 @@
+package webui
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/metrics"
+	"github.com/L1nMay/portscanner/internal/model"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec(
+		"portscanner_http_requests_total",
+		"HTTP requests by method, route and status code.",
+		"method", "route", "code",
+	)
+	httpDuration = metrics.NewHistogramVec(
+		"portscanner_http_request_duration_seconds",
+		"HTTP request latency by method and route.",
+		metrics.DefBuckets,
+		"method", "route",
+	)
+
+	collectorsOnce sync.Once
+)
+
+// registerCollectors — метрики, которые считаются запросом в БД при scrape;
+// регистрируются один раз на процесс
+func registerCollectors(pg *storage.Postgres, scanName string) {
+	collectorsOnce.Do(func() {
+		metrics.RegisterCollector("gauge", "portscanner_open_ports",
+			"Open ports by service.",
+			func() ([]metrics.Sample, error) {
+				list, err := pg.OpenPortsByService()
+				if err != nil {
+					return nil, err
+				}
+				out := make([]metrics.Sample, 0, len(list))
+				for _, c := range list {
+					out = append(out, metrics.Sample{Labels: []string{c.Service}, Value: float64(c.Count)})
+				}
+				return out, nil
+			}, "service")
+
+		metrics.RegisterCollector("gauge", "portscanner_event_queue_depth",
+			"Events waiting for delivery (state=pending) and dead-lettered (state=dead).",
+			func() ([]metrics.Sample, error) {
+				pending, dead, err := pg.EventQueueDepth()
+				if err != nil {
+					return nil, err
+				}
+				return []metrics.Sample{
+					{Labels: []string{"pending"}, Value: float64(pending)},
+					{Labels: []string{"dead"}, Value: float64(dead)},
+				}, nil
+			}, "state")
+
+		// schedule: свои сканы — под именем scan_name, импорт — "import"
+		metrics.RegisterCollector("gauge", "portscanner_last_successful_scan_timestamp_seconds",
+			"Unix time of the last successfully finished scan per schedule.",
+			func() ([]metrics.Sample, error) {
+				last, err := pg.LastSuccessfulScans()
+				if err != nil {
+					return nil, err
+				}
+				out := make([]metrics.Sample, 0, len(last))
+				for source, at := range last {
+					schedule := source
+					if source == model.SourceScan {
+						schedule = scanName
+					}
+					out = append(out, metrics.Sample{Labels: []string{schedule}, Value: float64(at.Unix())})
+				}
+				return out, nil
+			}, "schedule")
+	})
+}
+
+// handleMetrics — /metrics в формате Prometheus; при webui.metrics_token
+// требуется bearer с этим токеном
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	if want := strings.TrimSpace(s.cfg.WebUI.MetricsToken); want != "" {
+		got := strings.TrimSpace(r.Header.Get("Authorization"))
+		if strings.HasPrefix(strings.ToLower(got), "bearer ") {
+			got = strings.TrimSpace(got[7:])
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			http.Error(w, "invalid token", 401)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	if err := metrics.Default.Write(w); err != nil {
+		logger.Errorf("metrics write: %v", err)
+	}
+}
+
+/* ========================= HTTP METRICS ========================= */
+
+// statusRecorder — код ответа для метрик и трассировки; Flush нужен SSE-потокам
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (m *statusRecorder) WriteHeader(code int) {
+	if m.status == 0 {
+		m.status = code
+	}
+	m.ResponseWriter.WriteHeader(code)
+}
+
+func (m *statusRecorder) Write(b []byte) (int, error) {
+	if m.status == 0 {
+		m.status = 200
+	}
+	return m.ResponseWriter.Write(b)
+}
+
+func (m *statusRecorder) Flush() {
+	if f, ok := m.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func withMetrics(routeName routeFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = 200
+		}
+		route := routeName(r)
+		httpRequests.Inc(r.Method, route, strconv.Itoa(rec.status))
+		httpDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// routeFunc — метка маршрута запроса для метрик и спанов
+type routeFunc func(*http.Request) string
+
+// routeOf — зарегистрированный шаблон маршрута (/api/hosts/{ip}), а не сам путь:
+// число серий ограничено числом маршрутов. Запросы мимо маршрутов (в том числе
+// без авторизации — middleware стоит до неё) сводятся в "other" и "/api/other".
+func routeOf(mux, api *http.ServeMux) routeFunc {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		switch pattern {
+		case "/api/":
+			if _, pattern = api.Handler(r); pattern == "" {
+				return "/api/other"
+			}
+			return pattern
+		case "/":
+			// «/» в ServeMux ловит всё, что не совпало с остальным
+			if r.URL.Path != "/" {
+				return "other"
+			}
+		case "":
+			return "other"
+		}
+		return pattern
+	}
+}
@@ -0,0 +1,105 @@
+package webui
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/L1nMay/portscanner/internal/policy"
+)
+
+func (s *Server) handlePolicies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		pols, err := s.pg.ListPolicies(false)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 200, pols)
+
+	case http.MethodPost:
+		pol, err := decodePolicy(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err := s.pg.CreatePolicy(pol); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 201, pol)
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func (s *Server) handlePolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		pol, err := s.pg.GetPolicy(id)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, pol)
+
+	case http.MethodPut:
+		pol, err := decodePolicy(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		pol.ID = id
+		if err := s.pg.UpdatePolicy(pol); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, pol)
+
+	case http.MethodDelete:
+		if err := s.pg.DeletePolicy(id); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"deleted": id})
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+// handlePolicyEvaluate — перепроверка всех находок против текущих политик
+func (s *Server) handlePolicyEvaluate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	checked, violating, err := s.runner.EvaluatePolicies()
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	writeJSON(w, 200, map[string]any{
+		"checked":   checked,
+		"violating": violating,
+	})
+}
+
+func decodePolicy(r *http.Request) (*policy.Policy, error) {
+	// enabled по умолчанию true, если поле не передали
+	pol := policy.Policy{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&pol); err != nil {
+		return nil, err
+	}
+	if err := pol.Normalize(); err != nil {
+		return nil, err
+	}
+	return &pol, nil
+}
@@ -0,0 +1,106 @@
+package webui
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/auth"
+	"github.com/L1nMay/portscanner/internal/report"
+)
+
+// handleReports — GET: список отчётов; POST: сгенерировать и сохранить
+// ({"from": "7d"|RFC3339, "to": ...}; from по умолчанию — конец прошлого отчёта)
+func (s *Server) handleReports(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		limit := 50
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 500 {
+				http.Error(w, "invalid limit", 400)
+				return
+			}
+			limit = n
+		}
+		list, err := s.pg.ListReports(limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case http.MethodPost:
+		var req struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+
+		now := time.Now()
+		opts := report.Options{Kind: "manual", Save: true}
+		var err error
+		if req.From != "" {
+			if opts.From, err = parseTimeParam(req.From, now); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+		if req.To != "" {
+			if opts.To, err = parseTimeParam(req.To, now); err != nil {
+				http.Error(w, err.Error(), 400)
+				return
+			}
+		}
+		if !opts.From.IsZero() && !opts.To.IsZero() && !opts.From.Before(opts.To) {
+			http.Error(w, "from must be before to", 400)
+			return
+		}
+		if p := auth.FromContext(r.Context()); p != nil {
+			opts.CreatedBy = p.Username
+		}
+
+		rep, _, err := report.Generate(s.pg, opts)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditSet(r, "report_id", rep.ID)
+		writeJSON(w, 201, rep)
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+// handleReport — HTML отчёта; ?download=1 — как вложение
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	rep, html, err := s.pg.GetReport(id)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// отчёт — документ, а не часть UI: без скриптов и внешних ресурсов
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	if r.URL.Query().Get("download") != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+report.FileName(rep)+`"`)
+	}
+	_, _ = io.WriteString(w, html)
+}
@@ -0,0 +1,99 @@
+package webui
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+const (
+	scanLogDefaultLimit = 1000
+	scanLogMaxLimit     = 5000
+)
+
+// handleScanLog — журнал скана: ?after=<id> для дочитывания, ?limit=,
+// ?format=text — plain text. JSON: {scan, lines, next_after}
+func (s *Server) handleScanLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		http.Error(w, "invalid scan id", 400)
+		return
+	}
+
+	q := r.URL.Query()
+	var after int64
+	if v := q.Get("after"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid after", 400)
+			return
+		}
+		after = n
+	}
+	limit := scanLogDefaultLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", 400)
+			return
+		}
+		limit = min(n, scanLogMaxLimit)
+	}
+
+	run, err := s.pg.GetScanRun(id)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	lines, err := s.pg.ScanLog(id, after, limit)
+	if err != nil {
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	next := after
+	if len(lines) > 0 {
+		next = lines[len(lines)-1].ID
+	}
+
+	if q.Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, l := range lines {
+			fmt.Fprintf(w, "%s %-5s [%s] %s\n", l.Time.Format(time.RFC3339), l.Level, l.Source, l.Message)
+		}
+		return
+	}
+
+	writeJSON(w, 200, struct {
+		Scan      *storage.ScanRun      `json:"scan"`
+		Lines     []storage.ScanLogLine `json:"lines"`
+		NextAfter int64                 `json:"next_after"`
+	}{run, lines, next})
+}
+
+// isUUID — 8-4-4-4-12 из hex-цифр
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, c := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
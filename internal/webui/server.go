@@ -1,12 +1,18 @@
 package webui
 
 import (
+	"crypto/rand"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/L1nMay/portscanner/internal/auth"
 	"github.com/L1nMay/portscanner/internal/config"
 	"github.com/L1nMay/portscanner/internal/envdetect"
 	"github.com/L1nMay/portscanner/internal/logger"
@@ -21,18 +27,26 @@ type Server struct {
 	pg     *storage.Postgres
 	runner *scan.Runner
 	cfg    *config.Config
+
+	limiter *auth.LoginLimiter
 }
 
 type ScanRequest struct {
 	Targets []string `json:"targets"`
 	Ports   string   `json:"ports"`
+	Group   string   `json:"group,omitempty"` // asset-группа, эквивалент цели "group:<name>"
+	DryRun  bool     `json:"dry_run,omitempty"`
 }
 
 func NewServer(cfg *config.Config, pg *storage.Postgres, runner *scan.Runner) *Server {
+	registerCollectors(pg, cfg.ScanName)
+
 	return &Server{
 		cfg:    cfg,
 		pg:     pg,
 		runner: runner,
+
+		limiter: auth.NewLoginLimiter(5, 15*time.Minute),
 	}
 }
 
@@ -52,23 +66,67 @@ func (s *Server) Handler() http.Handler {
 		})
 	})
 
-	// ---------- SSE ----------
-	mux.HandleFunc("/api/scan/stream", s.handleStream)
+	// ---------- Metrics ----------
+	// вне /api: Prometheus ходит без сессии, доступ — webui.metrics_token
+	mux.HandleFunc("/metrics", s.handleMetrics)
 
 	// ---------- API ----------
+	// viewer — чтение, operator — запуск/отмена сканов, admin — настройки и пользователи
+	const (
+		viewer   = auth.RoleViewer
+		operator = auth.RoleOperator
+		admin    = auth.RoleAdmin
+	)
 	api := http.NewServeMux()
-	api.HandleFunc("/api/stats", s.handleStats)
-	api.HandleFunc("/api/results", s.handleResults)
-	api.HandleFunc("/api/scans", s.handleScans)
-	api.HandleFunc("/api/netinfo", s.handleNetinfo)
-	api.HandleFunc("/api/scan", s.handleScan)
-	api.HandleFunc("/api/scan/custom", s.handleCustomScan)
-	api.HandleFunc("/api/scan/cancel", s.handleCancel)
-	api.HandleFunc("/api/scan/plan", s.handlePlan)
-
-	mux.Handle("/api/", withAuth(s.cfg, api))
-
-	return withCORS(withLogging(mux))
+	api.HandleFunc("/api/auth/login", s.audit("auth.login", s.handleLogin))
+	api.HandleFunc("/api/auth/logout", s.audit("auth.logout", s.handleLogout))
+	api.HandleFunc("/api/auth/me", s.require(viewer, s.handleMe))
+	api.HandleFunc("/api/auth/password", s.audit("auth.password", s.require(viewer, s.handleChangePassword)))
+	api.HandleFunc("/api/users", s.auditCRUD("user", s.require(admin, s.handleUsers)))
+	api.HandleFunc("/api/users/{id}", s.auditCRUD("user", s.require(admin, s.handleUser)))
+	api.HandleFunc("/api/tokens", s.audit("token.create", s.require(viewer, s.handleTokens)))
+	api.HandleFunc("/api/audit", s.require(admin, s.handleAudit))
+	api.HandleFunc("/api/audit/export", s.require(admin, s.handleAuditExport))
+	api.HandleFunc("/api/tokens/{id}", s.audit("token.revoke", s.require(viewer, s.handleToken)))
+
+	api.HandleFunc("/api/scan/stream", s.require(viewer, s.handleStream))
+	api.HandleFunc("/api/stats", s.require(viewer, s.handleStats))
+	api.HandleFunc("/api/results", s.require(viewer, s.handleResults))
+	api.HandleFunc("/api/export", s.require(viewer, s.handleExport))
+	api.HandleFunc("/api/scans", s.require(viewer, s.handleScans))
+	api.HandleFunc("/api/scans/{id}/log", s.require(viewer, s.handleScanLog))
+	api.HandleFunc("/api/scans/{id}/stream", s.require(viewer, s.handleScanStream))
+	api.HandleFunc("/api/hosts", s.require(viewer, s.handleHosts))
+	api.HandleFunc("/api/hosts/{ip}", s.require(viewer, s.handleHost))
+	api.HandleFunc("/api/reports", s.audit("report.create", s.requireRW(viewer, operator, s.handleReports)))
+	api.HandleFunc("/api/reports/{id}", s.require(viewer, s.handleReport))
+	api.HandleFunc("/api/netinfo", s.require(viewer, s.handleNetinfo))
+	api.HandleFunc("/api/scan", s.audit("scan.start", s.require(operator, s.handleScan)))
+	api.HandleFunc("/api/scan/custom", s.audit("scan.start", s.require(operator, s.handleCustomScan)))
+	api.HandleFunc("/api/import", s.audit("scan.import", s.require(operator, s.handleImport)))
+	api.HandleFunc("/api/scan/cancel", s.audit("scan.cancel", s.require(operator, s.handleCancel)))
+	api.HandleFunc("/api/scan/plan", s.require(viewer, s.handlePlan))
+	api.HandleFunc("/api/assets/groups", s.auditCRUD("asset_group", s.requireRW(viewer, admin, s.handleAssetGroups)))
+	api.HandleFunc("/api/assets/groups/{id}", s.auditCRUD("asset_group", s.requireRW(viewer, admin, s.handleAssetGroup)))
+	api.HandleFunc("/api/assets/tags", s.auditCRUD("asset_tag", s.requireRW(viewer, admin, s.handleAssetTags)))
+	api.HandleFunc("/api/assets/tags/{id}", s.auditCRUD("asset_tag", s.requireRW(viewer, admin, s.handleAssetTag)))
+	api.HandleFunc("/api/policies", s.auditCRUD("policy", s.requireRW(viewer, admin, s.handlePolicies)))
+	api.HandleFunc("/api/policies/evaluate", s.audit("policy.evaluate", s.require(operator, s.handlePolicyEvaluate)))
+	api.HandleFunc("/api/policies/{id}", s.auditCRUD("policy", s.requireRW(viewer, admin, s.handlePolicy)))
+	api.HandleFunc("/api/alert-rules", s.auditCRUD("alert_rule", s.requireRW(viewer, admin, s.handleAlertRules)))
+	api.HandleFunc("/api/alert-rules/{id}", s.auditCRUD("alert_rule", s.requireRW(viewer, admin, s.handleAlertRule)))
+	api.HandleFunc("/api/templates", s.auditCRUD("template", s.requireRW(viewer, admin, s.handleTemplates)))
+	api.HandleFunc("/api/templates/defaults", s.require(viewer, s.handleTemplateDefaults))
+	api.HandleFunc("/api/templates/preview", s.require(admin, s.handleTemplatePreview))
+	api.HandleFunc("/api/templates/{id}", s.auditCRUD("template", s.requireRW(viewer, admin, s.handleTemplate)))
+	api.HandleFunc("/api/events/dead", s.require(admin, s.handleDeadEvents))
+	api.HandleFunc("/api/events/dead/replay", s.audit("event.replay", s.require(admin, s.handleReplayDeadEvents)))
+	api.HandleFunc("/api/events/{id}/replay", s.audit("event.replay", s.require(admin, s.handleReplayEvent)))
+
+	mux.Handle("/api/", s.authenticate(api))
+
+	route := routeOf(mux, api)
+	return withCORS(withRequestID(withTracing(route, withMetrics(route, withLogging(mux)))))
 }
 
 /* ========================= HANDLERS ========================= */
@@ -82,13 +140,101 @@ func (s *Server) handleStats(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, 200, st)
 }
 
-func (s *Server) handleResults(w http.ResponseWriter, _ *http.Request) {
-	res, err := s.pg.ListResults()
+// handleResults — фильтры, сортировка и курсорная пагинация;
+// ответ {items, total, next_cursor}
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	f, err := resultFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	page, err := s.pg.ListResults(f)
+	if errors.Is(err, storage.ErrInvalidFilter) {
+		http.Error(w, err.Error(), 400)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
-	writeJSON(w, 200, res)
+	writeJSON(w, 200, page)
+}
+
+func resultFilter(r *http.Request) (storage.ResultFilter, error) {
+	q := r.URL.Query()
+	f := storage.ResultFilter{
+		Query:    q.Get("q"),
+		IP:       q.Get("ip"),
+		CIDR:     q.Get("cidr"),
+		Ports:    q.Get("ports"),
+		Proto:    q.Get("proto"),
+		Service:  q.Get("service"),
+		Banner:   q.Get("banner"),
+		OSFamily: q.Get("os"),
+		Tag:      q.Get("tag"),
+		Group:    q.Get("group"),
+
+		Compliance: q.Get("compliance"),
+		State:      q.Get("state"),
+
+		Sort:   q.Get("sort"),
+		Cursor: q.Get("cursor"),
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return f, errors.New("invalid limit")
+		}
+		f.Limit = n
+	}
+
+	// since: since_cursor прошлого ответа, RFC3339 или давность (10m)
+	if v := strings.TrimSpace(q.Get("since")); v != "" {
+		if ts, err := parseTimeParam(v, time.Now()); err == nil {
+			v = ts.UTC().Format(time.RFC3339Nano)
+		}
+		f.Since = v
+	}
+
+	times := []struct {
+		name string
+		dst  *time.Time
+	}{
+		{"first_seen_from", &f.FirstSeenFrom},
+		{"first_seen_to", &f.FirstSeenTo},
+		{"last_seen_from", &f.LastSeenFrom},
+		{"last_seen_to", &f.LastSeenTo},
+	}
+	for _, t := range times {
+		if v := q.Get(t.name); v != "" {
+			ts, err := parseTimeParam(v, time.Now())
+			if err != nil {
+				return f, fmt.Errorf("%s: %w", t.name, err)
+			}
+			*t.dst = ts
+		}
+	}
+	return f, nil
+}
+
+// parseTimeParam — RFC3339 или давность: "7d", "12h", "30m" (= now - d)
+func parseTimeParam(v string, now time.Time) (time.Time, error) {
+	if ts, err := time.Parse(time.RFC3339, v); err == nil {
+		return ts, nil
+	}
+	if n, ok := strings.CutSuffix(v, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil || days < 0 {
+			return time.Time{}, fmt.Errorf("invalid time %q", v)
+		}
+		return now.Add(-time.Duration(days) * 24 * time.Hour), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("invalid time %q (RFC3339 or age like 7d, 12h)", v)
+	}
+	return now.Add(-d), nil
 }
 
 func (s *Server) handleScans(w http.ResponseWriter, _ *http.Request) {
@@ -99,28 +245,37 @@ func (s *Server) handleScans(w http.ResponseWriter, _ *http.Request) {
 	}
 
 	type scanRow struct {
-		ID        string `json:"id"`
-		StartedAt string `json:"started_at"`
-		Engine    string `json:"engine"`
-		Targets   string `json:"targets"`
-		Ports     string `json:"ports"`
-		Found     int    `json:"found"`
-		New       int    `json:"new"`
-		Status    string `json:"status"`
+		ID         string `json:"id"`
+		StartedAt  string `json:"started_at"`
+		FinishedAt string `json:"finished_at,omitempty"`
+		Engine     string `json:"engine"`
+		Source     string `json:"source"`
+		Targets    string `json:"targets"`
+		Ports      string `json:"ports"`
+		Found      int    `json:"found"`
+		New        int    `json:"new"`
+		Status     string `json:"status"`
+		Error      string `json:"error,omitempty"`
 	}
 
 	out := make([]scanRow, 0, len(runs))
 	for _, r := range runs {
-		out = append(out, scanRow{
+		row := scanRow{
 			ID:        r.ID,
 			StartedAt: r.StartedAt.Format(time.RFC3339),
 			Engine:    r.Engine,
+			Source:    r.Source,
 			Targets:   r.Targets,
 			Ports:     r.Ports,
 			Found:     r.Found,
 			New:       r.NewFound,
 			Status:    r.Status,
-		})
+			Error:     r.Error,
+		}
+		if r.FinishedAt != nil {
+			row.FinishedAt = r.FinishedAt.Format(time.RFC3339)
+		}
+		out = append(out, row)
 	}
 
 	writeJSON(w, 200, out)
@@ -142,8 +297,20 @@ func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
 	}
 	cfg := *s.cfg
 	cfg.UserDefined = false
-	s.runner.RunAsync(&cfg)
-	writeJSON(w, 200, map[string]any{"status": "started"})
+	if p := auth.FromContext(r.Context()); p != nil && len(p.AllowedCIDRs) > 0 {
+		targets, err := s.runner.ExpandTargets(cfg.Targets)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err := checkTargetScope(p, targets); err != nil {
+			http.Error(w, err.Error(), 403)
+			return
+		}
+	}
+	auditSet(r, "targets", cfg.Targets)
+	auditSet(r, "ports", cfg.Ports)
+	s.startScan(w, r, &cfg, dryRun(r))
 }
 
 func (s *Server) handleCustomScan(w http.ResponseWriter, r *http.Request) {
@@ -158,8 +325,18 @@ func (s *Server) handleCustomScan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	targets := req.Targets
+	if g := strings.TrimSpace(req.Group); g != "" {
+		targets = append(targets, scan.GroupTargetPrefix+g)
+	}
+	targets, err := s.runner.ExpandTargets(targets)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
 	cfg := *s.cfg
-	cfg.Targets = req.Targets
+	cfg.Targets = targets
 	cfg.Ports = req.Ports
 	cfg.UserDefined = true
 
@@ -167,9 +344,37 @@ func (s *Server) handleCustomScan(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), 403)
 		return
 	}
+	if err := checkTargetScope(auth.FromContext(r.Context()), cfg.Targets); err != nil {
+		http.Error(w, err.Error(), 403)
+		return
+	}
+	auditSet(r, "targets", cfg.Targets)
 
-	s.runner.RunAsync(&cfg)
-	writeJSON(w, 200, map[string]any{"status": "started"})
+	s.startScan(w, r, &cfg, req.DryRun || dryRun(r))
+}
+
+// startScan — асинхронный запуск; при dry-run — только план с оценкой
+func (s *Server) startScan(w http.ResponseWriter, r *http.Request, cfg *config.Config, dry bool) {
+	if dry {
+		plan, err := s.runner.Plan(cfg)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		auditSet(r, "dry_run", true)
+		writeJSON(w, 200, map[string]any{"status": "planned", "plan": plan})
+		return
+	}
+
+	id := s.runner.RunAsyncCtx(r.Context(), cfg)
+	auditSet(r, "scan_id", id)
+	writeJSON(w, 200, map[string]any{"status": "started", "scan_id": id})
+}
+
+// dryRun — ?dry_run=1|true
+func dryRun(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return v
 }
 
 func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
@@ -177,7 +382,25 @@ func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", 405)
 		return
 	}
-	ok := s.runner.CancelRunning()
+	id, targets, running := s.runner.Running()
+	if !running {
+		writeJSON(w, 200, map[string]any{"cancelled": false})
+		return
+	}
+	// токен с allowed_cidrs отменяет только то, что мог бы запустить сам
+	if p := auth.FromContext(r.Context()); p != nil && len(p.AllowedCIDRs) > 0 {
+		expanded, err := s.runner.ExpandTargets(targets)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if err := checkTargetScope(p, expanded); err != nil {
+			http.Error(w, err.Error(), 403)
+			return
+		}
+	}
+	auditSet(r, "scan_id", id)
+	ok := s.runner.CancelScan(id)
 	writeJSON(w, 200, map[string]any{"cancelled": ok})
 }
 
@@ -190,37 +413,6 @@ func (s *Server) handlePlan(w http.ResponseWriter, _ *http.Request) {
 	writeJSON(w, 200, plan)
 }
 
-func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "stream unsupported", 500)
-		return
-	}
-
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("X-Accel-Buffering", "no")
-
-	_, _ = w.Write([]byte(": ok\n\n"))
-	flusher.Flush()
-
-	ch := s.runner.HubSubscribe()
-	defer s.runner.HubUnsubscribe(ch)
-
-	for {
-		select {
-		case <-r.Context().Done():
-			return
-		case b := <-ch:
-			_, _ = w.Write([]byte("data: "))
-			_, _ = w.Write(b)
-			_, _ = w.Write([]byte("\n\n"))
-			flusher.Flush()
-		}
-	}
-}
-
 /* ========================= HELPERS ========================= */
 
 func serveAsset(path, ctype string) http.HandlerFunc {
@@ -235,41 +427,50 @@ func serveAsset(path, ctype string) http.HandlerFunc {
 	}
 }
 
-func withAuth(cfg *config.Config, next http.Handler) http.Handler {
-	token := strings.TrimSpace(cfg.WebUI.AuthToken)
-	if token == "" {
-		return next
-	}
-
+func withLogging(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		h := strings.TrimSpace(r.Header.Get("Authorization"))
-		if h == "" {
-			http.Error(w, "missing Authorization header", 401)
-			return
-		}
-		if strings.HasPrefix(strings.ToLower(h), "bearer ") {
-			h = strings.TrimSpace(h[7:])
-		}
-		if h != token {
-			http.Error(w, "invalid token", 403)
-			return
-		}
+		logger.Info(r.Context(), "webui request", "method", r.Method, "path", r.URL.Path)
 		next.ServeHTTP(w, r)
 	})
 }
 
-func withLogging(next http.Handler) http.Handler {
+// withRequestID — X-Request-ID из запроса (если разумный) или новый;
+// возвращается в ответе и попадает во все логи запроса как request_id
+func withRequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		logger.Infof("webui %s %s", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(logger.With(r.Context(), "request_id", id)))
 	})
 }
 
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-CSRF-Token, X-Request-ID, traceparent")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, traceparent")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(204)
 			return
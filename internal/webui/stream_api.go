@@ -0,0 +1,203 @@
+package webui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/model"
+	"github.com/L1nMay/portscanner/internal/scan"
+)
+
+const sseKeepAlive = 15 * time.Second
+
+// handleStream — прежний общий поток: только прогресс всех сканов
+// безымянными событиями (onmessage); с Last-Event-ID дочитывает пропущенное
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := startSSE(w)
+	if !ok {
+		return
+	}
+
+	// без Last-Event-ID — только новые события, без истории прошлых сканов
+	after, ok := lastEventID(r)
+	if !ok {
+		after = math.MaxUint64
+	}
+	sub, replay, _ := s.runner.Subscribe("", after)
+	defer s.runner.Unsubscribe(sub)
+
+	for _, e := range replay {
+		if e.Type == scan.EventProgress {
+			writeSSE(w, e, false)
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				return // отстал — клиент переподключится с Last-Event-ID
+			}
+			if e.Type != scan.EventProgress {
+				continue
+			}
+			writeSSE(w, e, false)
+			flusher.Flush()
+		}
+	}
+}
+
+// handleScanStream — события одного скана: progress, phase, log, finding,
+// done. Без Last-Event-ID отдаёт весь буфер скана (подключение посреди
+// скана получает текущее состояние); после done поток закрывается.
+// reset — часть событий уже вытеснена из буфера; status — скан в БД
+// running, но в этом процессе его нет (идёт в другом экземпляре или строка
+// осталась от рестарта): событий не будет, поток закрывается.
+func (s *Server) handleScanStream(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		http.Error(w, "invalid scan id", 400)
+		return
+	}
+	after, _ := lastEventID(r)
+
+	// скан не из этого процесса или уже вытеснен из буфера — итог из БД
+	if known, _ := s.runner.StreamState(id); !known {
+		run, err := s.pg.GetScanRun(id)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		flusher, ok := startSSE(w)
+		if !ok {
+			return
+		}
+		d := scan.Done{
+			Status:  run.Status,
+			Message: "Scan " + run.Status,
+			Error:   run.Error,
+			Found:   run.Found,
+			New:     run.NewFound,
+		}
+		typ := scan.EventDone
+		if run.Status == model.ScanRunning {
+			typ, d.Message = scan.EventStatus, "Scan is not running in this instance, live events unavailable"
+		}
+		writeSSEData(w, typ, d)
+		flusher.Flush()
+		return
+	}
+
+	sub, replay, gap := s.runner.Subscribe(id, after)
+	defer s.runner.Unsubscribe(sub)
+
+	// переподключение после done: 204 останавливает EventSource
+	if _, done := s.runner.StreamState(id); done && len(replay) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	flusher, ok := startSSE(w)
+	if !ok {
+		return
+	}
+	if gap {
+		writeSSEData(w, scan.EventReset, scan.Reset{Message: "some scan events were dropped from the buffer"})
+	}
+	for _, e := range replay {
+		writeSSE(w, e, true)
+		if e.Type == scan.EventDone {
+			flusher.Flush()
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": ping\n\n")
+			flusher.Flush()
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			writeSSE(w, e, true)
+			flusher.Flush()
+			if e.Type == scan.EventDone {
+				return
+			}
+		}
+	}
+}
+
+/* ========================= SSE ========================= */
+
+func startSSE(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "stream unsupported", 500)
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	_, _ = io.WriteString(w, "retry: 2000\n: ok\n\n")
+	flusher.Flush()
+	return flusher, true
+}
+
+// lastEventID — Last-Event-ID (EventSource шлёт его сам при переподключении)
+// или ?last_event_id= для первого подключения
+func lastEventID(r *http.Request) (uint64, bool) {
+	v := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// writeSSE — событие с id; typed — с полем event (иначе придёт в onmessage)
+func writeSSE(w io.Writer, e scan.Event, typed bool) {
+	if typed {
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.ID, e.Data)
+}
+
+// writeSSEData — событие без id (не из буфера Hub)
+func writeSSEData(w io.Writer, typ string, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typ, b)
+}
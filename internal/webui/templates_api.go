@@ -0,0 +1,193 @@
+package webui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/L1nMay/portscanner/internal/notifier"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// handleTemplates — пользовательские шаблоны уведомлений
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := s.pg.ListNotificationTemplates()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 200, list)
+
+	case http.MethodPost:
+		t, err := decodeTemplate(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		if err := s.pg.SaveNotificationTemplate(t); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 201, t)
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func (s *Server) handleTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		t, err := s.pg.GetNotificationTemplate(id)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, t)
+
+	case http.MethodPut:
+		t, err := decodeTemplate(r)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		t.ID = id
+		if err := s.pg.UpdateNotificationTemplate(t); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, t)
+
+	case http.MethodDelete:
+		if err := s.pg.DeleteNotificationTemplate(id); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"deleted": id})
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+// handleTemplateDefaults — встроенные шаблоны (отправная точка для своих)
+func (s *Server) handleTemplateDefaults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+	types := notifier.EventTypes()
+	out := make(map[string]notifier.Template, len(types))
+	for _, t := range types {
+		tpl, _ := notifier.NewTemplates().Lookup(t, "", "")
+		out[t] = tpl
+	}
+	writeJSON(w, 200, out)
+}
+
+type templatePreviewRequest struct {
+	EventType string         `json:"event_type"`
+	Channel   string         `json:"channel"` // имя канала или его тип; пусто — plain
+	Subject   string         `json:"subject"`
+	Body      string         `json:"body"`    // пусто — действующий шаблон
+	Payload   map[string]any `json:"payload"` // пусто — пример события
+}
+
+// handleTemplatePreview — рендер шаблона на примере события
+func (s *Server) handleTemplatePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", 405)
+		return
+	}
+
+	var req templatePreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if req.EventType == "" {
+		http.Error(w, "event_type is required", 400)
+		return
+	}
+
+	chName, chType := s.resolveChannel(req.Channel)
+
+	ev := notifier.SampleEvent(req.EventType)
+	if len(req.Payload) > 0 {
+		ev.Payload = req.Payload
+	}
+
+	var (
+		tpl    notifier.Template
+		source = "request"
+	)
+	if strings.TrimSpace(req.Body) != "" {
+		tpl = notifier.Template{Subject: req.Subject, Body: req.Body}
+		if tpl.Subject == "" {
+			def, _ := notifier.NewTemplates().Lookup(req.EventType, "", "")
+			tpl.Subject = def.Subject
+		}
+	} else {
+		list, err := s.pg.ListNotificationTemplates()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		ts := notifier.NewTemplates()
+		ts.SetOverrides(list)
+		tpl, source = ts.Lookup(req.EventType, chName, chType)
+	}
+
+	format := notifier.FormatFor(chType)
+	msg, err := notifier.RenderTemplate(tpl, ev, format)
+	if err != nil {
+		http.Error(w, err.Error(), 422)
+		return
+	}
+
+	writeJSON(w, 200, map[string]any{
+		"source":  source,
+		"format":  format,
+		"subject": msg.Subject,
+		"text":    msg.Text,
+	})
+}
+
+// resolveChannel — по имени канала из конфига находим его тип;
+// иначе считаем, что передан сам тип
+func (s *Server) resolveChannel(v string) (name, typ string) {
+	for _, ch := range s.cfg.Notifications.Channels {
+		if ch.Name == v {
+			return ch.Name, ch.Type
+		}
+	}
+	return "", v
+}
+
+func decodeTemplate(r *http.Request) (*storage.NotificationTemplate, error) {
+	var t storage.NotificationTemplate
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		return nil, err
+	}
+	t.EventType = strings.TrimSpace(t.EventType)
+	t.Channel = strings.TrimSpace(t.Channel)
+	if t.EventType == "" {
+		return nil, errors.New("event_type is required")
+	}
+	if t.Channel == "" {
+		t.Channel = "*"
+	}
+	if err := notifier.ValidateTemplate(t.EventType, notifier.Template{Subject: t.Subject, Body: t.Body}); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
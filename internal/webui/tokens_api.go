@@ -0,0 +1,201 @@
+package webui
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/L1nMay/portscanner/internal/auth"
+	"github.com/L1nMay/portscanner/internal/netutil"
+	"github.com/L1nMay/portscanner/internal/storage"
+)
+
+// defaultTokenTTL — срок жизни токена, если expires_at не указан
+const defaultTokenTTL = 90 * 24 * time.Hour
+
+type tokenRequest struct {
+	Name         string     `json:"name"`
+	UserID       int64      `json:"user_id,omitempty"` // только admin: токен для другого пользователя/сервиса
+	Scopes       []string   `json:"scopes"`
+	AllowedCIDRs []string   `json:"allowed_cidrs"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	NoExpiry     bool       `json:"no_expiry,omitempty"`
+}
+
+func (s *Server) handleTokens(w http.ResponseWriter, r *http.Request) {
+	p := auth.FromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		f := storage.TokenFilter{UserID: p.UserID, WithRevoked: q.Get("revoked") == "1"}
+		if p.Role.Allows(auth.RoleAdmin) {
+			f.UserID = 0
+			if v := q.Get("user_id"); v != "" {
+				id, err := strconv.ParseInt(v, 10, 64)
+				if err != nil {
+					http.Error(w, "invalid user_id", 400)
+					return
+				}
+				f.UserID = id
+			}
+		}
+		if v := q.Get("unused_days"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid unused_days", 400)
+				return
+			}
+			f.UnusedFor = time.Duration(n) * 24 * time.Hour
+		}
+
+		tokens, err := s.pg.ListTokens(f)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		writeJSON(w, 200, tokens)
+
+	case http.MethodPost:
+		// токеном нельзя выпустить новый токен — только из сессии или legacy-админом
+		if p.Via == "token" {
+			http.Error(w, "tokens cannot be created with an API token", 403)
+			return
+		}
+		var req tokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		ownerID := p.UserID
+		if req.UserID != 0 && req.UserID != p.UserID {
+			if !p.Role.Allows(auth.RoleAdmin) {
+				http.Error(w, "only admin can create tokens for other users", 403)
+				return
+			}
+			ownerID = req.UserID
+		}
+		if ownerID == 0 {
+			http.Error(w, "user_id is required", 400)
+			return
+		}
+		owner, err := s.pg.GetUser(ownerID)
+		if err != nil {
+			storageError(w, err)
+			return
+		}
+
+		t, err := newTokenFromRequest(owner, &req)
+		if err != nil {
+			http.Error(w, err.Error(), 400)
+			return
+		}
+		secret := auth.NewAPIToken()
+		t.Prefix = secret[:len(auth.TokenPrefix)+6]
+		if err := s.pg.CreateToken(t, auth.HashToken(secret)); err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		auditSet(r, "token_id", t.ID)
+		auditSet(r, "prefix", t.Prefix)
+		// секрет отдаётся только здесь, один раз
+		writeJSON(w, 201, map[string]any{"token": secret, "info": t})
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	t, err := s.pg.GetToken(id)
+	if err != nil {
+		storageError(w, err)
+		return
+	}
+	p := auth.FromContext(r.Context())
+	if t.UserID != p.UserID && !p.Role.Allows(auth.RoleAdmin) {
+		http.Error(w, storage.ErrNotFound.Error(), 404)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, 200, t)
+
+	case http.MethodDelete:
+		if err := s.pg.RevokeToken(id); err != nil {
+			storageError(w, err)
+			return
+		}
+		writeJSON(w, 200, map[string]any{"revoked": id})
+
+	default:
+		http.Error(w, "method not allowed", 405)
+	}
+}
+
+// newTokenFromRequest — проверка запроса; scopes не могут превышать роль владельца
+func newTokenFromRequest(owner *storage.User, req *tokenRequest) (*storage.APIToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if owner.Disabled {
+		return nil, errors.New("user " + owner.Username + " is disabled")
+	}
+
+	scopes, err := auth.ParseScopes(req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.Role(owner.Role).Allows(auth.ScopesRole(scopes)) {
+		return nil, errors.New("scopes exceed the role of " + owner.Username + " (" + owner.Role + ")")
+	}
+	cidrs, err := netutil.NormalizeCIDRs(cleanTags(req.AllowedCIDRs))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &storage.APIToken{
+		UserID:       owner.ID,
+		Name:         name,
+		Scopes:       scopes,
+		AllowedCIDRs: cidrs,
+	}
+	switch {
+	case req.NoExpiry:
+	case req.ExpiresAt != nil:
+		if !req.ExpiresAt.After(time.Now()) {
+			return nil, errors.New("expires_at must be in the future")
+		}
+		t.ExpiresAt = req.ExpiresAt
+	default:
+		exp := time.Now().Add(defaultTokenTTL).UTC()
+		t.ExpiresAt = &exp
+	}
+	return t, nil
+}
+
+// checkTargetScope — токен с allowed_cidrs может сканировать только эти сети
+func checkTargetScope(p *auth.Principal, targets []string) error {
+	if p == nil || len(p.AllowedCIDRs) == 0 {
+		return nil
+	}
+	if len(targets) == 0 {
+		return errors.New("this token requires explicit scan targets")
+	}
+	for _, t := range targets {
+		if !netutil.Within(t, p.AllowedCIDRs) {
+			return errors.New("target " + t + " is outside the token's allowed networks")
+		}
+	}
+	return nil
+}
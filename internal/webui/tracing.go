@@ -0,0 +1,44 @@
+package webui
+
+import (
+	"net/http"
+
+	"github.com/L1nMay/portscanner/internal/logger"
+	"github.com/L1nMay/portscanner/internal/tracing"
+)
+
+// withTracing — серверный спан на запрос (родитель — входящий traceparent);
+// trace_id попадает в логи запроса и в заголовок ответа
+func withTracing(routeName routeFunc, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeName(r)
+		ctx := tracing.Extract(r.Context(), r.Header.Get("traceparent"))
+		ctx, span := tracing.Start(ctx, r.Method+" "+route, tracing.WithKind(tracing.KindServer), tracing.WithAttrs(
+			tracing.String("http.request.method", r.Method),
+			tracing.String("http.route", route),
+			tracing.String("url.path", r.URL.Path),
+		))
+		if span == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer span.End()
+
+		if id := w.Header().Get("X-Request-ID"); id != "" { // выставил withRequestID
+			span.SetAttrs(tracing.String("request_id", id))
+		}
+		ctx = logger.With(ctx, "trace_id", span.TraceID())
+		w.Header().Set("traceparent", tracing.Traceparent(ctx))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if rec.status == 0 {
+			rec.status = 200
+		}
+		span.SetAttrs(tracing.Int("http.response.status_code", rec.status))
+		if rec.status >= 500 {
+			span.SetStatus(tracing.StatusError, http.StatusText(rec.status))
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package webui
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/L1nMay/portscanner/internal/tracing"
+)
+
+const (
+	remoteTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	remoteSpan  = "00f067aa0ba902b7"
+)
+
+// tracedHandler — та же цепочка, что в Handler, без авторизации и Postgres
+func tracedHandler() http.Handler {
+	mux := http.NewServeMux()
+	api := http.NewServeMux()
+	api.HandleFunc("/api/hosts/{ip}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "storage.query")
+		span.End()
+		writeJSON(w, 200, map[string]string{"ip": r.PathValue("ip")})
+	})
+	api.HandleFunc("/api/boom", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "boom", 500)
+	})
+	mux.Handle("/api/", api)
+
+	route := routeOf(mux, api)
+	return withRequestID(withTracing(route, withMetrics(route, mux)))
+}
+
+func TestHTTPSpans(t *testing.T) {
+	exp := tracing.NewMemoryExporter()
+	shutdown := tracing.Use(exp, 1)
+	t.Cleanup(func() { _ = shutdown(context.Background()) })
+
+	h := tracedHandler()
+
+	req := httptest.NewRequest("GET", "/api/hosts/10.0.0.5", nil)
+	req.Header.Set("traceparent", "00-"+remoteTrace+"-"+remoteSpan+"-01")
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	rec500 := httptest.NewRecorder()
+	h.ServeHTTP(rec500, httptest.NewRequest("GET", "/api/boom", nil))
+
+	tracing.ForceFlush(context.Background())
+	byName := map[string]tracing.SpanData{}
+	for _, s := range exp.Spans() {
+		byName[s.Name] = s
+	}
+
+	server, ok := byName["GET /api/hosts/{ip}"]
+	if !ok {
+		t.Fatalf("no server span, got %v", spanNames(exp.Spans()))
+	}
+	if server.Kind != tracing.KindServer {
+		t.Errorf("kind = %v", server.Kind)
+	}
+	if server.TraceID.String() != remoteTrace || server.ParentID.String() != remoteSpan {
+		t.Errorf("server span %s/%s does not continue the incoming traceparent", server.TraceID, server.ParentID)
+	}
+	if got := spanAttr(server, "http.route"); got != "/api/hosts/{ip}" {
+		t.Errorf("http.route = %v", got)
+	}
+	if got := spanAttr(server, "http.response.status_code"); got != int64(200) {
+		t.Errorf("status_code = %v", got)
+	}
+	if got := spanAttr(server, "request_id"); got != "req-1" {
+		t.Errorf("request_id = %v", got)
+	}
+	if server.Status == tracing.StatusError {
+		t.Errorf("200 marked as error")
+	}
+
+	want := "00-" + remoteTrace + "-" + server.SpanID.String() + "-01"
+	if got := rec.Header().Get("traceparent"); got != want {
+		t.Errorf("response traceparent = %q, want %q", got, want)
+	}
+
+	child, ok := byName["storage.query"]
+	if !ok {
+		t.Fatalf("no handler span, got %v", spanNames(exp.Spans()))
+	}
+	if child.TraceID != server.TraceID || child.ParentID != server.SpanID {
+		t.Errorf("storage.query parent %s/%s, want %s/%s", child.TraceID, child.ParentID, server.TraceID, server.SpanID)
+	}
+
+	boom, ok := byName["GET /api/boom"]
+	if !ok {
+		t.Fatalf("no span for /api/boom, got %v", spanNames(exp.Spans()))
+	}
+	if boom.ParentID.IsValid() || boom.TraceID == server.TraceID {
+		t.Errorf("request without traceparent joined another trace")
+	}
+	if boom.Status != tracing.StatusError || spanAttr(boom, "http.response.status_code") != int64(500) {
+		t.Errorf("500: status %v, attrs %v", boom.Status, boom.Attrs)
+	}
+	if !strings.HasPrefix(rec500.Header().Get("traceparent"), "00-"+boom.TraceID.String()+"-") {
+		t.Errorf("500 response traceparent = %q", rec500.Header().Get("traceparent"))
+	}
+}
+
+func spanNames(spans []tracing.SpanData) []string {
+	out := make([]string, 0, len(spans))
+	for _, s := range spans {
+		out = append(out, s.Name)
+	}
+	return out
+}
+
+// spanAttr — последнее значение атрибута (SetAttrs дописывает)
+func spanAttr(s tracing.SpanData, key string) any {
+	var v any
+	for _, a := range s.Attrs {
+		if a.Key == key {
+			v = a.Value
+		}
+	}
+	return v
+}
@@ -0,0 +1,17 @@
+-- предположение об ОС хоста (nmap -O + эвристики по баннерам/TTL)
+ALTER TABLE hosts
+    ADD COLUMN IF NOT EXISTS os_name TEXT;
+
+ALTER TABLE hosts
+    ADD COLUMN IF NOT EXISTS os_family TEXT;
+
+ALTER TABLE hosts
+    ADD COLUMN IF NOT EXISTS os_confidence INTEGER;
+
+ALTER TABLE hosts
+    ADD COLUMN IF NOT EXISTS os_sources TEXT;
+
+ALTER TABLE hosts
+    ADD COLUMN IF NOT EXISTS os_updated_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS hosts_os_family_idx ON hosts (os_family);
@@ -0,0 +1,45 @@
+-- группы активов: владелец, окружение, набор сетей и теги, наследуемые хостами
+CREATE TABLE IF NOT EXISTS asset_groups (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    owner TEXT,
+    environment TEXT,
+    description TEXT,
+    cidrs CIDR[] NOT NULL DEFAULT '{}',
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- теги на хост (/32) или диапазон; owner/env хранятся как "owner:<x>" / "env:<x>"
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    cidr CIDR NOT NULL,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (cidr, name)
+);
+
+CREATE INDEX IF NOT EXISTS tags_cidr_idx ON tags USING gist (cidr inet_ops);
+
+-- членство хостов в группах (по вхождению ip в любую сеть группы)
+CREATE OR REPLACE VIEW host_groups AS
+    SELECT h.id AS host_id, g.id AS group_id, g.name AS group_name
+    FROM hosts h
+    JOIN asset_groups g
+      ON EXISTS (SELECT 1 FROM unnest(g.cidrs) AS c WHERE c >>= h.ip);
+
+-- эффективные теги хоста: свои + диапазонов + групп (включая owner/env группы)
+CREATE OR REPLACE VIEW host_tags AS
+    SELECT h.id AS host_id, t.name AS tag
+    FROM hosts h
+    JOIN tags t ON t.cidr >>= h.ip
+    UNION
+    SELECT hg.host_id, gt.tag
+    FROM host_groups hg
+    JOIN asset_groups g ON g.id = hg.group_id
+    CROSS JOIN LATERAL (
+        SELECT unnest(g.tags)
+        UNION ALL SELECT 'owner:' || g.owner WHERE COALESCE(g.owner, '') <> ''
+        UNION ALL SELECT 'env:' || g.environment WHERE COALESCE(g.environment, '') <> ''
+    ) AS gt(tag);
@@ -0,0 +1,24 @@
+-- политики экспозиции: какие порты/сервисы разрешены или запрещены для хоста/группы/сети
+CREATE TABLE IF NOT EXISTS policies (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    description TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    mode TEXT NOT NULL DEFAULT 'allow',
+    scope_type TEXT NOT NULL DEFAULT 'global',
+    scope TEXT NOT NULL DEFAULT '',
+    ports TEXT NOT NULL DEFAULT '',
+    services TEXT[] NOT NULL DEFAULT '{}',
+    severity TEXT NOT NULL DEFAULT 'medium',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- результат последней проверки находки: compliant | violating (NULL — не проверялась)
+ALTER TABLE ports
+    ADD COLUMN IF NOT EXISTS compliance TEXT;
+
+ALTER TABLE ports
+    ADD COLUMN IF NOT EXISTS violations JSONB;
+
+CREATE INDEX IF NOT EXISTS ports_compliance_idx ON ports (compliance);
@@ -0,0 +1,17 @@
+-- надёжная доставка событий: ретраи с backoff, dead-letter, маршрут по каналам
+ALTER TABLE events ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now();
+ALTER TABLE events ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS dead BOOLEAN NOT NULL DEFAULT false;
+
+-- каналы, выбранные правилами (NULL — ещё не маршрутизировано) и уже получившие событие
+ALTER TABLE events ADD COLUMN IF NOT EXISTS channels TEXT[];
+ALTER TABLE events ADD COLUMN IF NOT EXISTS delivered_to TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS events_pending_idx
+    ON events (next_attempt_at)
+    WHERE delivered = false AND dead = false;
+
+CREATE INDEX IF NOT EXISTS events_dead_idx
+    ON events (id)
+    WHERE dead = true;
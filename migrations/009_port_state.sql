@@ -0,0 +1,18 @@
+-- состояние порта: open/closed по результатам последнего скана, покрывающего порт
+ALTER TABLE ports ADD COLUMN IF NOT EXISTS state TEXT NOT NULL DEFAULT 'open';
+ALTER TABLE ports ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
+-- когда сменился сервис или порт открылся снова (для дайджестов)
+ALTER TABLE ports ADD COLUMN IF NOT EXISTS changed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS ports_first_seen_idx ON ports (first_seen);
+CREATE INDEX IF NOT EXISTS ports_closed_at_idx ON ports (closed_at) WHERE closed_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS ports_changed_at_idx ON ports (changed_at) WHERE changed_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS events_type_created_idx ON events (type, created_at);
+
+-- периоды регулярных дайджестов, уже отправленных (защита от дублей между инстансами)
+CREATE TABLE IF NOT EXISTS digest_runs (
+    kind TEXT NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (kind, period_end)
+);
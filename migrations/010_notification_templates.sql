@@ -0,0 +1,10 @@
+-- пользовательские шаблоны уведомлений: тип события x канал (имя, тип канала или '*')
+CREATE TABLE IF NOT EXISTS notification_templates (
+    id SERIAL PRIMARY KEY,
+    event_type TEXT NOT NULL,
+    channel TEXT NOT NULL DEFAULT '*',
+    subject TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (event_type, channel)
+);
@@ -0,0 +1,17 @@
+-- API-токены для автоматизации; хранится только sha256 от секрета
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,                       -- начало токена, чтобы узнать его в списке
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    allowed_cidrs TEXT[] NOT NULL DEFAULT '{}', -- пусто — без ограничений по целям
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ,
+    last_used_ip TEXT,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_user_idx ON api_tokens (user_id);
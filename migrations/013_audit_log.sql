@@ -0,0 +1,30 @@
+-- журнал действий операторов; только добавление записей
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    ts TIMESTAMPTZ NOT NULL DEFAULT now(),
+    actor TEXT NOT NULL,                 -- имя пользователя / "anonymous"
+    actor_type TEXT NOT NULL,            -- session | token | legacy | telegram | anonymous
+    user_id INTEGER,                     -- без FK: запись переживает удаление пользователя
+    token_id INTEGER,
+    action TEXT NOT NULL,                -- scan.start, policy.update, token.create, ...
+    params JSONB NOT NULL DEFAULT '{}'::jsonb,
+    source_ip TEXT NOT NULL DEFAULT '',
+    outcome TEXT NOT NULL,               -- success | denied | failure
+    status INTEGER NOT NULL DEFAULT 0,   -- HTTP-код ответа
+    error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_ts_idx ON audit_log (ts DESC);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, ts DESC);
+CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log (action, ts DESC);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
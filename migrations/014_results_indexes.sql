@@ -0,0 +1,7 @@
+-- индексы под фильтры и keyset-пагинацию /api/results
+CREATE INDEX IF NOT EXISTS ports_last_seen_id_idx ON ports (last_seen, id);
+CREATE INDEX IF NOT EXISTS ports_first_seen_id_idx ON ports (first_seen, id);
+CREATE INDEX IF NOT EXISTS ports_port_idx ON ports (port, id);
+CREATE INDEX IF NOT EXISTS ports_service_idx ON ports (lower(COALESCE(service, 'unknown')));
+CREATE INDEX IF NOT EXISTS ports_state_idx ON ports (state);
+CREATE INDEX IF NOT EXISTS hosts_ip_gist_idx ON hosts USING gist (ip inet_ops);
@@ -0,0 +1,10 @@
+-- что видел каждый скан: open — порт найден, closed — закрыт этим сканом
+CREATE TABLE IF NOT EXISTS port_observations (
+    scan_id UUID NOT NULL,
+    port_id INTEGER NOT NULL REFERENCES ports(id) ON DELETE CASCADE,
+    state TEXT NOT NULL,
+    seen_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scan_id, port_id)
+);
+
+CREATE INDEX IF NOT EXISTS port_observations_port_idx ON port_observations (port_id, seen_at DESC);
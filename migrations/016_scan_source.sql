@@ -0,0 +1,3 @@
+-- откуда прогон: scan — свой сканер, import — загруженный файл
+ALTER TABLE scans
+    ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'scan';
@@ -0,0 +1,13 @@
+-- сгенерированные HTML-отчёты; period_to последнего — начало следующего
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    kind TEXT NOT NULL DEFAULT 'manual',
+    created_by TEXT NOT NULL DEFAULT '',
+    period_from TIMESTAMPTZ NOT NULL,
+    period_to TIMESTAMPTZ NOT NULL,
+    summary JSONB NOT NULL DEFAULT '{}',
+    html TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS reports_period_to_idx ON reports (period_to DESC);
@@ -0,0 +1,15 @@
+-- строка scans создаётся при старте (running) и закрывается finished/failed/cancelled
+ALTER TABLE scans
+    ADD COLUMN IF NOT EXISTS error TEXT;
+
+-- журнал скана: прогресс, командные строки движков, их stderr, предупреждения
+CREATE TABLE IF NOT EXISTS scan_logs (
+    id BIGSERIAL PRIMARY KEY,
+    scan_id UUID NOT NULL,
+    ts TIMESTAMPTZ NOT NULL,
+    level TEXT NOT NULL,
+    source TEXT NOT NULL,   -- progress | log | stderr
+    message TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scan_logs_scan_idx ON scan_logs (scan_id, id);
@@ -0,0 +1,3 @@
+-- владелец аренды события: воркер, у которого истёк lease, не может
+-- завершить событие, уже перехваченное другим воркером
+ALTER TABLE events ADD COLUMN IF NOT EXISTS lease_token TEXT;
@@ -0,0 +1,5 @@
+-- /mute из Telegram: переживает рестарт и общий для всех экземпляров
+CREATE TABLE IF NOT EXISTS alert_mutes (
+    rule TEXT PRIMARY KEY,
+    until TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,7 @@
+-- сертификат TLS-порта с последнего скана (для отчёта об истекающих сертификатах)
+ALTER TABLE ports ADD COLUMN IF NOT EXISTS tls_subject TEXT;
+ALTER TABLE ports ADD COLUMN IF NOT EXISTS tls_issuer TEXT;
+ALTER TABLE ports ADD COLUMN IF NOT EXISTS tls_dns_names TEXT[];
+ALTER TABLE ports ADD COLUMN IF NOT EXISTS tls_not_after TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS ports_tls_not_after_idx ON ports (tls_not_after) WHERE tls_not_after IS NOT NULL;
@@ -0,0 +1,15 @@
+-- окна throttle/dedup правил оповещений: общие для всех воркеров, переживают рестарт
+CREATE TABLE IF NOT EXISTS alert_sends (
+    rule TEXT NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS alert_sends_rule_idx ON alert_sends (rule, sent_at);
+
+CREATE TABLE IF NOT EXISTS alert_dedup (
+    key TEXT PRIMARY KEY,
+    rule TEXT NOT NULL,
+    until TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS alert_dedup_rule_idx ON alert_dedup (rule, until);